@@ -2,134 +2,717 @@ package main
 
 import (
     "bufio"
+    "context"
     "encoding/csv"
+    "errors"
+    "flag"
     "fmt"
+    "io"
     "os"
     "os/exec"
+    "slices"
     "strconv"
     "strings"
     "time"
 
+    "github.com/btcsuite/btcd/chaincfg"
     "github.com/mdp/qrterminal/v3"
+    "golang.org/x/term"
     "litecoin-wallet/internal/api"
+    "litecoin-wallet/internal/batch"
+    "litecoin-wallet/internal/coinselect"
+    "litecoin-wallet/internal/config"
     "litecoin-wallet/internal/crypto"
     "litecoin-wallet/internal/db"
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "litecoin-wallet/internal/txbuilder"
+    "litecoin-wallet/internal/txsync"
     "litecoin-wallet/internal/ui"
     "litecoin-wallet/internal/wallet"
-	qrcode "github.com/skip2/go-qrcode"
+    qrcode "github.com/skip2/go-qrcode"
 )
 
-var lastSyncTime string
-var lastBalance float64
+// app is one run of the wallet: the chain backend and network it talks to,
+// where answers are read from and where output goes, and the session state
+// the menus share. Every menu action is a method, so tests can drive it with
+// a MemoryProvider and canned input.
+type app struct {
+    provider api.ChainProvider
+    params   *chaincfg.Params
+    sendMode string
+    in       *bufio.Scanner
+    out      io.Writer
+    ui       ui.Printer
+    // tty, when set, is the terminal secrets are read from without echo;
+    // otherwise they are read from in like any other answer.
+    tty *os.File
+
+    lastSyncTime string
+    lastBalance  models.Amount
+    // coinSelection holds the outpoints picked on the coin control screen
+    // for the next send from each address.
+    coinSelection map[string][]string
+}
+
+func newApp(provider api.ChainProvider, params *chaincfg.Params, in io.Reader, out io.Writer) *app {
+    return &app{
+        provider:      provider,
+        params:        params,
+        sendMode:      "local",
+        in:            bufio.NewScanner(in),
+        out:           out,
+        ui:            ui.Printer{W: out},
+        coinSelection: map[string][]string{},
+    }
+}
 
 func main() {
-    _, ierr := db.InitDB()
-    if ierr != nil {
-        ui.PrintError("Could not create or open database: " + ierr.Error())
+    cfg, err := config.Load()
+    if err != nil {
+        ui.PrintError("Could not load config: " + err.Error())
+        os.Exit(1)
+    }
+    network := flag.String("network", cfg.Network, "Litecoin network: mainnet, testnet or regtest")
+    flag.Parse()
+    params, err := netparams.ByName(*network)
+    if err != nil {
+        ui.PrintError(err.Error())
+        os.Exit(1)
+    }
+    db.SetNetwork(params.Name)
+    provider, err := newProvider(cfg, params)
+    if err != nil {
+        ui.PrintError(err.Error())
         os.Exit(1)
     }
-    scanner := bufio.NewScanner(os.Stdin)
-    w := &wallet.Wallet{}
-    apiClient := api.NewBlockCypherClient()
 
+    a := newApp(provider, params, os.Stdin, os.Stdout)
+    a.sendMode = cfg.SendMode
+    a.tty = os.Stdin
+    db.SetPassphrasePrompt(a.promptPassphrase)
+    if _, err := db.InitDB(); err != nil {
+        ui.PrintError("Could not create or open database: " + err.Error())
+        os.Exit(1)
+    }
+    a.run()
+}
+
+// run shows the main menu until a wallet is open and the wallet menu after
+// that, until input ends or the user exits.
+func (a *app) run() {
+    w := &wallet.Wallet{}
     for {
         if w.PrivateKey == "" {
-            ui.PrintBanner()
-            items := []string{"1. Generate new wallet", "2. Load wallet from disk"}
-            ui.PrintMenu("MAIN MENU", items)
-            ui.PrintPrompt("Select option: ")
-            scanner.Scan()
-            choice := strings.TrimSpace(scanner.Text())
+            a.ui.PrintBanner()
+            a.printNetworkNotice()
+            items := []string{"1. Generate new wallet", "2. Load wallet from disk", "3. Restore HD wallet from mnemonic", "4. Change database passphrase", "5. Provider status"}
+            a.ui.PrintMenu("MAIN MENU", items)
+            a.ui.PrintPrompt("Select option: ")
+            if !a.in.Scan() {
+                return
+            }
+            choice := strings.TrimSpace(a.in.Text())
             switch choice {
             case "1":
-                generateWallet(w, scanner)
+                a.generateWallet(w)
             case "2":
-                loadWallet(w, apiClient, scanner)
+                a.loadWallet(w)
+            case "3":
+                a.restoreWallet(w)
+            case "4":
+                a.changePassphrase()
+            case "5":
+                a.showProviderStatus()
             default:
-                ui.PrintError("Invalid choice.")
+                a.ui.PrintError("Invalid choice.")
+            }
+            continue
+        }
+        a.walletAppMenu(w)
+        fmt.Fprintf(a.out, "\n%sPress ENTER to continue...%s", ui.Yellow, ui.Reset)
+        if !a.in.Scan() {
+            return
+        }
+        fmt.Fprint(a.out, "\033[H\033[2J")
+    }
+}
+
+// newProvider builds the chain backend selected by cfg.Providers (or the
+// single cfg.Provider). Several backends, or cross_check, are combined in a
+// failover provider; unreachable ones are then only a warning.
+func newProvider(cfg *config.Config, params *chaincfg.Params) (api.ChainProvider, error) {
+    names := cfg.Providers
+    if len(names) == 0 {
+        names = []string{cfg.Provider}
+    }
+    var backends []api.ChainProvider
+    for _, name := range names {
+        p, err := newBackend(cfg, params, name)
+        if err != nil {
+            return nil, err
+        }
+        if err := probeBackend(p); err != nil {
+            if len(names) == 1 {
+                return nil, err
+            }
+            ui.PrintError(err.Error() + " Will retry it when other providers fail.")
+        }
+        backends = append(backends, p)
+    }
+    if len(backends) == 1 && !cfg.CrossCheck {
+        return backends[0], nil
+    }
+    f := api.NewFailoverProvider(backends...)
+    f.CrossCheck = cfg.CrossCheck
+    f.OnFailover = func(name string, err error) {
+        ui.PrintInfo(fmt.Sprintf("%s failed (%v), trying the next provider...", name, err))
+    }
+    return f, nil
+}
+
+func newBackend(cfg *config.Config, params *chaincfg.Params, name string) (api.ChainProvider, error) {
+    switch strings.ToLower(strings.TrimSpace(name)) {
+    case "", "blockcypher":
+        bc, err := api.NewBlockCypherClient(params, cfg.BlockCypherURL)
+        if err != nil {
+            return nil, err
+        }
+        if cfg.MaxFee > 0 {
+            bc.MaxFee = cfg.MaxFee
+        }
+        bc.SetToken(cfg.BlockCypherToken)
+        return bc, nil
+    case "litecoind", "core":
+        return api.NewCoreRPCClient(params, cfg.RPCURL, cfg.RPCUser, cfg.RPCPassword, cfg.RPCCookie, cfg.RPCWallet), nil
+    case "electrum":
+        if cfg.ElectrumServer == "" {
+            return nil, fmt.Errorf("Set electrum_server (e.g. ssl://host:50002) to use the Electrum provider.")
+        }
+        return api.NewElectrumClient(params, cfg.ElectrumServer, cfg.ElectrumInsecure)
+    }
+    return nil, fmt.Errorf("Unknown provider %q (use blockcypher, litecoind or electrum).", name)
+}
+
+// probeBackend checks that self-hosted backends are reachable and on the
+// right network before the wallet relies on them.
+func probeBackend(p api.ChainProvider) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+    switch b := p.(type) {
+    case *api.CoreRPCClient:
+        info, err := b.ChainInfo(ctx)
+        if err != nil {
+            return fmt.Errorf("Could not reach litecoind at %s: %v.", b.URL, err)
+        }
+        ui.PrintInfo(fmt.Sprintf("Connected to litecoind (%s, height %d).", info.Chain, info.Blocks))
+    case *api.ElectrumClient:
+        height, err := b.TipHeight(ctx)
+        if err != nil {
+            return fmt.Errorf("Could not reach Electrum server %s: %v.", b.Server, err)
+        }
+        ui.PrintInfo(fmt.Sprintf("Connected to Electrum server %s (height %d).", b.Server, height))
+    case *api.BlockCypherClient:
+        if b.Token == "" {
+            return nil
+        }
+        usage, err := b.TokenStatus(ctx)
+        if err != nil {
+            ui.PrintError("Could not read BlockCypher token limits: " + err.Error())
+            return nil
+        }
+        b.ApplyTokenLimits(usage)
+    }
+    return nil
+}
+
+// showProviderStatus lists each backend's health and, for BlockCypher, the
+// remaining request quota (from /tokens when a token is configured).
+func (a *app) showProviderStatus() {
+    a.ui.PrintSection("Provider status")
+    backends := []api.ChainProvider{a.provider}
+    var health []api.BackendStatus
+    if f, ok := a.provider.(*api.FailoverProvider); ok {
+        backends, health = f.Providers, f.Status()
+        if f.CrossCheck {
+            a.ui.PrintInfo("Cross-check before sending: on")
+        }
+    }
+    now := time.Now()
+    for i, p := range backends {
+        state := ui.Green + "ok" + ui.Reset
+        if health != nil {
+            h := health[i]
+            switch {
+            case !h.Healthy(now):
+                state = fmt.Sprintf("%scircuit open for %s%s (%v)", ui.Red, h.OpenUntil.Sub(now).Round(time.Second), ui.Reset, h.LastErr)
+            case h.Failures > 0:
+                state = fmt.Sprintf("%s%d recent failure(s)%s (%v)", ui.Yellow, h.Failures, ui.Reset, h.LastErr)
+            }
+        }
+        fmt.Fprintf(a.out, "%s%d. %s%s  %s\n", ui.Cyan, i+1, p.Name(), ui.Reset, state)
+        bc, ok := p.(*api.BlockCypherClient)
+        if !ok {
+            continue
+        }
+        if bc.Token != "" {
+            ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+            usage, err := bc.TokenStatus(ctx)
+            cancel()
+            if err != nil {
+                a.ui.PrintError("   Could not read token usage: " + err.Error())
+            } else {
+                bc.ApplyTokenLimits(usage)
+                fmt.Fprintf(a.out, "   Token quota: %d of %d requests left this hour (%d/s)\n",
+                    usage.Remaining("api/hour"), usage.Limits["api/hour"], usage.Limits["api/second"])
             }
             continue
         }
-        walletAppMenu(w, apiClient, scanner)
-        fmt.Printf("\n%sPress ENTER to continue...%s", ui.Yellow, ui.Reset)
-        scanner.Scan()
-        fmt.Print("\033[H\033[2J")
+        fmt.Fprintln(a.out, "   No API token (set blockcypher_token to raise the limits).")
+        if bc.Limiter != nil {
+            for _, q := range bc.Limiter.Remaining() {
+                if q.Period >= time.Hour {
+                    fmt.Fprintf(a.out, "   Estimated quota: %d of %d requests left this hour\n", q.Available, q.Capacity)
+                }
+            }
+        }
+    }
+}
+
+func (a *app) printNetworkNotice() {
+    if a.params.Net != netparams.MainNetParams.Net {
+        a.ui.PrintInfo(fmt.Sprintf("%sNetwork: %s%s — test coins only, they have no value.", ui.Bold, strings.ToUpper(a.params.Name), ui.Reset+ui.Cyan))
+    }
+}
+
+// explorerTxURL links to a block explorer for txHash, or returns "" on
+// networks without a public explorer.
+func explorerTxURL(params *chaincfg.Params, txHash string) string {
+    switch params.Name {
+    case netparams.MainNetParams.Name:
+        return "https://live.blockcypher.com/ltc/tx/" + txHash
+    case netparams.TestNet4Params.Name:
+        return "https://litecoinspace.org/testnet/tx/" + txHash
+    }
+    return ""
+}
+
+func (a *app) readSecret(prompt string) (string, error) {
+    a.ui.PrintPrompt(prompt)
+    if a.tty != nil && term.IsTerminal(int(a.tty.Fd())) {
+        b, err := term.ReadPassword(int(a.tty.Fd()))
+        fmt.Fprintln(a.out)
+        return string(b), err
+    }
+    if !a.in.Scan() {
+        return "", fmt.Errorf("no input")
+    }
+    return a.in.Text(), nil
+}
+
+func (a *app) promptPassphrase(isNew bool) (string, error) {
+    if !isNew {
+        return a.readSecret("Wallet database passphrase: ")
+    }
+    a.ui.PrintInfo("Choose a passphrase to encrypt your wallet database. It cannot be recovered if lost.")
+    for {
+        pass, err := a.readSecret("New passphrase: ")
+        if err != nil {
+            return "", err
+        }
+        if len(pass) < 8 {
+            a.ui.PrintError("Passphrase must be at least 8 characters.")
+            continue
+        }
+        again, err := a.readSecret("Repeat passphrase: ")
+        if err != nil {
+            return "", err
+        }
+        if pass != again {
+            a.ui.PrintError("Passphrases do not match.")
+            continue
+        }
+        return pass, nil
+    }
+}
+
+func (a *app) changePassphrase() {
+    oldPass, err := a.readSecret("Current passphrase: ")
+    if err != nil {
+        return
+    }
+    newPass, err := a.promptPassphrase(true)
+    if err != nil {
+        return
+    }
+    if err := db.ChangePassphrase(oldPass, newPass); err != nil {
+        a.ui.PrintError("Failed to change passphrase: " + err.Error())
+        return
     }
+    a.ui.PrintSuccess("Passphrase changed. All wallets were re-encrypted.")
 }
 
-func generateWallet(w *wallet.Wallet, scanner *bufio.Scanner) {
-    wlt, err := crypto.GenerateLitecoinWallet()
+func (a *app) generateWallet(w *wallet.Wallet) {
+    a.ui.PrintPrompt("Wallet type: 1. Single key  2. HD (BIP39 mnemonic) [2]: ")
+    a.in.Scan()
+    single := strings.TrimSpace(a.in.Text()) == "1"
+    addrType := a.chooseAddressType()
+    if single {
+        wlt, err := crypto.GenerateLitecoinWallet(a.params, addrType)
+        if err != nil {
+            a.ui.PrintError("Failed to generate wallet: " + err.Error())
+            return
+        }
+        *w = wallet.Wallet{Kind: db.KindKey, AddrType: string(addrType), PrivateKey: wlt.PrivateKey, PublicKey: wlt.PublicKey, Address: wlt.Address}
+        a.ui.PrintInfo(fmt.Sprintf("Address: %s%s%s", ui.Cyan, w.Address, ui.Reset))
+        a.ui.PrintInfo(fmt.Sprintf("Private: %s%s%s", ui.Yellow, w.PrivateKey, ui.Reset))
+        a.nameAndSave(w)
+        return
+    }
+    a.ui.PrintPrompt("Mnemonic length, 12 or 24 words [12]: ")
+    a.in.Scan()
+    words := 12
+    if strings.TrimSpace(a.in.Text()) == "24" {
+        words = 24
+    }
+    passphrase, err := a.readSecret("Optional BIP39 passphrase (ENTER for none): ")
+    if err != nil {
+        return
+    }
+    hd, mnemonic, err := crypto.NewHDWallet(a.params, words, passphrase)
     if err != nil {
-        ui.PrintError("Failed to generate wallet: " + err.Error())
+        a.ui.PrintError("Failed to generate wallet: " + err.Error())
         return
     }
-    w.PrivateKey = wlt.PrivateKey
-    w.PublicKey = wlt.PublicKey
-    w.Address = wlt.Address
-    alias := "TEMP"
-    ui.PrintInfo(fmt.Sprintf("Address: %s%s%s", ui.Cyan, w.Address, ui.Reset))
-    ui.PrintInfo(fmt.Sprintf("Private: %s%s%s", ui.Yellow, w.PrivateKey, ui.Reset))
-    ui.PrintPrompt("Set an alias for this wallet (default TEMP): ")
-    scanner.Scan()
-    userAlias := strings.TrimSpace(scanner.Text())
+    a.ui.PrintSection("Recovery phrase")
+    a.ui.PrintInfo("Write these words down in order. They are shown only once and restore every address of this wallet.")
+    for i, word := range strings.Fields(mnemonic) {
+        fmt.Fprintf(a.out, "%s%2d. %-10s%s", ui.Yellow, i+1, word, ui.Reset)
+        if (i+1)%4 == 0 {
+            fmt.Fprintln(a.out)
+        }
+    }
+    if passphrase != "" {
+        a.ui.PrintInfo("You also need your BIP39 passphrase to restore this wallet.")
+    }
+    a.useHDWallet(w, hd, addrType, false)
+}
+
+func (a *app) chooseAddressType() crypto.AddressType {
+    a.ui.PrintPrompt("Address type: 1. Native SegWit (lowest fees)  2. P2SH-SegWit  3. Legacy [1]: ")
+    a.in.Scan()
+    switch strings.TrimSpace(a.in.Text()) {
+    case "2":
+        return crypto.AddrP2SHP2WPKH
+    case "3":
+        return crypto.AddrP2PKH
+    default:
+        return crypto.AddrP2WPKH
+    }
+}
+
+func (a *app) restoreWallet(w *wallet.Wallet) {
+    a.ui.PrintPrompt("Enter your 12/24-word recovery phrase: ")
+    a.in.Scan()
+    mnemonic := strings.TrimSpace(a.in.Text())
+    passphrase, err := a.readSecret("BIP39 passphrase (ENTER for none): ")
+    if err != nil {
+        return
+    }
+    hd, err := crypto.RestoreHDWallet(a.params, mnemonic, passphrase)
+    if err != nil {
+        a.ui.PrintError("Cannot restore wallet: " + err.Error())
+        return
+    }
+    a.useHDWallet(w, hd, a.chooseAddressType(), true)
+}
+
+// useHDWallet opens the chosen account of hd at its first receive address.
+// Each account is a separate wallet; for a restored one the addresses it
+// used before are looked up on the chain.
+func (a *app) useHDWallet(w *wallet.Wallet, hd *crypto.HDWallet, addrType crypto.AddressType, restored bool) {
+    account, ok := a.promptNumber("Account number [0]: ", 0, 1<<31-1, 0)
+    if !ok {
+        return
+    }
+    path := hd.DerivationPath(addrType, uint32(account), 0, 0)
+    key, err := hd.DerivePath(path)
+    if err != nil {
+        a.ui.PrintError("Failed to derive address: " + err.Error())
+        return
+    }
+    *w = wallet.Wallet{
+        Kind: db.KindHD, Seed: hd.Seed, Path: path, AddrType: string(addrType),
+        PrivateKey: key.PrivateKey, PublicKey: key.PublicKey, Address: key.Address,
+        Receive: []string{key.Address}, Keys: map[string]string{key.Address: key.PrivateKey},
+    }
+    a.ui.PrintInfo(fmt.Sprintf("Path:    %s", path))
+    a.ui.PrintInfo(fmt.Sprintf("Address: %s%s%s", ui.Cyan, w.Address, ui.Reset))
+    if restored {
+        a.discoverHDAddresses(w)
+    }
+    a.nameAndSave(w)
+}
+
+// hdGapLimit is how many unused addresses in a row end the search for a
+// restored wallet's addresses, as BIP 44 recommends.
+const hdGapLimit = 20
+
+// discoverHDAddresses finds the addresses a restored wallet has used, on
+// the receive chain and then the change chain, stopping at hdGapLimit
+// addresses in a row that never had a transaction. Every address up to the
+// last used one is handed out again, so indexes stay contiguous.
+func (a *app) discoverHDAddresses(w *wallet.Wallet) {
+    a.ui.PrintInfo(fmt.Sprintf("Looking for addresses this wallet has used (up to %d unused in a row)...", hdGapLimit))
+    for _, change := range []uint32{crypto.ChainReceive, crypto.ChainChange} {
+        var unused []*crypto.LitecoinWallet
+        for len(unused) < hdGapLimit {
+            key, err := a.deriveHD(w, change, uint32(len(*hdChain(w, change))+len(unused)))
+            if err != nil {
+                a.ui.PrintError("Failed to derive address: " + err.Error())
+                return
+            }
+            info, err := a.provider.GetAddressInfo(context.Background(), key.Address)
+            if err != nil {
+                a.printAPIError(err)
+                a.ui.PrintInfo("Stopped looking. Addresses after the last one found are not watched.")
+                return
+            }
+            if info.NTx == 0 && info.Balance == 0 && info.UnconfirmedBalance == 0 {
+                unused = append(unused, key)
+                continue
+            }
+            for _, k := range append(unused, key) {
+                a.addHDAddress(w, change, k)
+            }
+            unused = nil
+        }
+    }
+    a.ui.PrintInfo(fmt.Sprintf("Found %d receive and %d change addresses.", len(w.Receive), len(w.Change)))
+}
+
+// deriveHD returns the key at change/index of w's account.
+func (a *app) deriveHD(w *wallet.Wallet, change, index uint32) (*crypto.LitecoinWallet, error) {
+    hd, err := crypto.LoadHDWallet(a.params, w.Seed)
+    if err != nil {
+        return nil, err
+    }
+    path, err := crypto.SiblingPath(w.Path, change, index)
+    if err != nil {
+        return nil, err
+    }
+    return hd.DerivePath(path)
+}
+
+// nextHDAddress derives the first address not handed out yet on the
+// receive or change chain, as change selects, without handing it out;
+// addHDAddress does that once it is used. It returns nil for a single-key
+// wallet.
+func (a *app) nextHDAddress(w *wallet.Wallet, change uint32) (*crypto.LitecoinWallet, error) {
+    if w.Kind != db.KindHD {
+        return nil, nil
+    }
+    return a.deriveHD(w, change, uint32(len(*hdChain(w, change))))
+}
+
+// hdChain is w's list of addresses handed out on the chain change selects.
+func hdChain(w *wallet.Wallet, change uint32) *[]string {
+    if change == crypto.ChainChange {
+        return &w.Change
+    }
+    return &w.Receive
+}
+
+// addHDAddress hands out key, the next address on the chain change
+// selects, and stores it so it is still watched after a restart. Failing to store it
+// only warns; the address stays in use for the session.
+func (a *app) addHDAddress(w *wallet.Wallet, change uint32, key *crypto.LitecoinWallet) {
+    if _, ok := w.Keys[key.Address]; ok {
+        return
+    }
+    chain := hdChain(w, change)
+    rec := db.HDAddress{Change: change, Index: uint32(len(*chain)), Address: key.Address}
+    *chain = append(*chain, key.Address)
+    w.Keys[key.Address] = key.PrivateKey
+    if err := db.SaveHDAddress(w.Address, rec); err != nil {
+        a.ui.PrintInfo("Could not store the new address; it will not be watched after a restart: " + err.Error())
+    }
+}
+
+// keepChange hands out the HD change address d pays, once d is broadcast.
+// change is the address the send was built with, nil for a single-key
+// wallet.
+func (a *app) keepChange(w *wallet.Wallet, d *txbuilder.Draft, change *crypto.LitecoinWallet) {
+    if change != nil && d.ChangeAddress == change.Address {
+        a.addHDAddress(w, crypto.ChainChange, change)
+    }
+}
+
+// promptNumber asks until the answer is a whole number from lo to hi. Enter
+// returns def. It reports false only when input ends.
+func (a *app) promptNumber(prompt string, lo, hi, def int) (int, bool) {
+    for {
+        a.ui.PrintPrompt(prompt)
+        if !a.in.Scan() {
+            return 0, false
+        }
+        text := strings.TrimSpace(a.in.Text())
+        if text == "" {
+            return def, true
+        }
+        n, err := strconv.Atoi(text)
+        if err == nil && n >= lo && n <= hi {
+            return n, true
+        }
+        a.ui.PrintError(fmt.Sprintf("Enter a whole number from %d to %d.", lo, hi))
+    }
+}
+
+// promptChoice asks for one of n numbered items and returns its zero-based
+// index. Invalid answers are asked again; Enter cancels.
+func (a *app) promptChoice(prompt string, n int) (int, bool) {
+    idx, ok := a.promptNumber(prompt, 1, n, 0)
+    if !ok || idx == 0 {
+        a.ui.PrintInfo("Cancelled.")
+        return 0, false
+    }
+    return idx - 1, true
+}
+
+func (a *app) nameAndSave(w *wallet.Wallet) {
+    alias := db.TempWalletAlias
+    a.ui.PrintPrompt("Set an alias for this wallet (default TEMP): ")
+    a.in.Scan()
+    userAlias := strings.TrimSpace(a.in.Text())
     if userAlias != "" {
         alias = userAlias
     }
     w.Alias = alias
-    ui.PrintPrompt("Save this wallet locally for next time? (y/N): ")
-    scanner.Scan()
-    save := strings.TrimSpace(strings.ToLower(scanner.Text()))
+    a.ui.PrintPrompt("Save this wallet locally for next time? (y/N): ")
+    a.in.Scan()
+    save := strings.TrimSpace(strings.ToLower(a.in.Text()))
     if save == "y" || save == "yes" {
-        err = db.SaveWallet(w.Alias, w.PrivateKey, w.PublicKey, w.Address)
+        err := a.saveWallet(w)
         if err == nil {
-            ui.PrintSuccess("Wallet has been saved locally.")
+            a.ui.PrintSuccess("Wallet has been saved locally.")
         } else {
-            ui.PrintError("Failed to save wallet!")
+            a.ui.PrintError("Failed to save wallet!")
         }
     } else {
-        ui.PrintInfo("Wallet not saved. It will not persist after logout or app exit.")
+        a.ui.PrintInfo("Wallet not saved. It will not persist after logout or app exit.")
+    }
+}
+
+// saveWallet persists w; HD wallets store their seed rather than the derived key.
+func (a *app) saveWallet(w *wallet.Wallet) error {
+    rec := db.WalletRecord{Alias: w.Alias, Kind: w.Kind, Private: w.PrivateKey, Public: w.PublicKey, Address: w.Address, Path: w.Path, AddrType: w.AddrType}
+    if w.Kind == db.KindHD {
+        rec.Private = w.Seed
     }
+    return db.SaveWallet(rec)
 }
 
-func loadWallet(w *wallet.Wallet, apiClient *api.BlockCypherClient, scanner *bufio.Scanner) {
-    aliases, err := db.ListWalletAliases()
-    if err != nil || len(aliases) == 0 {
-        ui.PrintError("No saved wallets found.")
+// openWallet turns a stored record back into a usable wallet session.
+func (a *app) openWallet(rec db.WalletRecord) (*wallet.Wallet, error) {
+    w := &wallet.Wallet{Alias: rec.Alias, Kind: rec.Kind, PrivateKey: rec.Private, PublicKey: rec.Public, Address: rec.Address, Path: rec.Path, AddrType: rec.AddrType}
+    if rec.Kind != db.KindHD {
+        return w, nil
+    }
+    hd, err := crypto.LoadHDWallet(a.params, rec.Private)
+    if err != nil {
+        return nil, err
+    }
+    key, err := hd.DerivePath(rec.Path)
+    if err != nil {
+        return nil, err
+    }
+    w.Seed, w.PrivateKey = hd.Seed, key.PrivateKey
+    w.Receive, w.Keys = []string{w.Address}, map[string]string{w.Address: key.PrivateKey}
+    for _, stored := range rec.HDAddresses {
+        key, err := a.deriveHD(w, stored.Change, stored.Index)
+        if err != nil {
+            return nil, err
+        }
+        if key.Address != stored.Address {
+            return nil, fmt.Errorf("stored address %s is not %d/%d of this wallet", stored.Address, stored.Change, stored.Index)
+        }
+        chain := hdChain(w, stored.Change)
+        *chain = append(*chain, key.Address)
+        w.Keys[key.Address] = key.PrivateKey
+    }
+    return w, nil
+}
+
+// walletBalances looks up the balance of each wallet in recs, summed over
+// its addresses, by alias.
+func (a *app) walletBalances(recs []db.WalletRecord) (map[string]models.Amount, error) {
+    var addrs []string
+    for _, rec := range recs {
+        addrs = append(addrs, rec.Addresses()...)
+    }
+    bal, err := api.GetBalances(context.Background(), a.provider, addrs)
+    sums := make(map[string]models.Amount, len(recs))
+    for _, rec := range recs {
+        for _, addr := range rec.Addresses() {
+            sums[rec.Alias] += bal[addr]
+        }
+    }
+    return sums, err
+}
+
+// walletBalance is the balance of w over all its addresses.
+func (a *app) walletBalance(w *wallet.Wallet) (models.Amount, error) {
+    bal, err := api.GetBalances(context.Background(), a.provider, w.Addresses())
+    if err != nil {
+        return 0, err
+    }
+    var total models.Amount
+    for _, b := range bal {
+        total += b
+    }
+    return total, nil
+}
+
+func (a *app) loadWallet(w *wallet.Wallet) {
+    wallets, err := db.ListWallets()
+    if err != nil || len(wallets) == 0 {
+        a.ui.PrintError("No saved wallets found.")
         return
     }
-    bal := make([]float64, len(aliases))
-    for i, alias := range aliases {
-        _, _, addr, _, _ := db.LoadWallet(alias)
-        b, _ := apiClient.GetBalance(addr)
-        bal[i] = float64(b) / 100000000
+    bal, err := a.walletBalances(wallets)
+    if err != nil {
+        a.printAPIError(err)
     }
-    ui.PrintSection("Pick a wallet")
-    for i, alias := range aliases {
-        fmt.Printf("%s[%d]%s %s (%.8f LTC)\n", ui.Blue, i+1, ui.Reset, alias, bal[i])
+    a.ui.PrintSection("Pick a wallet")
+    for i, rec := range wallets {
+        fmt.Fprintf(a.out, "%s[%d]%s %s (%s)\n", ui.Blue, i+1, ui.Reset, rec.Alias, bal[rec.Alias])
     }
-    ui.PrintPrompt("Select wallet by number: ")
-    scanner.Scan()
-    idx, _ := strconv.Atoi(strings.TrimSpace(scanner.Text()))
-    if idx < 1 || idx > len(aliases) {
-        ui.PrintError("Invalid selection.")
+    idx, ok := a.promptChoice("Select wallet by number: ", len(wallets))
+    if !ok {
         return
     }
-    priv, pub, addr, found, err := db.LoadWallet(aliases[idx-1])
+    rec, found, err := db.LoadWallet(wallets[idx].Alias)
     if !found || err != nil {
-        ui.PrintError("Load error.")
+        a.ui.PrintError("Load error.")
         return
     }
-    w.PrivateKey, w.PublicKey, w.Address, w.Alias = priv, pub, addr, aliases[idx-1]
-    ui.PrintSuccess("Loaded wallet '" + w.Alias + "'")
+    loaded, err := a.openWallet(rec)
+    if err != nil {
+        a.ui.PrintError("Load error: " + err.Error())
+        return
+    }
+    *w = *loaded
+    a.ui.PrintSuccess("Loaded wallet '" + w.Alias + "'")
 }
 
-func walletAppMenu(w *wallet.Wallet, apiClient *api.BlockCypherClient, scanner *bufio.Scanner) {
-    ui.PrintBanner()
+func (a *app) walletAppMenu(w *wallet.Wallet) {
+    a.ui.PrintBanner()
+    a.printNetworkNotice()
     shortAddr := w.Address[:6] + "..." + w.Address[len(w.Address)-6:]
     menu := []string{
         fmt.Sprintf("Alias: %s%s%s", ui.Green, w.Alias, ui.Reset),
         fmt.Sprintf("Address: %s%s%s", ui.Yellow, shortAddr, ui.Reset),
-        fmt.Sprintf("Last balance: %s%.8f LTC%s", ui.Blue, lastBalance, ui.Reset),
+        fmt.Sprintf("Last balance: %s%s%s", ui.Blue, a.lastBalance, ui.Reset),
         "",
         "1. Wallet overview",
         "2. Transaction history",
@@ -143,185 +726,948 @@ func walletAppMenu(w *wallet.Wallet, apiClient *api.BlockCypherClient, scanner *
         "10. Save address QR as PNG",
         "11. Vanity address generator",
         "12. Bulk wallet generator",
-        "13. Logout",
+        "13. Coin control",
+        "14. Bump fee of a pending send",
+        "15. Speed up an incoming payment (CPFP)",
+        "16. Batch send from CSV",
+        "17. Logout",
         "0. Exit",
     }
-    ui.PrintMenu("WALLET MENU", menu[3:])
-    ui.PrintPrompt("Select option: ")
-    scanner.Scan()
-    choice := strings.TrimSpace(scanner.Text())
+    addrType, _ := crypto.ParseAddressType(w.AddrType)
+    a.ui.PrintInfo(fmt.Sprintf("%s  %s  [%s]", w.Alias, shortAddr, addrType.Label()))
+    a.ui.PrintMenu("WALLET MENU", menu[3:])
+    a.ui.PrintPrompt("Select option: ")
+    a.in.Scan()
+    choice := strings.TrimSpace(a.in.Text())
     switch choice {
     case "1":
-        walletOverview(w, apiClient)
+        a.walletOverview(w)
     case "2":
-        showTxnHistory(w, apiClient)
+        a.showTxnHistory(w)
     case "3":
-        sendTransaction(w, apiClient, scanner)
+        a.sendTransaction(w)
     case "4":
-        showReceive(w, scanner)
+        a.showReceive(w)
     case "5":
-        moveFunds(w, apiClient, scanner)
+        a.moveFunds(w)
     case "6":
-        changeAlias(w, scanner)
+        a.changeAlias(w)
     case "7":
-        deleteCurrentWallet(w, scanner)
+        a.deleteCurrentWallet(w)
     case "8":
-        resyncBalance(w, apiClient)
+        a.resyncBalance(w)
     case "9":
-        exportTxCSV(w, apiClient)
+        a.exportTxCSV(w)
     case "10":
-        saveAddressQRPNG(w, scanner)
+        a.saveAddressQRPNG(w)
     case "11":
-        vanityGenerator(scanner)
+        a.vanityGenerator()
     case "12":
-        bulkWalletGen(scanner)
+        a.bulkWalletGen()
     case "13":
-        logoutWallet(w)
+        a.coinControl(w)
+    case "14":
+        a.bumpFee(w)
+    case "15":
+        a.speedUpIncoming(w)
+    case "16":
+        a.batchSend(w)
+    case "17":
+        a.logoutWallet(w)
     case "0":
-        ui.PrintInfo("Exiting...")
+        a.ui.PrintInfo("Exiting...")
         os.Exit(0)
     default:
-        ui.PrintError("Invalid choice.")
+        a.ui.PrintError("Invalid choice.")
     }
 }
 
-func walletOverview(w *wallet.Wallet, apiClient *api.BlockCypherClient) {
-    info, err := apiClient.GetAddressInfo(w.Address)
+func (a *app) walletOverview(w *wallet.Wallet) {
+    if len(w.Addresses()) > 1 {
+        a.hdOverview(w)
+        return
+    }
+    info, err := a.provider.GetAddressInfo(context.Background(), w.Address)
     if err != nil {
-        ui.PrintError("API error: " + err.Error())
+        a.printAPIError(err)
+        a.showCachedOverview(w)
         return
     }
-    lastBalance = float64(info.Balance) / 1e8
-    lastSyncTime = time.Now().Format("02 Jan 2006 15:04:05")
-    fmt.Printf("%sWallet alias:%s   %s\n", ui.Cyan, ui.Reset, w.Alias)
-    fmt.Printf("%sAddress:%s       %s\n", ui.Cyan, ui.Reset, w.Address)
-    fmt.Printf("%sBalance:%s       %.8f LTC\n", ui.Cyan, ui.Reset, lastBalance)
-    fmt.Printf("%sTotal received:%s %.8f LTC\n", ui.Cyan, ui.Reset, float64(info.TotalReceived)/1e8)
-    fmt.Printf("%sTotal sent:%s     %.8f LTC\n", ui.Cyan, ui.Reset, float64(info.TotalSent)/1e8)
-    fmt.Printf("%sTx Count:%s       %d\n", ui.Cyan, ui.Reset, info.NTx)
+    a.lastBalance = info.Balance
+    a.lastSyncTime = time.Now().Format("02 Jan 2006 15:04:05")
+    fmt.Fprintf(a.out, "%sWallet alias:%s   %s\n", ui.Cyan, ui.Reset, w.Alias)
+    fmt.Fprintf(a.out, "%sAddress:%s       %s\n", ui.Cyan, ui.Reset, w.Address)
+    fmt.Fprintf(a.out, "%sBalance:%s       %s\n", ui.Cyan, ui.Reset, a.lastBalance)
+    fmt.Fprintf(a.out, "%sTotal received:%s %s\n", ui.Cyan, ui.Reset, info.TotalReceived)
+    fmt.Fprintf(a.out, "%sTotal sent:%s     %s\n", ui.Cyan, ui.Reset, info.TotalSent)
+    fmt.Fprintf(a.out, "%sTx Count:%s       %d\n", ui.Cyan, ui.Reset, info.NTx)
 }
 
-func resyncBalance(w *wallet.Wallet, apiClient *api.BlockCypherClient) {
-    info, err := apiClient.GetAddressInfo(w.Address)
+// hdOverview is the overview of a wallet with several addresses. The
+// provider's per-address totals would count change moving between them as
+// received and sent, so those come from the merged history instead.
+func (a *app) hdOverview(w *wallet.Wallet) {
+    balance, err := a.walletBalance(w)
     if err != nil {
-        ui.PrintError("Failed to sync: " + err.Error())
+        a.printAPIError(err)
+        a.showCachedOverview(w)
+        return
+    }
+    cache, ok := a.syncedHistory(w)
+    if !ok {
         return
     }
-    lastBalance = float64(info.Balance) / 1e8
-    lastSyncTime = time.Now().Format("02 Jan 2006 15:04:05")
-    ui.PrintSuccess(fmt.Sprintf("Synced! Balance now: %.8f LTC", lastBalance))
+    _, received, sent := cacheTotals(cache)
+    a.lastBalance = balance
+    a.lastSyncTime = time.Now().Format("02 Jan 2006 15:04:05")
+    fmt.Fprintf(a.out, "%sWallet alias:%s   %s\n", ui.Cyan, ui.Reset, w.Alias)
+    fmt.Fprintf(a.out, "%sAddress:%s       %s (%d receive, %d change)\n", ui.Cyan, ui.Reset, w.ReceiveAddress(), len(w.Receive), len(w.Change))
+    fmt.Fprintf(a.out, "%sBalance:%s       %s\n", ui.Cyan, ui.Reset, balance)
+    fmt.Fprintf(a.out, "%sTotal received:%s %s\n", ui.Cyan, ui.Reset, received)
+    fmt.Fprintf(a.out, "%sTotal sent:%s     %s\n", ui.Cyan, ui.Reset, sent)
+    fmt.Fprintf(a.out, "%sTx Count:%s       %d\n", ui.Cyan, ui.Reset, len(cache.Txs))
+}
+
+// cacheTotals adds up the net changes in cache.
+func cacheTotals(cache db.TxCache) (balance, received, sent models.Amount) {
+    for _, t := range cache.Txs {
+        balance += t.Value
+        if t.Value > 0 {
+            received += t.Value
+        } else {
+            sent -= t.Value
+        }
+    }
+    return balance, received, sent
 }
 
-func showTxnHistory(w *wallet.Wallet, apiClient *api.BlockCypherClient) {
-    info, err := apiClient.GetAddressInfo(w.Address)
+// cachedHistory is w's transaction cache as stored, merged over its
+// addresses.
+func cachedHistory(w *wallet.Wallet) (db.TxCache, error) {
+    var caches []db.TxCache
+    for _, addr := range w.Addresses() {
+        cache, err := db.LoadTxCache(addr)
+        if err != nil {
+            return cache, err
+        }
+        caches = append(caches, cache)
+    }
+    return db.MergeTxCaches(caches...), nil
+}
+
+// showCachedOverview prints the overview from the transaction cache when the
+// provider cannot be reached.
+func (a *app) showCachedOverview(w *wallet.Wallet) {
+    cache, err := cachedHistory(w)
+    if err != nil || cache.SyncedAt.IsZero() {
+        return
+    }
+    balance, received, sent := cacheTotals(cache)
+    a.ui.PrintInfo("Offline. Figures from the local cache, last synced " + cache.SyncedAt.Local().Format("02 Jan 2006 15:04:05") + ":")
+    fmt.Fprintf(a.out, "%sWallet alias:%s   %s\n", ui.Cyan, ui.Reset, w.Alias)
+    fmt.Fprintf(a.out, "%sAddress:%s       %s\n", ui.Cyan, ui.Reset, w.Address)
+    fmt.Fprintf(a.out, "%sBalance:%s       %s\n", ui.Cyan, ui.Reset, balance)
+    fmt.Fprintf(a.out, "%sTotal received:%s %s\n", ui.Cyan, ui.Reset, received)
+    fmt.Fprintf(a.out, "%sTotal sent:%s     %s\n", ui.Cyan, ui.Reset, sent)
+    fmt.Fprintf(a.out, "%sTx Count:%s       %d\n", ui.Cyan, ui.Reset, len(cache.Txs))
+}
+
+// syncedHistory brings the wallet's transaction cache up to date and
+// returns it, merged over the wallet's addresses. If the provider cannot be
+// reached the cache is used as it is, so history can still be browsed
+// offline; ok is false when there is none.
+func (a *app) syncedHistory(w *wallet.Wallet) (cache db.TxCache, ok bool) {
+    var caches []db.TxCache
+    var res txsync.Result
+    var err error
+    for _, addr := range w.Addresses() {
+        if err != nil {
+            // The provider just failed; use what the rest have cached.
+            c, _ := db.LoadTxCache(addr)
+            caches = append(caches, c)
+            continue
+        }
+        c, r, syncErr := txsync.New(a.provider).Sync(context.Background(), addr)
+        res.Added, res.Updated, res.Removed = res.Added+r.Added, res.Updated+r.Updated, res.Removed+r.Removed
+        caches, err = append(caches, c), syncErr
+    }
+    cache = db.MergeTxCaches(caches...)
+    if err == nil {
+        if res.Added+res.Updated+res.Removed > 0 {
+            a.ui.PrintInfo(fmt.Sprintf("Synced history: %d new, %d updated, %d removed.", res.Added, res.Updated, res.Removed))
+        }
+        return cache, true
+    }
+    a.printAPIError(err)
+    if cache.SyncedAt.IsZero() && len(cache.Txs) == 0 {
+        return cache, false
+    }
+    when := "never fully synced"
+    if !cache.SyncedAt.IsZero() {
+        when = "last synced " + cache.SyncedAt.Local().Format("02 Jan 2006 15:04:05")
+    }
+    a.ui.PrintInfo("Showing the local copy (" + when + ").")
+    return cache, true
+}
+
+func (a *app) resyncBalance(w *wallet.Wallet) {
+    balance, err := a.walletBalance(w)
     if err != nil {
-        ui.PrintError("API error: " + err.Error())
+        a.printAPIError(err)
+        return
+    }
+    a.lastBalance = balance
+    a.lastSyncTime = time.Now().Format("02 Jan 2006 15:04:05")
+    a.ui.PrintSuccess(fmt.Sprintf("Synced! Balance now: %s", a.lastBalance))
+}
+
+// historyPageSize is how many transactions the history screen shows at once.
+const historyPageSize = 10
+
+func (a *app) showTxnHistory(w *wallet.Wallet) {
+    cache, ok := a.syncedHistory(w)
+    if !ok {
         return
     }
-    if len(info.Txrefs) == 0 {
-        fmt.Println("(No transactions found)")
+    if len(cache.Txs) == 0 {
+        fmt.Fprintln(a.out, "(No transactions found)")
         return
     }
-    fmt.Println(ui.Yellow + "Last transactions:")
-    for i, t := range info.Txrefs {
-        fmt.Printf(ui.Blue+" %2d. Time: %v\n     Hash: %s\n     Amount: %.8f LTC\n     Confirmations: %d\n"+ui.Reset,
-            i+1, t.Received, t.Hash, float64(t.Value)/1e8, t.Confirmations)
-        if i >= 9 {
-            break
+    fmt.Fprintln(a.out, ui.Yellow + "Transactions, newest first:")
+    for start := 0; start < len(cache.Txs); start += historyPageSize {
+        if start > 0 {
+            a.ui.PrintPrompt("Enter for more, q to stop: ")
+            a.in.Scan()
+            if strings.ToLower(strings.TrimSpace(a.in.Text())) == "q" {
+                return
+            }
+        }
+        for i, t := range cache.Txs[start:min(start+historyPageSize, len(cache.Txs))] {
+            fmt.Fprintf(a.out, ui.Blue+" %2d. Time: %v\n     Hash: %s\n     Amount: %s\n     Confirmations: %d\n"+ui.Reset,
+                start+i+1, t.Received, t.Txid, t.Value, t.Confirmations(cache.TipHeight))
+            if t.HasDetail && t.Value < 0 {
+                fmt.Fprintf(a.out, ui.Blue+"     Fee: %s\n"+ui.Reset, t.Fee)
+            }
         }
     }
+    a.ui.PrintInfo(fmt.Sprintf("End of history (%d transactions).", len(cache.Txs)))
 }
 
-func sendTransaction(w *wallet.Wallet, apiClient *api.BlockCypherClient, scanner *bufio.Scanner) {
+func (a *app) sendTransaction(w *wallet.Wallet) {
     if w.Address == "" {
-        ui.PrintInfo("Generate or load a wallet first.")
+        a.ui.PrintInfo("Generate or load a wallet first.")
         return
     }
-    ui.PrintPrompt("Recipient address: ")
-    scanner.Scan()
-    toAddress := strings.TrimSpace(scanner.Text())
-    ui.PrintPrompt("Amount (LTC) or type 'all' to send all: ")
-    scanner.Scan()
-    amountStr := strings.TrimSpace(scanner.Text())
-
-    var amount float64
-    var err error
-    var sendAll bool
-    var txHash string
-
-    fee := int64(10000)
+    a.ui.PrintPrompt("Recipient address: ")
+    a.in.Scan()
+    toAddress := strings.TrimSpace(a.in.Text())
+    if _, err := netparams.DecodeAddress(toAddress, a.params); err != nil {
+        a.ui.PrintError("Invalid recipient address: " + err.Error())
+        return
+    }
+    a.ui.PrintPrompt("Amount (LTC, or add a unit: mLTC, µLTC, litoshi) or type 'all' to send all: ")
+    a.in.Scan()
+    amountStr := strings.TrimSpace(a.in.Text())
 
+    req := api.SendRequest{To: toAddress}
     if strings.ToLower(amountStr) == "all" {
-        sendAll = true
-        info, err := apiClient.GetAddressInfo(w.Address)
+        // A sweep: the builder gives the recipient everything left after
+        // the fee for the transaction's actual size, with no change.
+        req.SendAll = true
+        a.ui.PrintPrompt("Include unconfirmed coins? (y/N): ")
+        a.in.Scan()
+        ans := strings.ToLower(strings.TrimSpace(a.in.Text()))
+        req.IncludeUnconfirmed = ans == "y" || ans == "yes"
+    } else {
+        amount, err := models.ParseAmount(amountStr)
         if err != nil {
-            ui.PrintError("API error: " + err.Error())
+            a.ui.PrintError(err.Error())
             return
         }
-        totalBalance := info.Balance
-        if totalBalance <= fee {
-            ui.PrintError("No available balance to send after subtracting fee.")
+        if amount <= 0 {
+            a.ui.PrintError("Invalid amount entered.")
             return
         }
-        amount = float64(totalBalance-fee) / 1e8
-        if amount <= 0 {
-            ui.PrintError("No available balance to send after fees.")
+        req.Amount = amount
+    }
+
+    var ok bool
+    if req.FeePerKB, ok = a.promptFeeRate(); !ok {
+        return
+    }
+    if !req.SendAll {
+        if req.Strategy, ok = a.promptCoinSelection(w); !ok {
             return
         }
+    }
+    txHash, err := a.send(w, req)
+    if err != nil {
+        a.printAPIError(err)
+        return
+    }
+    if txHash == "" {
+        return
+    }
+    a.ui.PrintSuccess("Transaction sent successfully!")
+    if link := explorerTxURL(a.params, txHash); link != "" {
+        fmt.Fprintf(a.out, "Explorer link: %s%s%s\n", ui.Blue, link, ui.Reset)
     } else {
-        amount, err = strconv.ParseFloat(amountStr, 64)
-        if err != nil || amount <= 0 {
-            ui.PrintError("Invalid amount entered.")
+        fmt.Fprintf(a.out, "Tx hash: %s%s%s\n", ui.Blue, txHash, ui.Reset)
+    }
+}
+
+// batchSend pays every row of an address,amount[,label] CSV file in as few
+// transactions as will relay, usually one, so the whole list costs one fee
+// instead of one per payment. The file is refused unless every row is
+// valid. Each payout is recorded with its row label.
+func (a *app) batchSend(w *wallet.Wallet) {
+    ctx := context.Background()
+    a.ui.PrintPrompt("CSV file (address,amount[,label] per line): ")
+    a.in.Scan()
+    fname := strings.TrimSpace(a.in.Text())
+    f, err := os.Open(fname)
+    if err != nil {
+        a.ui.PrintError("Couldn't open: " + err.Error())
+        return
+    }
+    rows, err := batch.ParseCSV(f, a.params)
+    f.Close()
+    if err != nil {
+        for _, line := range strings.Split(err.Error(), "\n") {
+            a.ui.PrintError(line)
+        }
+        a.ui.PrintInfo("Fix the file and try again. Nothing was sent.")
+        return
+    }
+    a.ui.PrintSection(fmt.Sprintf("Batch: %d payments", len(rows)))
+    fmt.Fprintf(a.out, "%s%-5s %-20s %-44s %s%s\n", ui.Cyan, "Line", "Label", "Address", "Amount", ui.Reset)
+    for _, r := range rows {
+        label := r.Label
+        if rs := []rune(label); len(rs) > 20 {
+            label = string(rs[:17]) + "..."
+        }
+        fmt.Fprintf(a.out, "%-5d %-20s %-44s %s\n", r.Line, label, r.Address, r.Amount)
+    }
+    fmt.Fprintf(a.out, "%sTotal:%s %s\n", ui.Cyan, ui.Reset, batch.Total(rows))
+
+    feePerKB, ok := a.promptFeeRate()
+    if !ok {
+        return
+    }
+    strategy, ok := a.promptCoinSelection(w)
+    if !ok {
+        return
+    }
+    coins, err := a.walletCoinControl(w)
+    if err != nil {
+        a.printAPIError(err)
+        return
+    }
+    change, err := a.nextHDAddress(w, crypto.ChainChange)
+    if err != nil {
+        a.printAPIError(err)
+        return
+    }
+    req := api.BatchRequest{
+        Params:    a.params,
+        From:      w.Address,
+        Addresses: w.Addresses(),
+        Payments:  batch.Payments(rows),
+        Coins:     coins,
+        Strategy:  strategy,
+        FeePerKB:  feePerKB,
+    }
+    if change != nil {
+        req.Change = change.Address
+    }
+    drafts, err := api.BuildBatch(ctx, a.provider, req)
+    if err != nil {
+        a.printAPIError(err)
+        return
+    }
+
+    a.ui.PrintSection("Review batch")
+    var fees models.Amount
+    for i, d := range drafts {
+        change := "no change"
+        if d.ChangeIndex >= 0 {
+            change = "change " + d.Change.String()
+        }
+        fmt.Fprintf(a.out, "%sTransaction %d/%d:%s %d payments, %d inputs (%s), fee %s (%s, %d vB), %s\n",
+            ui.Cyan, i+1, len(drafts), ui.Reset, len(d.Payments), len(d.Inputs), d.InputTotal(), d.Fee, api.FormatFeePerVByte(d.FeePerKB), d.VSize, change)
+        fees += d.Fee
+    }
+    if len(drafts) > 1 {
+        a.ui.PrintInfo(fmt.Sprintf("The batch is too large for one transaction and was split into %d.", len(drafts)))
+    }
+    fmt.Fprintf(a.out, "%sPaid:%s     %s to %d recipients\n", ui.Cyan, ui.Reset, batch.Total(rows), len(rows))
+    fmt.Fprintf(a.out, "%sFees:%s     %s\n", ui.Cyan, ui.Reset, fees)
+    if balance, err := a.walletBalance(w); err == nil {
+        fmt.Fprintf(a.out, "%sBalance:%s  %s now, %s after\n", ui.Cyan, ui.Reset, balance, balance-batch.Total(rows)-fees)
+    }
+    a.ui.PrintPrompt("Type 'yes' to sign and broadcast, anything else to cancel: ")
+    a.in.Scan()
+    if strings.ToLower(strings.TrimSpace(a.in.Text())) != "yes" {
+        a.ui.PrintInfo("Batch cancelled. Nothing was signed.")
+        return
+    }
+    for i, d := range drafts {
+        txHash, err := api.SignAndBroadcast(ctx, a.provider, d, w.SigningKeys())
+        if err != nil {
+            a.printAPIError(err)
+            if i > 0 {
+                a.ui.PrintInfo(fmt.Sprintf("Transactions 1-%d were sent; the rest were not. Check the history before sending the remaining rows again.", i))
+            }
             return
         }
-        sendAll = false
+        delete(a.coinSelection, w.Address)
+        a.keepChange(w, d, change)
+        a.recordSent(w, d, txHash, false, "", "")
+        a.ui.PrintSuccess(fmt.Sprintf("Transaction %d/%d sent (%d payments): %s", i+1, len(drafts), len(d.Payments), txHash))
+    }
+}
+
+// promptFeeRate shows the provider's fee estimate and asks for a tier or a
+// custom rate in sat/vB, returned in litoshis per kB. Enter or "normal"
+// returns 0, leaving the normal rate to the send.
+func (a *app) promptFeeRate() (int64, bool) {
+    fees, err := a.provider.EstimateFee(context.Background())
+    if err != nil {
+        a.printAPIError(err)
+        return 0, false
+    }
+    a.ui.PrintSection("Fee rate")
+    for i, t := range api.FeeTiers {
+        fmt.Fprintf(a.out, "%s[%d]%s %-7s %s\n", ui.Blue, i+1, ui.Reset, t, api.FormatFeePerVByte(t.PerKB(fees)))
+    }
+    a.ui.PrintPrompt("Fee (1-3, slow/normal/fast, or a custom sat/vB rate; Enter for normal): ")
+    a.in.Scan()
+    choice := strings.ToLower(strings.TrimSpace(a.in.Text()))
+    for i, t := range api.FeeTiers {
+        if choice == string(t) || choice == strconv.Itoa(i+1) {
+            if t == api.FeeNormal {
+                return 0, true
+            }
+            return t.PerKB(fees), true
+        }
+    }
+    if choice == "" {
+        return 0, true
+    }
+    perKB, err := api.ParseFeePerVByte(choice)
+    if err != nil {
+        a.ui.PrintError(err.Error())
+        return 0, false
+    }
+    if perKB < fees.LowPerKB {
+        a.ui.PrintInfo("That is below the slow estimate (" + api.FormatFeePerVByte(fees.LowPerKB) + "); the transaction may take long to confirm or not relay at all.")
+    }
+    return perKB, true
+}
+
+// promptCoinSelection asks how to pick the inputs of a send, unless coins
+// were already picked on the coin control screen. Enter keeps the default.
+func (a *app) promptCoinSelection(w *wallet.Wallet) (coinselect.Strategy, bool) {
+    if len(a.coinSelection[w.Address]) > 0 {
+        return "", true
+    }
+    names := make([]string, len(coinselect.Strategies))
+    for i, s := range coinselect.Strategies {
+        names[i] = string(s)
+    }
+    a.ui.PrintPrompt("Coin selection (" + strings.Join(names, "/") + ", Enter for auto): ")
+    a.in.Scan()
+    name := strings.ToLower(strings.TrimSpace(a.in.Text()))
+    strategy, err := coinselect.ParseStrategy(name)
+    if err != nil {
+        a.ui.PrintError(err.Error())
+        return "", false
+    }
+    if strategy == coinselect.LeastCost {
+        return "", true
+    }
+    a.ui.PrintInfo("Using " + strategy.Label() + ".")
+    return strategy, true
+}
+
+// send builds req from w, keeping off frozen coins and spending the coin
+// control selection if there is one, shows the full breakdown and only
+// signs and broadcasts once the user confirms. The caller fills in the
+// payment, fee rate and strategy. It returns an empty hash and no error
+// when the user cancels or asks for a dry run, which prints the signed
+// transaction instead of broadcasting it.
+//
+// In "skeleton" mode legacy single-key wallets use the provider's
+// server-side /txs/new flow instead, unless coin control, a strategy or a
+// fee rate is chosen, since the server decides those there. Sweeps, and
+// HD wallets, whose change goes to a new address, are always built here.
+func (a *app) send(w *wallet.Wallet, req api.SendRequest) (string, error) {
+    ctx := context.Background()
+    coins, err := a.walletCoinControl(w)
+    if err != nil {
+        return "", err
+    }
+    if s, ok := a.provider.(api.SkeletonSender); ok && a.sendMode == "skeleton" && w.AddrType == string(crypto.AddrP2PKH) && w.Kind != db.KindHD && coins.Empty() && req.Strategy == "" && req.FeePerKB == 0 && !req.SendAll {
+        a.ui.PrintSection("Review (skeleton mode)")
+        fmt.Fprintf(a.out, "%sTo:%s      %s\n", ui.Cyan, ui.Reset, req.To)
+        fmt.Fprintf(a.out, "%sAmount:%s  %s\n", ui.Cyan, ui.Reset, req.Amount)
+        fmt.Fprintf(a.out, "%sFee:%s     chosen by %s, refused above max_fee\n", ui.Cyan, ui.Reset, a.provider.Name())
+        a.ui.PrintPrompt("Type 'yes' to send (dry runs need send_mode \"local\"): ")
+        a.in.Scan()
+        if strings.ToLower(strings.TrimSpace(a.in.Text())) != "yes" {
+            a.ui.PrintInfo("Send cancelled.")
+            return "", nil
+        }
+        return s.SendTransaction(ctx, w.PrivateKey, w.Address, req.To, req.Amount, false)
+    }
+    if len(coins.Selected) > 0 {
+        a.ui.PrintInfo(fmt.Sprintf("Spending the %d coins selected in coin control.", len(coins.Selected)))
+    }
+    change, err := a.nextHDAddress(w, crypto.ChainChange)
+    if err != nil {
+        return "", err
+    }
+    if change != nil {
+        req.Change = change.Address
+    }
+    req.Params, req.From, req.Addresses, req.Coins = a.params, w.Address, w.Addresses(), coins
+    draft, err := api.BuildSend(ctx, a.provider, req)
+    if err != nil {
+        return "", err
+    }
+    a.printSendReview(w, draft)
+    a.ui.PrintPrompt("Type 'yes' to sign and broadcast, 'dry' to sign and print without broadcasting, anything else to cancel: ")
+    a.in.Scan()
+    switch strings.ToLower(strings.TrimSpace(a.in.Text())) {
+    case "yes":
+    case "dry":
+        if err := draft.SignWith(w.SigningKeys()); err != nil {
+            return "", err
+        }
+        raw, err := draft.RawHex()
+        if err != nil {
+            return "", err
+        }
+        a.ui.PrintSection("Dry run: signed, not broadcast")
+        fmt.Fprintf(a.out, "%sTxid:%s %s\n", ui.Cyan, ui.Reset, draft.Tx.TxHash())
+        fmt.Fprintln(a.out, raw)
+        a.ui.PrintInfo("Nothing was sent. The coins stay unspent until a transaction spending them is broadcast.")
+        return "", nil
+    default:
+        a.ui.PrintInfo("Send cancelled. Nothing was signed.")
+        return "", nil
+    }
+    txHash, err := api.SignAndBroadcast(ctx, a.provider, draft, w.SigningKeys())
+    if err == nil {
+        delete(a.coinSelection, w.Address)
+        a.keepChange(w, draft, change)
+        a.recordSent(w, draft, txHash, req.SendAll, "", "")
+    }
+    return txHash, err
+}
+
+// recordSent stores a broadcast transaction so its fee can be bumped later.
+// parent is set for a child-pays-for-parent child. Failing to store it does
+// not undo the send, so it only warns.
+func (a *app) recordSent(w *wallet.Wallet, d *txbuilder.Draft, txHash string, sendAll bool, replaces, parent string) {
+    raw, _ := d.RawHex()
+    tx := db.SentTx{
+        Txid:     txHash,
+        Address:  w.Address,
+        SendAll:  sendAll,
+        Inputs:   d.Inputs,
+        Fee:      d.Fee,
+        FeePerKB: d.FeePerKB,
+        VSize:    d.VSize,
+        Raw:      raw,
+        Created:  time.Now(),
+        Replaces: replaces,
+        Parent:   parent,
+    }
+    for _, p := range d.Payments {
+        tx.Payments = append(tx.Payments, db.SentPayment{Address: p.Address, Value: p.Value, Label: p.Label})
+    }
+    if err := db.SaveSentTx(tx); err != nil {
+        a.ui.PrintInfo("The transaction was sent but could not be recorded for fee bumping: " + err.Error())
+    }
+}
+
+// bumpFee lists the wallet's sends that are still unconfirmed and replaces
+// the chosen one with the same payment at a higher fee rate, paid out of
+// its change. Every locally built transaction signals replaceability, so
+// nodes accept the replacement in place of the original.
+func (a *app) bumpFee(w *wallet.Wallet) {
+    ctx := context.Background()
+    sent, err := db.LoadSentTxs(w.Address)
+    if err != nil {
+        a.ui.PrintError("Could not load sent transactions: " + err.Error())
+        return
+    }
+    var pending []db.SentTx
+    for _, tx := range sent {
+        // A CPFP child only moves a payment to us back to us; replacing
+        // it would not be a fee bump of anything we sent.
+        if tx.Parent != "" {
+            continue
+        }
+        detail, err := a.provider.GetTransaction(ctx, tx.Txid)
+        if errors.Is(err, api.ErrTxNotFound) {
+            continue
+        }
+        if err != nil {
+            a.printAPIError(err)
+            return
+        }
+        if detail.BlockHeight <= 0 && detail.Confirmations == 0 {
+            pending = append(pending, tx)
+        }
+    }
+    if len(pending) == 0 {
+        a.ui.PrintInfo("No unconfirmed sends from this wallet to bump.")
+        return
+    }
+    a.ui.PrintSection("Pending sends")
+    for i, tx := range pending {
+        fmt.Fprintf(a.out, "%s[%d]%s %s  %s\n", ui.Blue, i+1, ui.Reset, tx.Txid, tx.Created.Local().Format("2006-01-02 15:04"))
+        for _, p := range tx.Payments {
+            if p.Label != "" {
+                fmt.Fprintf(a.out, "     %s to %s (%s)\n", p.Value, p.Address, p.Label)
+            } else {
+                fmt.Fprintf(a.out, "     %s to %s\n", p.Value, p.Address)
+            }
+        }
+        fmt.Fprintf(a.out, "     %sFee:%s %s (%s)\n", ui.Cyan, ui.Reset, tx.Fee, api.FormatFeePerVByte(tx.FeePerKB))
+    }
+    idx, ok := a.promptChoice("Choose: ", len(pending))
+    if !ok {
+        return
+    }
+    orig := pending[idx]
+    // Replacing orig would also evict every send that spends its change,
+    // and BIP 125 would make the replacement pay their fees too. Those
+    // payments would silently never happen, so refuse instead.
+    if kids := db.Descendants(sent, orig.Txid); len(kids) > 0 {
+        a.ui.PrintError(fmt.Sprintf("%d later send(s) spend the change of this transaction; replacing it would cancel them:", len(kids)))
+        for _, kid := range kids {
+            fmt.Fprintf(a.out, "     %s\n", kid.Txid)
+        }
+        a.ui.PrintInfo("Bump the newest of them instead: miners weigh its fee together with this one's.")
+        return
+    }
+    a.ui.PrintInfo("Current fee rate: " + api.FormatFeePerVByte(orig.FeePerKB) + ". The new rate must be higher.")
+    feePerKB, ok := a.promptFeeRate()
+    if !ok {
+        return
+    }
+    change, err := a.nextHDAddress(w, crypto.ChainChange)
+    if err != nil {
+        a.printAPIError(err)
+        return
+    }
+    req := api.BumpRequest{
+        Params:   a.params,
+        From:     w.Address,
+        SendAll:  orig.SendAll,
+        Inputs:   orig.Inputs,
+        Fee:      orig.Fee,
+        FeePerKB: feePerKB,
+    }
+    for _, p := range orig.Payments {
+        req.Payments = append(req.Payments, txbuilder.Payment{Address: p.Address, Value: p.Value, Label: p.Label})
+    }
+    if change != nil {
+        req.Change = change.Address
     }
+    draft, err := api.BuildBump(ctx, a.provider, req)
+    if errors.Is(err, api.ErrInsufficientFunds) {
+        a.ui.PrintError("The change of this transaction is too small to pay that fee rate.")
+        return
+    }
+    if err != nil {
+        a.printAPIError(err)
+        return
+    }
+    a.printSendReview(w, draft)
+    fmt.Fprintf(a.out, "%sReplaces:%s %s (fee %s, +%s)\n", ui.Cyan, ui.Reset, orig.Txid, orig.Fee, draft.Fee-orig.Fee)
+    a.ui.PrintPrompt("Type 'yes' to sign and broadcast the replacement: ")
+    a.in.Scan()
+    if strings.ToLower(strings.TrimSpace(a.in.Text())) != "yes" {
+        a.ui.PrintInfo("Fee bump cancelled. Nothing was signed.")
+        return
+    }
+    txHash, err := api.SignAndBroadcast(ctx, a.provider, draft, w.SigningKeys())
+    if err != nil {
+        a.printAPIError(err)
+        return
+    }
+    a.keepChange(w, draft, change)
+    a.recordSent(w, draft, txHash, orig.SendAll, orig.Txid, "")
+    a.ui.PrintSuccess("Replacement sent. Once it confirms, " + orig.Txid + " can no longer confirm.")
+    if link := explorerTxURL(a.params, txHash); link != "" {
+        fmt.Fprintf(a.out, "Explorer link: %s%s%s\n", ui.Blue, link, ui.Reset)
+    } else {
+        fmt.Fprintf(a.out, "Tx hash: %s%s%s\n", ui.Blue, txHash, ui.Reset)
+    }
+}
 
-    if sendAll {
-        info, _ := apiClient.GetAddressInfo(w.Address)
-        txHash, err = apiClient.SendTransaction(
-            w.PrivateKey, w.Address, toAddress, info.Balance-fee, false,
-        )
+// printSendReview shows everything a built transaction does before it is
+// signed: payments, fee and rate, change, the coins spent and the balance
+// left afterwards.
+func (a *app) printSendReview(w *wallet.Wallet, d *txbuilder.Draft) {
+    a.ui.PrintSection("Review transaction")
+    var paid models.Amount
+    for _, p := range d.Payments {
+        fmt.Fprintf(a.out, "%sTo:%s       %s\n", ui.Cyan, ui.Reset, p.Address)
+        fmt.Fprintf(a.out, "%sAmount:%s   %s\n", ui.Cyan, ui.Reset, p.Value)
+        paid += p.Value
+    }
+    fmt.Fprintf(a.out, "%sFee:%s      %s (%s, %d vB)\n", ui.Cyan, ui.Reset, d.Fee, api.FormatFeePerVByte(d.FeePerKB), d.VSize)
+    if d.ChangeIndex >= 0 {
+        fmt.Fprintf(a.out, "%sChange:%s   %s back to %s\n", ui.Cyan, ui.Reset, d.Change, d.ChangeAddress)
     } else {
-        txHash, err = apiClient.SendTransaction(
-            w.PrivateKey, w.Address, toAddress, int64(amount*1e8), false,
-        )
+        fmt.Fprintf(a.out, "%sChange:%s   none\n", ui.Cyan, ui.Reset)
     }
+    fmt.Fprintf(a.out, "%sInputs:%s   %d, %s\n", ui.Cyan, ui.Reset, len(d.Inputs), d.InputTotal())
+    for _, u := range d.Inputs {
+        fmt.Fprintf(a.out, "          %s  %s\n", u.Outpoint(), u.Value)
+    }
+    balance, err := a.walletBalance(w)
+    if err != nil {
+        return
+    }
+    after := balance - paid - d.Fee
+    for _, p := range d.Payments {
+        if w.Owns(p.Address) {
+            after += p.Value
+        }
+    }
+    fmt.Fprintf(a.out, "%sBalance:%s  %s now, %s after\n", ui.Cyan, ui.Reset, balance, after)
+}
 
+// walletCoinControl combines the coins frozen in the database with the
+// session's selection for w.
+func (a *app) walletCoinControl(w *wallet.Wallet) (api.CoinControl, error) {
+    meta, err := db.LoadCoinMeta(w.Address)
     if err != nil {
-        msg := err.Error()
-        switch {
-        case strings.Contains(msg, "Insufficient funds"):
-            ui.PrintError("Insufficient balance for this transaction.")
-        case strings.Contains(msg, "zero for value"):
-            ui.PrintError("Cannot send zero coins. Enter a valid amount.")
-        case strings.Contains(msg, "Unable to find a transaction to spend"):
-            ui.PrintError("This wallet has no LTC sent to it yet (no UTXOs to spend).")
-        default:
-            ui.PrintError(msg)
+        return api.CoinControl{}, err
+    }
+    coins := api.CoinControl{Frozen: map[string]bool{}, Selected: a.coinSelection[w.Address]}
+    for op, m := range meta {
+        if m.Frozen {
+            coins.Frozen[op] = true
+        }
+    }
+    return coins, nil
+}
+
+// speedUpIncoming lists unconfirmed payments to the wallet and accelerates
+// the chosen one with child-pays-for-parent: its output is spent back to
+// us with a fee that brings parent and child together to the chosen rate.
+// Our own sends are left out; bumpFee replaces those instead.
+func (a *app) speedUpIncoming(w *wallet.Wallet) {
+    ctx := context.Background()
+    utxos, err := api.ListUTXOs(ctx, a.provider, w.Addresses()...)
+    if err != nil {
+        a.printAPIError(err)
+        return
+    }
+    sent, err := db.LoadSentTxs(w.Address)
+    if err != nil {
+        a.ui.PrintError("Could not load sent transactions: " + err.Error())
+        return
+    }
+    ours := map[string]bool{}
+    for _, tx := range sent {
+        ours[tx.Txid] = true
+    }
+    var parents []string
+    received := map[string]models.Amount{}
+    // paidTo is the first of our addresses each parent pays; the child
+    // pays back there.
+    paidTo := map[string]string{}
+    for _, u := range utxos {
+        if u.Confirmations > 0 || ours[u.TxHash] {
+            continue
+        }
+        if _, seen := received[u.TxHash]; !seen {
+            parents = append(parents, u.TxHash)
+            paidTo[u.TxHash] = u.Address
+        }
+        received[u.TxHash] += u.Value
+    }
+    if len(parents) == 0 {
+        a.ui.PrintInfo("No unconfirmed incoming payments to speed up.")
+        return
+    }
+    a.ui.PrintSection("Unconfirmed incoming payments")
+    for i, txid := range parents {
+        rate := "fee unknown"
+        if detail, err := a.provider.GetTransaction(ctx, txid); err == nil {
+            vsize := int64(detail.VSize)
+            if vsize <= 0 {
+                vsize = int64(detail.Size)
+            }
+            if vsize > 0 {
+                rate = fmt.Sprintf("fee %s, %s", detail.Fees, api.FormatFeePerVByte(int64(detail.Fees)*1000/vsize))
+            }
         }
+        fmt.Fprintf(a.out, "%s[%d]%s %s\n     %s to this wallet, %s\n", ui.Blue, i+1, ui.Reset, txid, received[txid], rate)
+    }
+    idx, ok := a.promptChoice("Choose: ", len(parents))
+    if !ok {
         return
     }
-    ui.PrintSuccess("Transaction sent successfully!")
-    fmt.Printf("Explorer link: %shttps://live.blockcypher.com/ltc/tx/%s%s\n",
-        ui.Blue, txHash, ui.Reset)
+    a.ui.PrintInfo("Choose the fee rate for the payment and its child together.")
+    feePerKB, ok := a.promptFeeRate()
+    if !ok {
+        return
+    }
+    coins, err := a.walletCoinControl(w)
+    if err != nil {
+        a.ui.PrintError("Could not load coin control: " + err.Error())
+        return
+    }
+    pkg, err := api.BuildCPFP(ctx, a.provider, api.CPFPRequest{
+        Params:     a.params,
+        Address:    paidTo[parents[idx]],
+        Addresses:  w.Addresses(),
+        ParentTxid: parents[idx],
+        Frozen:     coins.Frozen,
+        FeePerKB:   feePerKB,
+    })
+    if errors.Is(err, api.ErrInsufficientFunds) {
+        a.ui.PrintError("The payment is too small to pay for the whole package at that rate.")
+        return
+    }
+    if err != nil {
+        a.printAPIError(err)
+        return
+    }
+    a.printSendReview(w, pkg.Child)
+    fmt.Fprintf(a.out, "%sParent:%s   %s, %d vB (%s)\n", ui.Cyan, ui.Reset, pkg.ParentFee, pkg.ParentVSize, api.FormatFeePerVByte(pkg.ParentFeePerKB()))
+    fmt.Fprintf(a.out, "%sPackage:%s  %s, %d vB (%s effective)\n", ui.Cyan, ui.Reset, pkg.Fee(), pkg.VSize(), api.FormatFeePerVByte(pkg.FeePerKB()))
+    a.ui.PrintPrompt("Type 'yes' to sign and broadcast the child transaction: ")
+    a.in.Scan()
+    if strings.ToLower(strings.TrimSpace(a.in.Text())) != "yes" {
+        a.ui.PrintInfo("Cancelled. Nothing was signed.")
+        return
+    }
+    txHash, err := api.SignAndBroadcast(ctx, a.provider, pkg.Child, w.SigningKeys())
+    if err != nil {
+        a.printAPIError(err)
+        return
+    }
+    a.recordSent(w, pkg.Child, txHash, true, "", parents[idx])
+    a.ui.PrintSuccess("Child transaction sent: " + txHash)
 }
 
-func showReceive(w *wallet.Wallet, scanner *bufio.Scanner) {
-    ui.PrintSection("Receive Litecoin")
-    ui.PrintInfo("Share your public address or QR below for payments.")
-    fmt.Printf("%sAddress: %s%s%s\n\n", ui.Bold, ui.Yellow, w.Address, ui.Reset)
-    qrterminal.Generate(w.Address, qrterminal.L, os.Stdout)
-    ui.PrintPrompt("Copy address to clipboard (y/N)? ")
-    scanner.Scan()
-    inp := strings.ToLower(strings.TrimSpace(scanner.Text()))
-    if inp == "y" || inp == "c" {
-        copyToClipboard(w.Address)
-        ui.PrintSuccess("Address copied to clipboard (if supported on this OS).")
+// printAPIError renders an error from the provider or the send helpers,
+// matching the api package's error kinds so every screen words them alike.
+func (a *app) printAPIError(err error) {
+    var be *api.BroadcastError
+    switch {
+    case a.printSkeletonError(err), a.printDisagreement(err):
+    case errors.Is(err, api.ErrInsufficientFunds):
+        a.ui.PrintError("Insufficient balance for this transaction (amount plus network fee).")
+    case errors.Is(err, api.ErrOnlyUnconfirmed):
+        a.ui.PrintError("Every coin in this wallet is still unconfirmed. Wait for a confirmation or include unconfirmed coins in the sweep.")
+    case errors.Is(err, api.ErrNoUTXOs):
+        a.ui.PrintError("This wallet has no LTC sent to it yet (no UTXOs to spend).")
+    case errors.Is(err, api.ErrFeeNotHigher):
+        a.ui.PrintError("A replacement must pay more than the original by at least 1 sat/vB of its size. Choose a higher fee rate.")
+        a.ui.PrintInfo("Details: " + err.Error())
+    case errors.Is(err, api.ErrPackageRateMet):
+        a.ui.PrintError("The payment already pays that fee rate on its own. Choose a higher rate, or wait.")
+    case errors.Is(err, api.ErrAlreadyConfirmed):
+        a.ui.PrintInfo("That transaction has confirmed in the meantime. Nothing to do.")
+    case errors.Is(err, api.ErrZeroValue):
+        a.ui.PrintError("Cannot send zero coins. Enter a valid amount.")
+    case errors.As(err, &be):
+        a.ui.PrintError(fmt.Sprintf("%s rejected the transaction. Nothing was sent.", be.Provider))
+        a.ui.PrintInfo("Reason: " + be.Reason)
+    case errors.Is(err, api.ErrRateLimited):
+        msg := "The provider's request limit is used up."
+        if d := api.RetryAfter(err); d > 0 {
+            msg += fmt.Sprintf(" Try again in %s.", d.Round(time.Second))
+        }
+        a.ui.PrintError(msg)
+        a.ui.PrintInfo("Set blockcypher_token or add a second provider to avoid this.")
+    case errors.Is(err, api.ErrNetwork), errors.Is(err, context.DeadlineExceeded):
+        a.ui.PrintError("Could not reach the provider. Check your connection and try again.")
+        a.ui.PrintInfo("Details: " + err.Error())
+    default:
+        a.ui.PrintError(err.Error())
     }
 }
 
-func copyToClipboard(addr string) {
+// printSkeletonError explains a BlockCypher skeleton that was refused before
+// signing. It reports whether err was such a refusal.
+func (a *app) printSkeletonError(err error) bool {
+    var se *api.SkeletonError
+    if !errors.As(err, &se) {
+        return false
+    }
+    a.ui.PrintError("BlockCypher returned a transaction that does not match your request. Nothing was signed or sent.")
+    switch se.Check {
+    case api.CheckFee:
+        a.ui.PrintInfo("The fee is above your ceiling (max_fee / LTC_MAX_FEE): " + se.Detail)
+    case api.CheckSighash:
+        a.ui.PrintInfo("The hashes to sign do not commit to the reviewed outputs: " + se.Detail)
+    default:
+        a.ui.PrintInfo("Reason: " + se.Detail)
+    }
+    a.ui.PrintInfo("Set send_mode to \"local\" to build transactions on this machine instead.")
+    return true
+}
+
+// printDisagreement explains a send refused because two providers reported
+// different coins for the wallet. It reports whether err was such a refusal.
+func (a *app) printDisagreement(err error) bool {
+    var de *api.DisagreementError
+    if !errors.As(err, &de) {
+        return false
+    }
+    a.ui.PrintError(fmt.Sprintf("%s and %s report different coins for this wallet. Nothing was signed or sent.", de.Provider[0], de.Provider[1]))
+    a.ui.PrintInfo(fmt.Sprintf("%s: %s, %s: %s — %s.", de.Provider[0], de.Balance[0], de.Provider[1], de.Balance[1], de.Detail))
+    a.ui.PrintInfo("One backend may be out of sync or lying. Wait a few minutes and try again.")
+    return true
+}
+
+// showReceive shows the address to be paid at. An HD wallet can hand out
+// a new one, the next on its receive chain, so payers cannot link their
+// payments to each other.
+func (a *app) showReceive(w *wallet.Wallet) {
+    a.ui.PrintSection("Receive Litecoin")
+    a.ui.PrintInfo("Share your public address or QR below for payments.")
+    for {
+        fmt.Fprintf(a.out, "%sAddress: %s%s%s\n\n", ui.Bold, ui.Yellow, w.ReceiveAddress(), ui.Reset)
+        qrterminal.Generate(w.ReceiveAddress(), qrterminal.L, a.out)
+        if w.Kind == db.KindHD {
+            a.ui.PrintPrompt("Copy address to clipboard (y/N), or 'new' for a new address? ")
+        } else {
+            a.ui.PrintPrompt("Copy address to clipboard (y/N)? ")
+        }
+        a.in.Scan()
+        inp := strings.ToLower(strings.TrimSpace(a.in.Text()))
+        if inp == "new" && w.Kind == db.KindHD {
+            key, err := a.nextHDAddress(w, crypto.ChainReceive)
+            if err != nil {
+                a.ui.PrintError("Failed to derive address: " + err.Error())
+                return
+            }
+            a.addHDAddress(w, crypto.ChainReceive, key)
+            a.ui.PrintInfo(fmt.Sprintf("New address #%d. Earlier ones keep working.", len(w.Receive)))
+            continue
+        }
+        if inp == "y" || inp == "c" {
+            a.copyToClipboard(w.ReceiveAddress())
+            a.ui.PrintSuccess("Address copied to clipboard (if supported on this OS).")
+        }
+        return
+    }
+}
+
+func (a *app) copyToClipboard(addr string) {
     if _, err := exec.LookPath("pbcopy"); err == nil {
         c := exec.Command("pbcopy")
         c.Stdin = strings.NewReader(addr)
@@ -348,168 +1694,279 @@ func copyToClipboard(addr string) {
     }
 }
 
-func moveFunds(w *wallet.Wallet, apiClient *api.BlockCypherClient, scanner *bufio.Scanner) {
-    aliases, err := db.ListWalletAliases()
-    if err != nil || len(aliases) == 0 {
-        ui.PrintError("No saved wallets found.")
+func (a *app) moveFunds(w *wallet.Wallet) {
+    wallets, err := db.ListWallets()
+    if err != nil || len(wallets) == 0 {
+        a.ui.PrintError("No saved wallets found.")
         return
     }
-    var targets []string
-    for _, alias := range aliases {
-        _, _, addr, found, _ := db.LoadWallet(alias)
-        if found && addr != w.Address {
-            targets = append(targets, alias)
+    var targets []db.WalletRecord
+    for _, rec := range wallets {
+        if rec.Address != w.Address {
+            targets = append(targets, rec)
         }
     }
     if len(targets) == 0 {
-        ui.PrintError("No destination wallets available.")
+        a.ui.PrintError("No destination wallets available.")
         return
     }
-    ui.PrintSection("Select destination wallet:")
-    for i, a := range targets {
-        _, _, addr, _, _ := db.LoadWallet(a)
-        bal, _ := apiClient.GetBalance(addr)
-        fmt.Printf("%s[%d]%s %s (%.8f LTC)\n", ui.Blue, i+1, ui.Reset, a, float64(bal)/1e8)
+    a.ui.PrintSection("Select destination wallet:")
+    bal, _ := a.walletBalances(targets)
+    for i, rec := range targets {
+        fmt.Fprintf(a.out, "%s[%d]%s %s (%s)\n", ui.Blue, i+1, ui.Reset, rec.Alias, bal[rec.Alias])
+    }
+    idx, ok := a.promptChoice("Choose: ", len(targets))
+    if !ok {
+        return
     }
-    ui.PrintPrompt("Choose: ")
-    scanner.Scan()
-    idx, _ := strconv.Atoi(strings.TrimSpace(scanner.Text()))
-    if idx < 1 || idx > len(targets) {
-        ui.PrintError("Invalid selection.")
+    destAddr := targets[idx].Address
+    a.ui.PrintPrompt("Amount (LTC, or add a unit: mLTC, µLTC, litoshi): ")
+    a.in.Scan()
+    amt, err := models.ParseAmount(a.in.Text())
+    if err != nil {
+        a.ui.PrintError(err.Error())
         return
     }
-    _, _, destAddr, _, _ := db.LoadWallet(targets[idx-1])
-    ui.PrintPrompt("Amount (LTC): ")
-    scanner.Scan()
-    amtStr := strings.TrimSpace(scanner.Text())
-    amt, _ := strconv.ParseFloat(amtStr, 64)
     if amt <= 0 {
-        ui.PrintError("Invalid amount.")
+        a.ui.PrintError("Invalid amount.")
         return
     }
-    txHash, err := apiClient.SendTransaction(w.PrivateKey, w.Address, destAddr, int64(amt*100000000), false)
+    txHash, err := a.send(w, api.SendRequest{To: destAddr, Amount: amt})
     if err != nil {
-        ui.PrintError(err.Error())
+        a.printAPIError(err)
+        return
+    }
+    if txHash == "" {
         return
     }
-    ui.PrintSuccess("Funds moved. Tx hash: " + txHash)
+    a.ui.PrintSuccess("Funds moved. Tx hash: " + txHash)
 }
 
-func changeAlias(w *wallet.Wallet, scanner *bufio.Scanner) {
-    ui.PrintPrompt("Enter new alias for this wallet (current: " + w.Alias + "): ")
-    scanner.Scan()
-    newAlias := strings.TrimSpace(scanner.Text())
+func (a *app) changeAlias(w *wallet.Wallet) {
+    a.ui.PrintPrompt("Enter new alias for this wallet (current: " + w.Alias + "): ")
+    a.in.Scan()
+    newAlias := strings.TrimSpace(a.in.Text())
     if newAlias == "" {
-        ui.PrintInfo("No changes made.")
+        a.ui.PrintInfo("No changes made.")
         return
     }
-    err := db.SaveWallet(newAlias, w.PrivateKey, w.PublicKey, w.Address)
+    oldAlias := w.Alias
+    w.Alias = newAlias
+    err := a.saveWallet(w)
     if err == nil {
-        _ = db.DeleteWallet(w.Alias)
-        w.Alias = newAlias
-        ui.PrintSuccess("Alias changed to: " + newAlias)
+        _ = db.DeleteWallet(oldAlias)
+        a.ui.PrintSuccess("Alias changed to: " + newAlias)
     } else {
-        ui.PrintError("Failed to change alias.")
+        w.Alias = oldAlias
+        a.ui.PrintError("Failed to change alias.")
     }
 }
 
-func deleteCurrentWallet(w *wallet.Wallet, scanner *bufio.Scanner) {
-    ui.PrintPrompt("Are you sure you want to delete this wallet, type its alias (" + w.Alias + ") to confirm: ")
-    scanner.Scan()
-    conf := strings.TrimSpace(scanner.Text())
+func (a *app) deleteCurrentWallet(w *wallet.Wallet) {
+    a.ui.PrintPrompt("Are you sure you want to delete this wallet, type its alias (" + w.Alias + ") to confirm: ")
+    a.in.Scan()
+    conf := strings.TrimSpace(a.in.Text())
     if conf != w.Alias {
-        ui.PrintError("Wallet deletion cancelled.")
+        a.ui.PrintError("Wallet deletion cancelled.")
         return
     }
     _ = db.DeleteWallet(w.Alias)
-    w.PrivateKey, w.PublicKey, w.Address, w.Alias = "", "", "", ""
-    ui.PrintSuccess("Wallet deleted.")
+    *w = wallet.Wallet{}
+    a.ui.PrintSuccess("Wallet deleted.")
 }
 
-func logoutWallet(w *wallet.Wallet) {
-    w.PrivateKey, w.PublicKey, w.Address, w.Alias = "", "", "", ""
-    ui.PrintInfo("Logged out of wallet session. Returning to main screen.")
+func (a *app) logoutWallet(w *wallet.Wallet) {
+    delete(a.coinSelection, w.Address)
+    *w = wallet.Wallet{}
+    a.ui.PrintInfo("Logged out of wallet session. Returning to main screen.")
 }
 
-
-func exportTxCSV(w *wallet.Wallet, apiClient *api.BlockCypherClient) {
-    info, err := apiClient.GetAddressInfo(w.Address)
+// coinControl lists the wallet's unspent outputs and lets the user pick the
+// ones the next send spends, freeze coins so no send touches them, and
+// label them. Freezes and labels are stored; the selection lasts until the
+// next successful send or logout.
+func (a *app) coinControl(w *wallet.Wallet) {
+    utxos, err := api.ListUTXOs(context.Background(), a.provider, w.Addresses()...)
     if err != nil {
-        ui.PrintError("API error: " + err.Error())
+        a.printAPIError(err)
+        return
+    }
+    if len(utxos) == 0 {
+        a.ui.PrintInfo("This wallet has no unspent outputs.")
+        return
+    }
+    for {
+        meta, err := db.LoadCoinMeta(w.Address)
+        if err != nil {
+            a.ui.PrintError("Could not load coin control: " + err.Error())
+            return
+        }
+        selected := map[string]bool{}
+        for _, op := range a.coinSelection[w.Address] {
+            selected[op] = true
+        }
+        a.ui.PrintSection("Coin control")
+        var total, spendable, picked models.Amount
+        for i, u := range utxos {
+            m := meta[u.Outpoint()]
+            flags := ""
+            if m.Frozen {
+                flags += ui.Red + " [frozen]" + ui.Reset
+            } else {
+                spendable += u.Value
+            }
+            if selected[u.Outpoint()] {
+                flags += ui.Green + " [selected]" + ui.Reset
+                picked += u.Value
+            }
+            total += u.Value
+            fmt.Fprintf(a.out, "%s[%d]%s %s\n     %s, %d confirmations%s\n", ui.Blue, i+1, ui.Reset, u.Outpoint(), u.Value, u.Confirmations, flags)
+            if len(w.Addresses()) > 1 {
+                fmt.Fprintf(a.out, "     %sAddress:%s %s\n", ui.Cyan, ui.Reset, u.Address)
+            }
+            if m.Label != "" {
+                fmt.Fprintf(a.out, "     %sLabel:%s %s\n", ui.Cyan, ui.Reset, m.Label)
+            }
+        }
+        fmt.Fprintf(a.out, "%sTotal:%s %s, spendable %s", ui.Cyan, ui.Reset, total, spendable)
+        if len(selected) > 0 {
+            fmt.Fprintf(a.out, ", selected %s", picked)
+        }
+        fmt.Fprintln(a.out)
+        a.ui.PrintPrompt("s N select/unselect, f N freeze/unfreeze, l N TEXT label, c clear selection, q back: ")
+        if !a.in.Scan() {
+            return
+        }
+        fields := strings.Fields(a.in.Text())
+        if len(fields) == 0 {
+            continue
+        }
+        cmd := strings.ToLower(fields[0])
+        if cmd == "q" {
+            return
+        }
+        if cmd == "c" {
+            delete(a.coinSelection, w.Address)
+            a.ui.PrintInfo("Selection cleared; sends pick coins automatically.")
+            continue
+        }
+        if len(fields) < 2 {
+            a.ui.PrintError("Give the number of a coin, e.g. 's 2'.")
+            continue
+        }
+        n, err := strconv.Atoi(fields[1])
+        if err != nil || n < 1 || n > len(utxos) {
+            a.ui.PrintError("Invalid coin number.")
+            continue
+        }
+        op := utxos[n-1].Outpoint()
+        switch cmd {
+        case "s":
+            if meta[op].Frozen {
+                a.ui.PrintError("That coin is frozen. Unfreeze it first.")
+            } else if selected[op] {
+                a.coinSelection[w.Address] = slices.DeleteFunc(a.coinSelection[w.Address], func(s string) bool { return s == op })
+            } else {
+                a.coinSelection[w.Address] = append(a.coinSelection[w.Address], op)
+            }
+        case "f":
+            frozen := !meta[op].Frozen
+            if err := db.SetCoinFrozen(w.Address, op, frozen); err != nil {
+                a.ui.PrintError("Could not save: " + err.Error())
+            } else if frozen && selected[op] {
+                a.coinSelection[w.Address] = slices.DeleteFunc(a.coinSelection[w.Address], func(s string) bool { return s == op })
+            }
+        case "l":
+            label := strings.Join(fields[2:], " ")
+            if err := db.SetCoinLabel(w.Address, op, label); err != nil {
+                a.ui.PrintError("Could not save: " + err.Error())
+            }
+        default:
+            a.ui.PrintError("Unknown command.")
+        }
+    }
+}
+
+
+func (a *app) exportTxCSV(w *wallet.Wallet) {
+    cache, ok := a.syncedHistory(w)
+    if !ok {
         return
     }
     fn := fmt.Sprintf("%s_%s.csv", w.Alias, time.Now().Format("20060102_150405"))
     f, err := os.Create(fn)
     if err != nil {
-        ui.PrintError("Failed to create export file.")
+        a.ui.PrintError("Failed to create export file.")
         return
     }
     defer f.Close()
     wtr := csv.NewWriter(f)
-    wtr.Write([]string{"Time", "TxHash", "Value", "Confirmations"})
-    for _, t := range info.Txrefs {
+    wtr.Write([]string{"Time", "TxHash", "Value", "Confirmations", "Fee"})
+    for _, t := range cache.Txs {
+        fee := ""
+        if t.HasDetail {
+            fee = t.Fee.In(models.UnitLTC)
+        }
         wtr.Write([]string{
             t.Received,
-            t.Hash,
-            fmt.Sprintf("%.8f", float64(t.Value)/1e8),
-            strconv.Itoa(t.Confirmations),
+            t.Txid,
+            t.Value.In(models.UnitLTC),
+            strconv.Itoa(t.Confirmations(cache.TipHeight)),
+            fee,
         })
     }
     wtr.Flush()
-    ui.PrintSuccess("Exported to: " + fn)
+    a.ui.PrintSuccess(fmt.Sprintf("Exported %d transactions to: %s", len(cache.Txs), fn))
 }
 
-func saveAddressQRPNG(w *wallet.Wallet, scanner *bufio.Scanner) {
-    ui.PrintPrompt("Enter PNG filename (default: address.png): ")
-    scanner.Scan()
-    fname := strings.TrimSpace(scanner.Text())
+func (a *app) saveAddressQRPNG(w *wallet.Wallet) {
+    a.ui.PrintPrompt("Enter PNG filename (default: address.png): ")
+    a.in.Scan()
+    fname := strings.TrimSpace(a.in.Text())
     if fname == "" {
         fname = "address.png"
     }
-    err := qrcode.WriteFile(w.Address, qrcode.Medium, 256, fname)
+    err := qrcode.WriteFile(w.ReceiveAddress(), qrcode.Medium, 256, fname)
     if err != nil {
-        ui.PrintError("Couldn't save: " + err.Error())
+        a.ui.PrintError("Couldn't save: " + err.Error())
     } else {
-        ui.PrintSuccess("QR PNG saved as: " + fname)
+        a.ui.PrintSuccess("QR PNG saved as: " + fname)
     }
 }
 
 
-func vanityGenerator(scanner *bufio.Scanner) {
-    ui.PrintPrompt("Enter a prefix to search for (e.g. lt, L, etc): ")
-    scanner.Scan()
-    prefix := strings.TrimSpace(scanner.Text())
+func (a *app) vanityGenerator() {
+    a.ui.PrintPrompt("Enter a prefix to search for (e.g. lt, L, etc): ")
+    a.in.Scan()
+    prefix := strings.TrimSpace(a.in.Text())
     if prefix == "" {
-        ui.PrintError("Invalid prefix")
+        a.ui.PrintError("Invalid prefix")
         return
     }
     t0 := time.Now()
     for i := 1; ; i++ {
-        lw, _ := crypto.GenerateLitecoinWallet()
+        lw, _ := crypto.GenerateLitecoinWallet(a.params, crypto.AddrP2PKH)
         if strings.HasPrefix(strings.ToLower(lw.Address), strings.ToLower(prefix)) {
-            fmt.Printf("Found: %s\nPrivate: %s\n", lw.Address, lw.PrivateKey)
+            fmt.Fprintf(a.out, "Found: %s\nPrivate: %s\n", lw.Address, lw.PrivateKey)
             break
         }
         if i%10000 == 0 && time.Since(t0) > 10*time.Second {
-            ui.PrintError("Stopping after 10s, not found.")
+            a.ui.PrintError("Stopping after 10s, not found.")
             break
         }
     }
 }
 
-func bulkWalletGen(scanner *bufio.Scanner) {
-    ui.PrintPrompt("How many wallets? ")
-    scanner.Scan()
-    n, _ := strconv.Atoi(strings.TrimSpace(scanner.Text()))
-    if n <= 0 {
-        ui.PrintError("Number too low.")
+func (a *app) bulkWalletGen() {
+    n, ok := a.promptNumber("How many wallets? (Enter to cancel) ", 1, 1000, 0)
+    if !ok || n == 0 {
         return
     }
     for i := 0; i < n; i++ {
-        lw, _ := crypto.GenerateLitecoinWallet()
+        lw, _ := crypto.GenerateLitecoinWallet(a.params, crypto.AddrP2PKH)
         alias := fmt.Sprintf("Bulk%d", i+1)
-        db.SaveWallet(alias, lw.PrivateKey, lw.PublicKey, lw.Address)
-        fmt.Printf("[%d] %s - %s\n", i+1, lw.Address, lw.PrivateKey)
+        db.SaveWallet(db.WalletRecord{Alias: alias, Kind: db.KindKey, AddrType: string(lw.AddressType), Private: lw.PrivateKey, Public: lw.PublicKey, Address: lw.Address})
+        fmt.Fprintf(a.out, "[%d] %s - %s\n", i+1, lw.Address, lw.PrivateKey)
     }
-    ui.PrintSuccess("Bulk wallets generated!")
+    a.ui.PrintSuccess("Bulk wallets generated!")
 }
\ No newline at end of file
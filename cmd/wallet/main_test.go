@@ -0,0 +1,254 @@
+package main
+
+import (
+    "bytes"
+    "encoding/hex"
+    "os"
+    "strings"
+    "testing"
+    "time"
+
+    "litecoin-wallet/internal/api"
+    "litecoin-wallet/internal/crypto"
+    "litecoin-wallet/internal/db"
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "litecoin-wallet/internal/wallet"
+    "github.com/btcsuite/btcd/txscript"
+)
+
+const (
+    testKey       = "0000000000000000000000000000000000000000000000000000000000000001"
+    testRecipient = "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ"
+)
+
+// testApp runs the app against a MemoryProvider holding one 0.5 LTC coin
+// for the returned wallet, with a throwaway database and input as the
+// user's answers.
+func testApp(t *testing.T, input string) (*app, *api.MemoryProvider, *wallet.Wallet, *bytes.Buffer) {
+    t.Helper()
+    wd, _ := os.Getwd()
+    if err := os.Chdir(t.TempDir()); err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() {
+        os.Chdir(wd)
+        db.Lock()
+    })
+    db.SetPassphrasePrompt(func(bool) (string, error) { return "test passphrase", nil })
+
+    params := &netparams.MainNetParams
+    lw, err := crypto.LoadLitecoinWallet(params, testKey, crypto.AddrP2WPKH)
+    if err != nil {
+        t.Fatal(err)
+    }
+    addr, _ := netparams.DecodeAddress(lw.Address, params)
+    script, _ := txscript.PayToAddrScript(addr)
+    m := api.NewMemoryProvider()
+    m.Addresses[lw.Address] = models.AddressOverview{Balance: 50_000_000, TotalReceived: 50_000_000, NTx: 1}
+    m.UTXOs[lw.Address] = []models.UTXO{{
+        TxHash:        hex.EncodeToString([]byte{31: 1}),
+        Value:         50_000_000,
+        Confirmations: 6,
+        Script:        hex.EncodeToString(script),
+    }}
+    w := &wallet.Wallet{
+        Alias: "test", Kind: db.KindKey, AddrType: string(crypto.AddrP2WPKH),
+        PrivateKey: lw.PrivateKey, PublicKey: lw.PublicKey, Address: lw.Address,
+    }
+    var out bytes.Buffer
+    return newApp(m, params, strings.NewReader(input), &out), m, w, &out
+}
+
+func TestWalletOverviewShowsBalance(t *testing.T) {
+    a, _, w, out := testApp(t, "")
+    a.walletOverview(w)
+    if a.lastBalance != 50_000_000 {
+        t.Errorf("lastBalance = %s, want 0.5 LTC", a.lastBalance)
+    }
+    if !strings.Contains(out.String(), "0.50000000 LTC") {
+        t.Errorf("balance not shown:\n%s", out)
+    }
+}
+
+func TestSendTransaction(t *testing.T) {
+    // recipient, amount, fee (normal), coin selection (auto), confirm
+    a, m, w, out := testApp(t, testRecipient+"\n0.1\n\n\nyes\n")
+    a.sendTransaction(w)
+    if len(m.Broadcasts) != 1 {
+        t.Fatalf("broadcast %d transactions, want 1:\n%s", len(m.Broadcasts), out)
+    }
+    tx := m.Broadcasts[0]
+    if tx.TxOut[0].Value != 10_000_000 {
+        t.Errorf("paid %d litoshis, want 10000000", tx.TxOut[0].Value)
+    }
+    if !strings.Contains(out.String(), "Transaction sent successfully") {
+        t.Errorf("no success message:\n%s", out)
+    }
+    sent, err := db.LoadSentTxs(w.Address)
+    if err != nil || len(sent) != 1 || sent[0].Txid != tx.TxHash().String() {
+        t.Errorf("recorded %+v, %v; want the broadcast transaction", sent, err)
+    }
+}
+
+func TestSendTransactionCancelAndDryRun(t *testing.T) {
+    a, m, w, out := testApp(t, testRecipient+"\n0.1\n\n\nno\n")
+    a.sendTransaction(w)
+    if len(m.Broadcasts) != 0 || !strings.Contains(out.String(), "Send cancelled") {
+        t.Errorf("cancelled send broadcast %d transactions:\n%s", len(m.Broadcasts), out)
+    }
+
+    a, m, w, out = testApp(t, testRecipient+"\n0.1\n\n\ndry\n")
+    a.sendTransaction(w)
+    if len(m.Broadcasts) != 0 || !strings.Contains(out.String(), "Dry run") {
+        t.Errorf("dry run broadcast %d transactions:\n%s", len(m.Broadcasts), out)
+    }
+}
+
+func TestSendTransactionRejectsBadInput(t *testing.T) {
+    a, m, w, out := testApp(t, "not-an-address\n")
+    a.sendTransaction(w)
+    if len(m.Broadcasts) != 0 || !strings.Contains(out.String(), "Invalid recipient address") {
+        t.Errorf("bad address: %d broadcasts:\n%s", len(m.Broadcasts), out)
+    }
+
+    a, m, w, out = testApp(t, testRecipient+"\n5\n\n\nyes\n")
+    a.sendTransaction(w)
+    if len(m.Broadcasts) != 0 || !strings.Contains(out.String(), "Insufficient balance") {
+        t.Errorf("overspend: %d broadcasts:\n%s", len(m.Broadcasts), out)
+    }
+}
+
+func TestBumpFeeRefusesWithDescendants(t *testing.T) {
+    // pending lists newest first, so 2 is the original.
+    a, m, w, out := testApp(t, "2\n")
+    orig := db.SentTx{Txid: "orig", Address: w.Address, Created: time.Unix(1, 0), Inputs: m.UTXOs[w.Address]}
+    child := db.SentTx{Txid: "child", Address: w.Address, Created: time.Unix(2, 0), Inputs: []models.UTXO{{TxHash: "orig", Value: 40_000_000}}}
+    for _, tx := range []db.SentTx{orig, child} {
+        if err := db.SaveSentTx(tx); err != nil {
+            t.Fatal(err)
+        }
+        m.Txs[tx.Txid] = models.TxDetail{Hash: tx.Txid}
+    }
+    a.bumpFee(w)
+    if len(m.Broadcasts) != 0 || !strings.Contains(out.String(), "replacing it would cancel them") {
+        t.Errorf("bumped a transaction with descendants:\n%s", out)
+    }
+}
+
+func TestSpeedUpIncomingIsNotOfferedForBump(t *testing.T) {
+    // parent, fee rate (normal), confirm
+    a, m, w, out := testApp(t, "1\n\nyes\n")
+    coin := &m.UTXOs[w.Address][0]
+    coin.Confirmations = 0
+    m.Txs[coin.TxHash] = models.TxDetail{Hash: coin.TxHash, Fees: 250, VSize: 250}
+    a.speedUpIncoming(w)
+    if len(m.Broadcasts) != 1 {
+        t.Fatalf("broadcast %d transactions, want the child:\n%s", len(m.Broadcasts), out)
+    }
+    child := m.Broadcasts[0].TxHash().String()
+    sent, err := db.LoadSentTxs(w.Address)
+    if err != nil || len(sent) != 1 || sent[0].Txid != child || sent[0].Parent != coin.TxHash {
+        t.Fatalf("recorded %+v, %v; want the child with its parent", sent, err)
+    }
+
+    m.Txs[child] = models.TxDetail{Hash: child}
+    out.Reset()
+    a.bumpFee(w)
+    if !strings.Contains(out.String(), "No unconfirmed sends") {
+        t.Errorf("CPFP child offered for a fee bump:\n%s", out)
+    }
+}
+
+func TestMoveFundsToAnotherWallet(t *testing.T) {
+    // destination, amount, confirm
+    a, m, w, out := testApp(t, "1\n0.1\nyes\n")
+    dest, err := crypto.LoadLitecoinWallet(a.params, "0000000000000000000000000000000000000000000000000000000000000002", crypto.AddrP2WPKH)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, rec := range []db.WalletRecord{
+        {Alias: "test", Private: testKey, Address: w.Address},
+        {Alias: "savings", Private: dest.PrivateKey, Address: dest.Address},
+    } {
+        if err := db.SaveWallet(rec); err != nil {
+            t.Fatal(err)
+        }
+    }
+    a.moveFunds(w)
+    if len(m.Broadcasts) != 1 {
+        t.Fatalf("broadcast %d transactions, want 1:\n%s", len(m.Broadcasts), out)
+    }
+    addr, _ := netparams.DecodeAddress(dest.Address, a.params)
+    script, _ := txscript.PayToAddrScript(addr)
+    if got := m.Broadcasts[0].TxOut[0]; got.Value != 10_000_000 || !bytes.Equal(got.PkScript, script) {
+        t.Errorf("paid %d to %x, want 0.1 LTC to savings", got.Value, got.PkScript)
+    }
+}
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestHDWalletSpendsEveryAddressAndHandsOutNewOnes(t *testing.T) {
+    // Restore: phrase, passphrase, address type, account, alias, save.
+    // Send: recipient, amount, fee, coin selection, confirm. Then ask the
+    // receive screen for a new address.
+    a, m, w, out := testApp(t, testMnemonic+"\n\n\n\nhd\ny\n"+testRecipient+"\n0.7\n\n\nyes\nnew\n\n")
+    hd, err := crypto.RestoreHDWallet(a.params, testMnemonic, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    derive := func(change, index uint32) *crypto.LitecoinWallet {
+        key, err := hd.Derive(crypto.AddrP2WPKH, 0, change, index)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return key
+    }
+    // Coins on receive addresses 0 and 2; 1 was handed out but never paid.
+    for i, key := range []*crypto.LitecoinWallet{derive(0, 0), derive(0, 2)} {
+        m.Addresses[key.Address] = models.AddressOverview{Balance: 50_000_000, NTx: 1}
+        m.UTXOs[key.Address] = []models.UTXO{{TxHash: hex.EncodeToString([]byte{30: 7, 31: byte(i)}), Value: 50_000_000, Confirmations: 6}}
+    }
+
+    a.restoreWallet(w)
+    if len(w.Receive) != 3 || len(w.Change) != 0 {
+        t.Fatalf("restored %d receive and %d change addresses, want 3 and 0:\n%s", len(w.Receive), len(w.Change), out)
+    }
+
+    a.sendTransaction(w)
+    if len(m.Broadcasts) != 1 {
+        t.Fatalf("broadcast %d transactions, want 1:\n%s", len(m.Broadcasts), out)
+    }
+    tx := m.Broadcasts[0]
+    change := derive(crypto.ChainChange, 0)
+    addr, _ := netparams.DecodeAddress(change.Address, a.params)
+    script, _ := txscript.PayToAddrScript(addr)
+    if len(tx.TxIn) != 2 || len(tx.TxOut) != 2 || !bytes.Equal(tx.TxOut[1].PkScript, script) {
+        t.Errorf("spent %d inputs into %d outputs; want both coins and change to %s", len(tx.TxIn), len(tx.TxOut), change.Address)
+    }
+    if len(w.Change) != 1 || w.Change[0] != change.Address {
+        t.Errorf("change addresses = %v, want [%s]", w.Change, change.Address)
+    }
+
+    a.showReceive(w)
+    if next := derive(0, 3).Address; w.ReceiveAddress() != next || !strings.Contains(out.String(), next) {
+        t.Errorf("new receive address %s, want %s", w.ReceiveAddress(), next)
+    }
+
+    // Everything handed out is watched again after a restart.
+    recs, err := db.ListWallets()
+    if err != nil || len(recs) != 1 {
+        t.Fatalf("ListWallets = %+v, %v", recs, err)
+    }
+    rec, _, err := db.LoadWallet(recs[0].Alias)
+    if err != nil {
+        t.Fatal(err)
+    }
+    reopened, err := a.openWallet(rec)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got, want := strings.Join(reopened.Addresses(), " "), strings.Join(w.Addresses(), " "); got != want {
+        t.Errorf("reopened addresses %s, want %s", got, want)
+    }
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+    "context"
+    "fmt"
+
+    "litecoin-wallet/internal/coinselect"
+    "litecoin-wallet/internal/crypto"
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "litecoin-wallet/internal/txbuilder"
+    "github.com/btcsuite/btcd/chaincfg"
+)
+
+// BatchRequest pays many recipients from our addresses.
+type BatchRequest struct {
+    Params   *chaincfg.Params
+    From     string
+    // Addresses and Change are as in SendRequest. Every part of a split
+    // batch sends its change to the same address.
+    Addresses []string
+    Change    string
+    Payments []txbuilder.Payment
+    // Coins keeps frozen coins out. Coins picked by hand are all spent, as
+    // in BuildSend; if the batch is split, the last part takes whatever the
+    // others left of them.
+    Coins    CoinControl
+    // Strategy picks the inputs when none were selected by hand, and those
+    // of every part but the last when a hand-picked batch is split.
+    Strategy coinselect.Strategy
+    // FeePerKB is the fee rate in litoshis per kB; 0 means the provider's
+    // FeeNormal estimate.
+    FeePerKB int64
+}
+
+// BuildBatch assembles unsigned transactions paying every payment in req,
+// in order. Normally that is one transaction; a batch too large to relay
+// is split in halves until each part fits under MaxStandardVSize. Parts
+// spend disjoint coins, so they can be broadcast in any order.
+func BuildBatch(ctx context.Context, p ChainProvider, req BatchRequest) ([]*txbuilder.Draft, error) {
+    from, err := netparams.DecodeAddress(req.From, req.Params)
+    if err != nil {
+        return nil, fmt.Errorf("invalid sender address: %w", err)
+    }
+    if len(req.Payments) == 0 {
+        return nil, fmt.Errorf("no payments")
+    }
+    utxos, err := ListUTXOs(ctx, p, spendFrom(req.From, req.Addresses)...)
+    if err != nil {
+        return nil, err
+    }
+    if utxos, err = req.Coins.Filter(utxos); err != nil {
+        return nil, err
+    }
+    feePerKB := req.FeePerKB
+    if feePerKB <= 0 {
+        fees, err := p.EstimateFee(ctx)
+        if err != nil {
+            return nil, err
+        }
+        feePerKB = FeeNormal.PerKB(fees)
+    }
+    strategy := req.Strategy
+    if strategy == "" {
+        strategy = coinselect.LeastCost
+    }
+    b := &batchBuilder{
+        base: txbuilder.Request{
+            Params:   req.Params,
+            From:     req.From,
+            Change:   req.Change,
+            AddrType: crypto.AddressTypeOf(from),
+            FeePerKB: feePerKB,
+            Strategy: strategy,
+        },
+        unspent:  utxos,
+        spendAll: len(req.Coins.Selected) > 0,
+    }
+    if err := b.build(req.Payments, true); err != nil {
+        return nil, err
+    }
+    return b.drafts, nil
+}
+
+type batchBuilder struct {
+    base     txbuilder.Request
+    unspent  []models.UTXO
+    // spendAll makes the last part spend every coin still unspent.
+    spendAll bool
+    drafts   []*txbuilder.Draft
+}
+
+// build pays payments in one transaction if it fits, and otherwise each
+// half in turn. Coins spent by a part are not offered to the next. last
+// marks the part that ends the batch.
+func (b *batchBuilder) build(payments []txbuilder.Payment, last bool) error {
+    req := b.base
+    req.Outputs = payments
+    req.UTXOs = b.unspent
+    req.SpendAll = b.spendAll && last
+    d, err := txbuilder.Build(req)
+    if err != nil {
+        return err
+    }
+    if d.VSize > txbuilder.MaxStandardVSize {
+        if len(payments) == 1 {
+            return fmt.Errorf("paying %s needs a transaction of %d vB, over the %d vB relay limit", payments[0].Address, d.VSize, txbuilder.MaxStandardVSize)
+        }
+        half := len(payments) / 2
+        if err := b.build(payments[:half], false); err != nil {
+            return err
+        }
+        return b.build(payments[half:], last)
+    }
+    spent := map[string]bool{}
+    for _, u := range d.Inputs {
+        spent[u.Outpoint()] = true
+    }
+    var left []models.UTXO
+    for _, u := range b.unspent {
+        if !spent[u.Outpoint()] {
+            left = append(left, u)
+        }
+    }
+    b.unspent = left
+    b.drafts = append(b.drafts, d)
+    return nil
+}
@@ -1,17 +1,22 @@
 package api
 
 import (
-    "bytes"
+    "context"
     "encoding/hex"
     "encoding/json"
+    "errors"
     "fmt"
-    "io"
     "net/http"
+    "net/url"
+    "strconv"
     "strings"
+    "time"
 
     "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
     "github.com/btcsuite/btcd/btcec/v2"
     "github.com/btcsuite/btcd/btcec/v2/ecdsa"
+    "github.com/btcsuite/btcd/chaincfg"
     "github.com/btcsuite/btcd/chaincfg/chainhash"
 )
 
@@ -19,45 +24,373 @@ const BlockCypherBaseURL = "https://api.blockcypher.com/v1/ltc/main"
 
 type BlockCypherClient struct {
     BaseURL string
+    Params  *chaincfg.Params
     Client  *http.Client
+    // MaxFee is the largest fee, in litoshis, SendTransaction accepts in a
+    // server-built skeleton.
+    MaxFee models.Amount
+    // Limiter enforces BlockCypher's request quotas; MaxRetries bounds
+    // retries of failed GETs.
+    Limiter    *RateLimiter
+    MaxRetries int
+    // Token is an optional BlockCypher API token; set it with SetToken so
+    // the rate limits are raised to match.
+    Token string
+
+    sleep func(context.Context, time.Duration) error
 }
 
-func NewBlockCypherClient() *BlockCypherClient {
+// BlockCypher's limits for requests without a token, and for a free token
+// until its real limits have been read from /tokens.
+const (
+    blockCypherPerSecond      = 3
+    blockCypherPerHour        = 100
+    blockCypherTokenPerHour   = 200
+    blockCypherMaxWait        = 10 * time.Second
+    blockCypherBatchAnonymous = 3
+    blockCypherBatchToken     = 100
+)
+
+var _ ChainProvider = (*BlockCypherClient)(nil)
+
+// NewBlockCypherClient returns a client for the given network. BlockCypher
+// only serves Litecoin mainnet, so other networks need baseURL pointing at a
+// compatible API.
+func NewBlockCypherClient(params *chaincfg.Params, baseURL string) (*BlockCypherClient, error) {
+    if baseURL == "" {
+        if params.Net != netparams.MainNetParams.Net {
+            return nil, fmt.Errorf("BlockCypher has no Litecoin %s API; set blockcypher_url to a compatible endpoint", params.Name)
+        }
+        baseURL = BlockCypherBaseURL
+    }
+    limiter := NewRateLimiter(blockCypherMaxWait,
+        NewTokenBucket(blockCypherPerSecond, time.Second),
+        NewTokenBucket(blockCypherPerHour, time.Hour))
     return &BlockCypherClient{
-        BaseURL: BlockCypherBaseURL,
-        Client:  &http.Client{},
+        BaseURL:    strings.TrimRight(baseURL, "/"),
+        Params:     params,
+        Client:     &http.Client{Timeout: DefaultTimeout},
+        MaxFee:     DefaultMaxFee,
+        Limiter:    limiter,
+        MaxRetries: DefaultMaxRetries,
+    }, nil
+}
+
+func (bc *BlockCypherClient) Name() string { return "blockcypher" }
+
+func (bc *BlockCypherClient) rest() *restClient {
+    sleep := bc.sleep
+    if sleep == nil {
+        sleep = sleepCtx
+    }
+    return &restClient{client: bc.Client, limiter: bc.Limiter, maxRetries: bc.MaxRetries, sleep: sleep}
+}
+
+func (bc *BlockCypherClient) get(ctx context.Context, url string, out interface{}) error {
+    return bc.rest().doJSON(ctx, http.MethodGet, bc.withToken(url), nil, out)
+}
+
+func (bc *BlockCypherClient) post(ctx context.Context, url string, payload, out interface{}) error {
+    return bc.rest().doJSON(ctx, http.MethodPost, bc.withToken(url), payload, out)
+}
+
+func (bc *BlockCypherClient) withToken(endpoint string) string {
+    if bc.Token == "" {
+        return endpoint
+    }
+    sep := "?"
+    if strings.Contains(endpoint, "?") {
+        sep = "&"
+    }
+    return endpoint + sep + "token=" + url.QueryEscape(bc.Token)
+}
+
+// SetToken makes every request carry token and raises the hourly quota to
+// that of a free token. Call ApplyTokenLimits to use the token's real limits.
+func (bc *BlockCypherClient) SetToken(token string) {
+    bc.Token = token
+    if bc.Limiter != nil && token != "" {
+        bc.Limiter.SetBuckets(
+            NewTokenBucket(blockCypherPerSecond, time.Second),
+            NewTokenBucket(blockCypherTokenPerHour, time.Hour))
     }
 }
 
-func (bc *BlockCypherClient) GetAddressInfo(address string) (models.AddressOverview, error) {
-    url := fmt.Sprintf("%s/addrs/%s?limit=10", bc.BaseURL, address)
-    resp, err := bc.Client.Get(url)
+// TokenUsage is BlockCypher's /tokens report: limits and hits are keyed by
+// quota name, e.g. "api/hour" and "api/second".
+type TokenUsage struct {
+    Limits map[string]int `json:"limits"`
+    Hits   map[string]int `json:"hits"`
+}
+
+// Remaining returns how much of quota is left, or -1 if it is not reported.
+func (u TokenUsage) Remaining(quota string) int {
+    limit, ok := u.Limits[quota]
+    if !ok {
+        return -1
+    }
+    return limit - u.Hits[quota]
+}
+
+// TokenStatus reads the token's limits and current usage. The /tokens
+// endpoint sits at the API root, not under the coin and chain.
+func (bc *BlockCypherClient) TokenStatus(ctx context.Context) (TokenUsage, error) {
+    var usage TokenUsage
+    if bc.Token == "" {
+        return usage, fmt.Errorf("no BlockCypher token configured")
+    }
+    u, err := url.Parse(bc.BaseURL)
     if err != nil {
-        return models.AddressOverview{}, err
+        return usage, err
     }
-    defer resp.Body.Close()
-    var info models.AddressOverview
-    err = json.NewDecoder(resp.Body).Decode(&info)
+    u.Path, u.RawQuery = "/v1/tokens/"+url.PathEscape(bc.Token), ""
+    err = bc.rest().doJSON(ctx, http.MethodGet, u.String(), nil, &usage)
+    if err != nil {
+        return usage, fmt.Errorf("token status: %w", err)
+    }
+    return usage, nil
+}
+
+// ApplyTokenLimits sizes the rate limiter to the limits in usage.
+func (bc *BlockCypherClient) ApplyTokenLimits(usage TokenUsage) {
+    perSecond, perHour := usage.Limits["api/second"], usage.Limits["api/hour"]
+    if bc.Limiter == nil || perSecond <= 0 || perHour <= 0 {
+        return
+    }
+    hour := NewTokenBucket(perHour, time.Hour)
+    // Start from what the server says is left this hour.
+    hour.tokens = float64(max(usage.Remaining("api/hour"), 0))
+    hour.last = bc.Limiter.now()
+    bc.Limiter.SetBuckets(NewTokenBucket(perSecond, time.Second), hour)
+}
+
+// GetAddressInfo returns the address totals. Txrefs holds only the newest
+// page of history; HistoryPage pages through the rest.
+func (bc *BlockCypherClient) GetAddressInfo(ctx context.Context, address string) (models.AddressOverview, error) {
+    var response struct {
+        models.AddressOverview
+        Txrefs            []blockCypherTxref `json:"txrefs"`
+        UnconfirmedTxrefs []blockCypherTxref `json:"unconfirmed_txrefs"`
+    }
+    err := bc.get(ctx, fmt.Sprintf("%s/addrs/%s?limit=%d", bc.BaseURL, address, blockCypherHistoryPage), &response)
+    info := response.AddressOverview
+    info.Txrefs = mergeTxrefs(address, append(response.UnconfirmedTxrefs, response.Txrefs...))
     return info, err
 }
 
-func (bc *BlockCypherClient) GetBalance(address string) (int64, error) {
-    url := fmt.Sprintf("%s/addrs/%s/balance", bc.BaseURL, address)
-    resp, err := bc.Client.Get(url)
+// blockCypherHistoryPage is the limit a page of an address asks for first.
+// BlockCypher counts inputs and outputs, so a page holds at most this many
+// transactions. blockCypherMaxLimit is the most BlockCypher allows.
+const (
+    blockCypherHistoryPage = 200
+    blockCypherMaxLimit    = 2000
+)
+
+// blockCypherTxref is one input or output of ours in an address response.
+// Spent inputs have TxInputN >= 0; received outputs have TxInputN == -1.
+type blockCypherTxref struct {
+    TxHash        string        `json:"tx_hash"`
+    BlockHeight   int64         `json:"block_height"`
+    TxInputN      int           `json:"tx_input_n"`
+    Value         models.Amount `json:"value"`
+    Confirmations int           `json:"confirmations"`
+    Confirmed     string        `json:"confirmed"`
+    Received      string        `json:"received"`
+}
+
+// mergeTxrefs folds the inputs and outputs of each transaction into one
+// entry whose Value is the net change for address, keeping their order.
+func mergeTxrefs(address string, refs []blockCypherTxref) []models.Transaction {
+    var txs []models.Transaction
+    index := map[string]int{}
+    for _, r := range refs {
+        i, ok := index[r.TxHash]
+        if !ok {
+            received := r.Confirmed
+            if received == "" {
+                received = r.Received
+            }
+            i = len(txs)
+            index[r.TxHash] = i
+            txs = append(txs, models.Transaction{Hash: r.TxHash, BlockHeight: r.BlockHeight, Confirmations: r.Confirmations, Received: received, Addresses: []string{address}})
+        }
+        if r.TxInputN >= 0 {
+            txs[i].Value -= r.Value
+        } else {
+            txs[i].Value += r.Value
+        }
+    }
+    return txs
+}
+
+// blockCypherPage fetches one page of /addrs/address with the before=
+// cursor, a block height, and query added. before= skips the whole cursor
+// height, so a block cut off by the limit would lose its remaining
+// entries: it is dropped from the page and next asks again from just
+// above it. A block that fills the page on its own is fetched again with a
+// larger limit, and is an error if even blockCypherMaxLimit cannot hold it.
+// Unconfirmed entries are only returned with the first page.
+func blockCypherPage[T any](ctx context.Context, bc *BlockCypherClient, address, query, cursor string, height func(T) int64) (refs, unconfirmed []T, next string, err error) {
+    for limit := blockCypherHistoryPage; ; limit = min(limit*4, blockCypherMaxLimit) {
+        endpoint := fmt.Sprintf("%s/addrs/%s?limit=%d%s", bc.BaseURL, address, limit, query)
+        if cursor != "" {
+            endpoint += "&before=" + url.QueryEscape(cursor)
+        }
+        var response struct {
+            Txrefs            []T  `json:"txrefs"`
+            UnconfirmedTxrefs []T  `json:"unconfirmed_txrefs"`
+            HasMore           bool `json:"hasMore"`
+        }
+        if err := bc.get(ctx, endpoint, &response); err != nil {
+            return nil, nil, "", err
+        }
+        refs = response.Txrefs
+        if cursor == "" {
+            unconfirmed = response.UnconfirmedTxrefs
+        }
+        if !response.HasMore || len(refs) == 0 {
+            return refs, unconfirmed, "", nil
+        }
+        low := height(refs[len(refs)-1])
+        cut := len(refs)
+        for cut > 0 && height(refs[cut-1]) == low {
+            cut--
+        }
+        if cut > 0 {
+            return refs[:cut], unconfirmed, strconv.FormatInt(low+1, 10), nil
+        }
+        if limit == blockCypherMaxLimit {
+            return nil, nil, "", fmt.Errorf("block %d has more than %d entries for %s, more than BlockCypher can page through", low, limit, address)
+        }
+    }
+}
+
+// HistoryPage pages through the address with BlockCypher's before= cursor.
+// Unconfirmed transactions come first, on the first page.
+func (bc *BlockCypherClient) HistoryPage(ctx context.Context, address, cursor string) ([]models.Transaction, string, error) {
+    refs, unconfirmed, next, err := blockCypherPage(ctx, bc, address, "", cursor, func(r blockCypherTxref) int64 { return r.BlockHeight })
     if err != nil {
-        return 0, err
+        return nil, "", err
     }
-    defer resp.Body.Close()
+    return mergeTxrefs(address, append(unconfirmed, refs...)), next, nil
+}
+
+func (bc *BlockCypherClient) GetBalance(ctx context.Context, address string) (models.Amount, error) {
     var response struct {
-        Balance int64 `json:"balance"`
+        Balance models.Amount `json:"balance"`
     }
-    if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+    if err := bc.get(ctx, fmt.Sprintf("%s/addrs/%s/balance", bc.BaseURL, address), &response); err != nil {
         return 0, err
     }
     return response.Balance, nil
 }
 
-func (bc *BlockCypherClient) SendTransaction(privateKeyHex, fromAddress, toAddress string, amount int64, sendAll bool) (string, error) {
+// GetBalances looks up many addresses with the batch endpoint
+// (/addrs/a;b;c/balance). BlockCypher counts each address against the quota
+// but answers a whole batch in one round trip.
+func (bc *BlockCypherClient) GetBalances(ctx context.Context, addresses []string) (map[string]models.Amount, error) {
+    size := blockCypherBatchAnonymous
+    if bc.Token != "" {
+        size = blockCypherBatchToken
+    }
+    balances := make(map[string]models.Amount, len(addresses))
+    for start := 0; start < len(addresses); start += size {
+        chunk := addresses[start:min(start+size, len(addresses))]
+        var raw json.RawMessage
+        r := bc.rest()
+        r.cost = len(chunk)
+        endpoint := fmt.Sprintf("%s/addrs/%s/balance", bc.BaseURL, strings.Join(chunk, ";"))
+        if err := r.doJSON(ctx, http.MethodGet, bc.withToken(endpoint), nil, &raw); err != nil {
+            return balances, err
+        }
+        type entry struct {
+            Address string        `json:"address"`
+            Balance models.Amount `json:"balance"`
+            Error   string        `json:"error"`
+        }
+        var entries []entry
+        // A batch of one comes back as a bare object.
+        if err := json.Unmarshal(raw, &entries); err != nil {
+            var one entry
+            if err := json.Unmarshal(raw, &one); err != nil {
+                return balances, err
+            }
+            entries = []entry{one}
+        }
+        for _, e := range entries {
+            if e.Error != "" {
+                return balances, fmt.Errorf("%s: %s", e.Address, e.Error)
+            }
+            balances[e.Address] = e.Balance
+        }
+    }
+    return balances, nil
+}
+
+// GetUTXOs pages through every unspent output of address.
+func (bc *BlockCypherClient) GetUTXOs(ctx context.Context, address string) ([]models.UTXO, error) {
+    type unspent struct {
+        models.UTXO
+        BlockHeight int64 `json:"block_height"`
+    }
+    var utxos []models.UTXO
+    cursor := ""
+    for {
+        refs, unconfirmed, next, err := blockCypherPage(ctx, bc, address, "&unspentOnly=true&includeScript=true", cursor, func(u unspent) int64 { return u.BlockHeight })
+        if err != nil {
+            return nil, err
+        }
+        for _, u := range append(refs, unconfirmed...) {
+            utxos = append(utxos, u.UTXO)
+        }
+        // A cursor that does not move would page forever.
+        if next == "" || next == cursor {
+            return utxos, nil
+        }
+        cursor = next
+    }
+}
+
+// EstimateFee returns BlockCypher's low/medium/high fee rates from the chain endpoint.
+func (bc *BlockCypherClient) EstimateFee(ctx context.Context) (models.FeeEstimate, error) {
+    var fees models.FeeEstimate
+    err := bc.get(ctx, bc.BaseURL, &fees)
+    return fees, err
+}
+
+// GetTransaction looks txid up; a 404 means BlockCypher does not know it
+// and is reported as ErrTxNotFound.
+func (bc *BlockCypherClient) GetTransaction(ctx context.Context, txid string) (models.TxDetail, error) {
+    var tx models.TxDetail
+    err := bc.get(ctx, fmt.Sprintf("%s/txs/%s?limit=1000", bc.BaseURL, txid), &tx)
+    var se *HTTPStatusError
+    if errors.As(err, &se) && se.StatusCode == http.StatusNotFound {
+        err = fmt.Errorf("%w: %s: %w", ErrTxNotFound, txid, err)
+    }
+    return tx, err
+}
+
+// BroadcastRawTx pushes a fully signed transaction and returns its hash.
+func (bc *BlockCypherClient) BroadcastRawTx(ctx context.Context, rawHex string) (string, error) {
+    var result struct {
+        Tx struct{ Hash string `json:"hash"` } `json:"tx"`
+    }
+    if err := bc.post(ctx, fmt.Sprintf("%s/txs/push", bc.BaseURL), map[string]string{"tx": rawHex}, &result); err != nil {
+        return "", bc.broadcastError(err)
+    }
+    if result.Tx.Hash == "" {
+        return "", fmt.Errorf("broadcast error: no transaction hash in response")
+    }
+    return result.Tx.Hash, nil
+}
+
+// SendTransaction uses BlockCypher's /txs/new skeleton flow for legacy
+// inputs: the server assembles the transaction and we sign its "tosign"
+// hashes. The skeleton is checked with verifySkeleton first and a
+// *SkeletonError is returned, without signing, if it pays anything other
+// than what was requested. Prefer api.BuildSend, which builds the
+// transaction locally.
+func (bc *BlockCypherClient) SendTransaction(ctx context.Context, privateKeyHex, fromAddress, toAddress string, amount models.Amount, sendAll bool) (string, error) {
     var txReq map[string]interface{}
     if sendAll {
         txReq = map[string]interface{}{
@@ -70,69 +403,78 @@ func (bc *BlockCypherClient) SendTransaction(privateKeyHex, fromAddress, toAddre
             "outputs": []map[string]interface{}{{"addresses": []string{toAddress}, "value": amount}},
         }
     }
-    jsonData, _ := json.Marshal(txReq)
-    url := fmt.Sprintf("%s/txs/new", bc.BaseURL)
-    resp, err := bc.Client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+    var txSkeleton struct {
+        ToSign   []string        `json:"tosign"`
+        ToSignTx []string        `json:"tosign_tx"`
+        Tx       json.RawMessage `json:"tx"`
+    }
+    err := bc.post(ctx, fmt.Sprintf("%s/txs/new?includeToSignTx=true", bc.BaseURL), txReq, &txSkeleton)
+    var se *HTTPStatusError
+    if errors.As(err, &se) {
+        switch {
+        case strings.Contains(se.Message, "Insufficient funds"):
+            return "", fmt.Errorf("%w: %w", ErrInsufficientFunds, err)
+        case strings.Contains(se.Message, "can't have zero for value"):
+            return "", fmt.Errorf("%w: %w", ErrZeroValue, err)
+        case strings.Contains(se.Message, "Unable to find a transaction to spend"):
+            return "", fmt.Errorf("%w: %w", ErrNoUTXOs, err)
+        }
+    }
     if err != nil {
         return "", err
     }
-    defer resp.Body.Close()
-    body, _ := io.ReadAll(resp.Body)
-    if resp.StatusCode != http.StatusCreated {
-        var res map[string]interface{}
-        json.Unmarshal(body, &res)
-        if errs, ok := res["errors"].([]interface{}); ok {
-            for _, e := range errs {
-                if errStr, ok := e.(map[string]interface{})["error"].(string); ok {
-                    switch {
-                    case strings.Contains(errStr, "Insufficient funds"):
-                        return "", fmt.Errorf("You have insufficient balance for this operation.")
-                    case strings.Contains(errStr, "can't have zero for value"):
-                        return "", fmt.Errorf("Cannot send zero coins. Enter a valid amount.")
-                    case strings.Contains(errStr, "Unable to find a transaction to spend"):
-                        return "", fmt.Errorf("No Litecoin ever deposited to this wallet. It cannot spend.")
-                    }
-                    return "", fmt.Errorf(errStr)
-                }
-            }
-        }
-        return "", fmt.Errorf("Service error: %s", string(body))
+    var decoded skeletonTx
+    if err := json.Unmarshal(txSkeleton.Tx, &decoded); err != nil {
+        return "", err
     }
-    var txSkeleton struct {
-        ToSign []string `json:"tosign"`
-        Tx     struct {
-            Hash string `json:"hash"`
-        } `json:"tx"`
-        TxFee int64 `json:"fees"`
+    err = verifySkeleton(skeletonRequest{
+        params:  bc.Params,
+        from:    fromAddress,
+        to:      toAddress,
+        amount:  amount,
+        sendAll: sendAll,
+        maxFee:  bc.MaxFee,
+    }, decoded, txSkeleton.ToSign, txSkeleton.ToSignTx)
+    if err != nil {
+        return "", err
     }
-    if err := json.Unmarshal(body, &txSkeleton); err != nil {
+    privBytes, err := hex.DecodeString(privateKeyHex)
+    if err != nil {
         return "", err
     }
-    privBytes, _ := hex.DecodeString(privateKeyHex)
     priv, _ := btcec.PrivKeyFromBytes(privBytes)
+    pubHex := hex.EncodeToString(priv.PubKey().SerializeCompressed())
     sigs := make([]string, len(txSkeleton.ToSign))
+    pubkeys := make([]string, len(txSkeleton.ToSign))
     for i, tosign := range txSkeleton.ToSign {
         hash, _ := hex.DecodeString(tosign)
         msgHash, _ := chainhash.NewHash(hash)
         signature := ecdsa.Sign(priv, msgHash[:])
         sigs[i] = hex.EncodeToString(signature.Serialize())
+        pubkeys[i] = pubHex
     }
     signedTx := map[string]interface{}{
         "signatures": sigs,
-        "pubkeys":    []string{hex.EncodeToString(priv.PubKey().SerializeCompressed())},
+        "pubkeys":    pubkeys,
+        "tosign":     txSkeleton.ToSign,
         "tx":         txSkeleton.Tx,
     }
-    signedTxJson, _ := json.Marshal(signedTx)
-    url = fmt.Sprintf("%s/txs/send", bc.BaseURL)
-    resp2, err := bc.Client.Post(url, "application/json", bytes.NewBuffer(signedTxJson))
-    if err != nil {
-        return "", err
+    var result struct {
+        Tx struct{ Hash string `json:"hash"` } `json:"tx"`
     }
-    defer resp2.Body.Close()
-    var result struct{ Tx struct{ Hash string `json:"hash"` } `json:"tx"` }
-    if err := json.NewDecoder(resp2.Body).Decode(&result); err != nil {
-        body2, _ := io.ReadAll(resp2.Body)
-        return "", fmt.Errorf("broadcast error: %s", string(body2))
+    if err := bc.post(ctx, fmt.Sprintf("%s/txs/send", bc.BaseURL), signedTx, &result); err != nil {
+        return "", bc.broadcastError(err)
     }
     return result.Tx.Hash, nil
 }
+
+// broadcastError turns a 4xx answer to a push into a *BroadcastError. Rate
+// limits, server errors and lost connections leave the outcome unknown and
+// are returned as they are.
+func (bc *BlockCypherClient) broadcastError(err error) error {
+    var se *HTTPStatusError
+    if errors.As(err, &se) && se.StatusCode >= 400 && se.StatusCode < 500 && !errors.Is(se, ErrRateLimited) {
+        return &BroadcastError{Provider: bc.Name(), Reason: se.Message, Err: err}
+    }
+    return fmt.Errorf("broadcast: %w", err)
+}
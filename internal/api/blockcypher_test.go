@@ -0,0 +1,350 @@
+package api
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "litecoin-wallet/internal/netparams"
+)
+
+// newTestBlockCypher points a client at handler and records retry delays
+// instead of sleeping.
+func newTestBlockCypher(t *testing.T, handler http.HandlerFunc) (*BlockCypherClient, *[]time.Duration) {
+    srv := httptest.NewServer(handler)
+    t.Cleanup(srv.Close)
+    bc, err := NewBlockCypherClient(&netparams.MainNetParams, srv.URL)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var delays []time.Duration
+    bc.sleep = func(ctx context.Context, d time.Duration) error {
+        delays = append(delays, d)
+        return ctx.Err()
+    }
+    bc.Limiter = nil
+    return bc, &delays
+}
+
+func TestBlockCypherRetriesGETs(t *testing.T) {
+    var calls int32
+    bc, delays := newTestBlockCypher(t, func(w http.ResponseWriter, r *http.Request) {
+        switch atomic.AddInt32(&calls, 1) {
+        case 1:
+            w.Header().Set("Retry-After", "2")
+            w.WriteHeader(http.StatusTooManyRequests)
+        case 2:
+            w.WriteHeader(http.StatusBadGateway)
+        default:
+            fmt.Fprint(w, `{"balance": 1234}`)
+        }
+    })
+    balance, err := bc.GetBalance(context.Background(), "L1")
+    if err != nil || balance != 1234 {
+        t.Fatalf("balance = %d, %v", balance, err)
+    }
+    if calls != 3 || len(*delays) != 2 {
+        t.Fatalf("calls = %d, delays = %v", calls, *delays)
+    }
+    if (*delays)[0] != 2*time.Second {
+        t.Errorf("first delay = %s, want Retry-After of 2s", (*delays)[0])
+    }
+    if (*delays)[1] < 2*retryBaseDelay {
+        t.Errorf("second delay = %s, want exponential backoff", (*delays)[1])
+    }
+}
+
+func TestBlockCypherStatusErrors(t *testing.T) {
+    var calls int32
+    bc, _ := newTestBlockCypher(t, func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&calls, 1)
+        if r.Method == http.MethodPost {
+            w.WriteHeader(http.StatusServiceUnavailable)
+            return
+        }
+        w.WriteHeader(http.StatusTooManyRequests)
+        fmt.Fprint(w, `{"error": "Limits reached."}`)
+    })
+    bc.MaxRetries = 2
+    _, err := bc.GetBalance(context.Background(), "L1")
+    var se *HTTPStatusError
+    if !errors.As(err, &se) || se.StatusCode != 429 || se.Message != "Limits reached." || !errors.Is(err, ErrRateLimited) {
+        t.Fatalf("err = %v, want 429 with message instead of a zero balance", err)
+    }
+    if calls != 3 {
+        t.Errorf("calls = %d, want 1 + 2 retries", calls)
+    }
+
+    atomic.StoreInt32(&calls, 0)
+    _, err = bc.BroadcastRawTx(context.Background(), "00")
+    if !errors.As(err, &se) || se.StatusCode != 503 || !errors.Is(err, ErrNetwork) || errors.Is(err, ErrBroadcastRejected) {
+        t.Errorf("err = %v, want 503", err)
+    }
+    if calls != 1 {
+        t.Errorf("POST was sent %d times, want no retries", calls)
+    }
+}
+
+func TestBlockCypherNotFoundOnlyForTransactions(t *testing.T) {
+    bc, _ := newTestBlockCypher(t, func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusNotFound)
+        fmt.Fprint(w, `{"error": "not found"}`)
+    })
+    if _, err := bc.GetTransaction(context.Background(), "ab"); !errors.Is(err, ErrTxNotFound) {
+        t.Errorf("tx lookup: err = %v, want ErrTxNotFound", err)
+    }
+    // A wrong base URL or network is not a missing transaction.
+    for name, call := range map[string]func() error{
+        "balance": func() error { _, err := bc.GetBalance(context.Background(), "L1"); return err },
+        "fees":    func() error { _, err := bc.EstimateFee(context.Background()); return err },
+    } {
+        var se *HTTPStatusError
+        if err := call(); errors.Is(err, ErrTxNotFound) || !errors.As(err, &se) || se.StatusCode != 404 {
+            t.Errorf("%s: err = %v, want a plain 404", name, err)
+        }
+    }
+}
+
+func TestBlockCypherBroadcastRejected(t *testing.T) {
+    bc, _ := newTestBlockCypher(t, func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusBadRequest)
+        fmt.Fprint(w, `{"error": "Error validating transaction: Transaction with hash abcd already exists."}`)
+    })
+    _, err := bc.BroadcastRawTx(context.Background(), "00")
+    var be *BroadcastError
+    if !errors.Is(err, ErrBroadcastRejected) || !errors.As(err, &be) || !strings.Contains(be.Reason, "already exists") {
+        t.Fatalf("err = %v, want rejection with the server's reason", err)
+    }
+}
+
+func TestBlockCypherLongRetryAfterBlocks(t *testing.T) {
+    var calls int32
+    bc, _ := newTestBlockCypher(t, func(w http.ResponseWriter, r *http.Request) {
+        atomic.AddInt32(&calls, 1)
+        w.Header().Set("Retry-After", "3600")
+        w.WriteHeader(http.StatusTooManyRequests)
+    })
+    bc.Limiter = NewRateLimiter(time.Second)
+    if _, err := bc.GetBalance(context.Background(), "L1"); err == nil {
+        t.Fatal("expected 429 error")
+    }
+    _, err := bc.GetBalance(context.Background(), "L1")
+    var rl *RateLimitError
+    if !errors.As(err, &rl) || !errors.Is(err, ErrRateLimited) || RetryAfter(err) < 59*time.Minute {
+        t.Fatalf("err = %v, want local rate limit until Retry-After passes", err)
+    }
+    if calls != 1 {
+        t.Errorf("server hit %d times, want 1", calls)
+    }
+}
+
+func TestRateLimiterBuckets(t *testing.T) {
+    now := time.Unix(0, 0)
+    var slept time.Duration
+    l := NewRateLimiter(10*time.Second, NewTokenBucket(3, time.Second), NewTokenBucket(5, time.Hour))
+    l.now = func() time.Time { return now }
+    l.sleep = func(_ context.Context, d time.Duration) error { slept += d; now = now.Add(d); return nil }
+
+    for i := 0; i < 3; i++ {
+        if err := l.Wait(context.Background()); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if slept != 0 {
+        t.Fatalf("slept %s within burst", slept)
+    }
+    l.Wait(context.Background())
+    if slept < 300*time.Millisecond || slept > 400*time.Millisecond {
+        t.Errorf("4th request slept %s, want ~333ms", slept)
+    }
+    l.Wait(context.Background())
+    err := l.Wait(context.Background())
+    var rl *RateLimitError
+    if !errors.As(err, &rl) || rl.Quota != "5 requests per 1h0m0s" {
+        t.Fatalf("err = %v, want hourly quota exhausted", err)
+    }
+}
+
+func TestBlockCypherTokenAndBatchBalances(t *testing.T) {
+    var paths []string
+    bc, _ := newTestBlockCypher(t, func(w http.ResponseWriter, r *http.Request) {
+        paths = append(paths, r.URL.Path+"?"+r.URL.RawQuery)
+        switch r.URL.Path {
+        case "/v1/tokens/s3cret":
+            fmt.Fprint(w, `{"token":"s3cret","limits":{"api/hour":2000,"api/second":5},"hits":{"api/hour":150}}`)
+        case "/addrs/La;Lb;Lc/balance":
+            fmt.Fprint(w, `[{"address":"La","balance":1},{"address":"Lb","balance":2},{"address":"Lc","balance":3}]`)
+        case "/addrs/Ld/balance":
+            fmt.Fprint(w, `{"address":"Ld","balance":4}`)
+        default:
+            w.WriteHeader(http.StatusNotFound)
+        }
+    })
+
+    balances, err := bc.GetBalances(context.Background(), []string{"La", "Lb", "Lc", "Ld"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(balances) != 4 || balances["Lc"] != 3 || balances["Ld"] != 4 {
+        t.Errorf("balances = %v", balances)
+    }
+    if len(paths) != 2 || paths[1] != "/addrs/Ld/balance?" {
+        t.Errorf("requests = %v, want anonymous batches of 3", paths)
+    }
+
+    paths = nil
+    bc.Limiter = NewRateLimiter(time.Second)
+    bc.SetToken("s3cret")
+    bc.GetBalances(context.Background(), []string{"La", "Lb", "Lc"})
+    if len(paths) != 1 || paths[0] != "/addrs/La;Lb;Lc/balance?token=s3cret" {
+        t.Errorf("requests = %v, want token on every request", paths)
+    }
+    usage, err := bc.TokenStatus(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if usage.Remaining("api/hour") != 1850 || usage.Remaining("hooks/hour") != -1 {
+        t.Errorf("usage = %+v", usage)
+    }
+    bc.ApplyTokenLimits(usage)
+    if r := bc.Limiter.Remaining(); len(r) != 2 || r[0].Capacity != 5 || r[1].Capacity != 2000 || r[1].Available != 1850 {
+        t.Errorf("limiter = %+v", r)
+    }
+}
+
+func TestBlockCypherRedactsTokenFromErrors(t *testing.T) {
+    bc, err := NewBlockCypherClient(&netparams.MainNetParams, "http://127.0.0.1:1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    bc.MaxRetries = 0
+    bc.SetToken("s3cret")
+    _, err = bc.GetBalance(context.Background(), "L1")
+    if !errors.Is(err, ErrNetwork) || strings.Contains(err.Error(), "s3cret") {
+        t.Errorf("err = %v, want connection error without the token", err)
+    }
+}
+
+func TestBlockCypherHistoryPaging(t *testing.T) {
+    // 100 confirmed transactions, each with two outputs to us and one
+    // input from us, so a 200-entry page ends in the middle of a block.
+    type ref struct {
+        TxHash      string `json:"tx_hash"`
+        BlockHeight int64  `json:"block_height"`
+        TxInputN    int    `json:"tx_input_n"`
+        Value       int64  `json:"value"`
+    }
+    var refs []ref
+    for h := int64(100); h > 0; h-- {
+        hash := fmt.Sprintf("%064d", h)
+        refs = append(refs, ref{hash, h, -1, 1000}, ref{hash, h, -1, 1000}, ref{hash, h, 0, 500})
+    }
+    var befores []string
+    bc, _ := newTestBlockCypher(t, func(w http.ResponseWriter, r *http.Request) {
+        before := r.URL.Query().Get("before")
+        befores = append(befores, before)
+        below, _ := strconv.ParseInt(before, 10, 64)
+        var page []ref
+        hasMore := false
+        for _, x := range refs {
+            if before != "" && x.BlockHeight >= below {
+                continue
+            }
+            if len(page) == blockCypherHistoryPage {
+                hasMore = true
+                break
+            }
+            page = append(page, x)
+        }
+        out := map[string]interface{}{"txrefs": page, "hasMore": hasMore}
+        if before == "" {
+            out["unconfirmed_txrefs"] = []ref{{TxHash: "ff", TxInputN: -1, Value: 7}}
+        }
+        json.NewEncoder(w).Encode(out)
+    })
+
+    txs, err := FullHistory(context.Background(), bc, "L1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(txs) != 101 || txs[0].Hash != "ff" || txs[0].Value != 7 {
+        t.Fatalf("got %d transactions, first %+v", len(txs), txs[0])
+    }
+    for _, tx := range txs[1:] {
+        if tx.Value != 1500 {
+            t.Fatalf("tx %s value = %d, want inputs and outputs merged to 1500", tx.Hash, tx.Value)
+        }
+    }
+    if len(befores) != 2 || befores[1] != "35" {
+        t.Errorf("before cursors = %q, want the cut-off block 34 fetched again", befores)
+    }
+}
+
+// blockCypherAddrServer serves /addrs/ pages of refs, newest block first,
+// honouring limit and before= like BlockCypher, and records the queries.
+func blockCypherAddrServer(t *testing.T, refs []map[string]interface{}) (*BlockCypherClient, *[]string) {
+    var queries []string
+    bc, _ := newTestBlockCypher(t, func(w http.ResponseWriter, r *http.Request) {
+        q := r.URL.Query()
+        queries = append(queries, q.Encode())
+        limit, _ := strconv.Atoi(q.Get("limit"))
+        below, _ := strconv.ParseInt(q.Get("before"), 10, 64)
+        page := []map[string]interface{}{}
+        hasMore := false
+        for _, x := range refs {
+            if q.Get("before") != "" && x["block_height"].(int64) >= below {
+                continue
+            }
+            if len(page) == limit {
+                hasMore = true
+                break
+            }
+            page = append(page, x)
+        }
+        json.NewEncoder(w).Encode(map[string]interface{}{"txrefs": page, "hasMore": hasMore})
+    })
+    return bc, &queries
+}
+
+func TestBlockCypherPagesThroughABlockFillingThePage(t *testing.T) {
+    // Block 10 pays us 300 times, more than a first page holds.
+    var refs []map[string]interface{}
+    for i := 0; i < 300; i++ {
+        refs = append(refs, map[string]interface{}{"tx_hash": fmt.Sprintf("%064d", i+1), "block_height": int64(10), "tx_input_n": -1, "tx_output_n": 0, "value": 1000})
+    }
+    for h := int64(9); h > 0; h-- {
+        refs = append(refs, map[string]interface{}{"tx_hash": fmt.Sprintf("a%063d", h), "block_height": h, "tx_input_n": -1, "tx_output_n": 0, "value": 1000})
+    }
+    bc, queries := blockCypherAddrServer(t, refs)
+    txs, err := FullHistory(context.Background(), bc, "L1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(txs) != 309 {
+        t.Errorf("got %d transactions, want all 309: %q", len(txs), *queries)
+    }
+    utxos, err := bc.GetUTXOs(context.Background(), "L1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(utxos) != 309 {
+        t.Errorf("got %d UTXOs, want all 309", len(utxos))
+    }
+
+    // A block no page can hold is an error, not a silent gap.
+    for i := 300; i < 2100; i++ {
+        refs = append([]map[string]interface{}{{"tx_hash": fmt.Sprintf("%064d", i+1), "block_height": int64(10), "tx_input_n": -1, "value": 1000}}, refs...)
+    }
+    bc, _ = blockCypherAddrServer(t, refs)
+    if _, err := FullHistory(context.Background(), bc, "L1"); err == nil {
+        t.Error("a block over the maximum limit paged without error")
+    }
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+    "context"
+    "fmt"
+
+    "litecoin-wallet/internal/models"
+)
+
+// ListUTXOs returns the unspent outputs of every address, in the order the
+// addresses are given, with Address filled in.
+func ListUTXOs(ctx context.Context, p ChainProvider, addresses ...string) ([]models.UTXO, error) {
+    var all []models.UTXO
+    for _, address := range addresses {
+        utxos, err := p.GetUTXOs(ctx, address)
+        if err != nil {
+            return nil, err
+        }
+        for i := range utxos {
+            utxos[i].Address = address
+        }
+        all = append(all, utxos...)
+    }
+    return all, nil
+}
+
+// spendFrom is the addresses whose coins a request may spend: addresses
+// when given, otherwise just from.
+func spendFrom(from string, addresses []string) []string {
+    if len(addresses) == 0 {
+        return []string{from}
+    }
+    return addresses
+}
+
+// CoinControl restricts which UTXOs a send may spend. Both fields hold
+// outpoints ("txid:vout"). Frozen coins are never spent. When Selected is
+// set only those coins are spent, all of them, and the rest are left alone.
+type CoinControl struct {
+    Frozen   map[string]bool
+    Selected []string
+}
+
+// Empty reports whether c leaves coin choice entirely to the wallet.
+func (c CoinControl) Empty() bool {
+    for _, frozen := range c.Frozen {
+        if frozen {
+            return false
+        }
+    }
+    return len(c.Selected) == 0
+}
+
+// Filter returns the UTXOs c allows, in the order they are given. A
+// selected coin that is no longer unspent, or is frozen, is an error
+// rather than being dropped silently.
+func (c CoinControl) Filter(utxos []models.UTXO) ([]models.UTXO, error) {
+    var out []models.UTXO
+    if len(c.Selected) == 0 {
+        for _, u := range utxos {
+            if !c.Frozen[u.Outpoint()] {
+                out = append(out, u)
+            }
+        }
+    } else {
+        byOutpoint := make(map[string]models.UTXO, len(utxos))
+        for _, u := range utxos {
+            byOutpoint[u.Outpoint()] = u
+        }
+        for _, op := range c.Selected {
+            u, ok := byOutpoint[op]
+            if !ok {
+                return nil, fmt.Errorf("selected coin %s is no longer unspent", op)
+            }
+            if c.Frozen[op] {
+                return nil, fmt.Errorf("selected coin %s is frozen", op)
+            }
+            out = append(out, u)
+        }
+    }
+    if len(out) == 0 {
+        return nil, ErrNoUTXOs
+    }
+    return out, nil
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+    "context"
+    "errors"
+    "testing"
+
+    "litecoin-wallet/internal/crypto"
+    "litecoin-wallet/internal/netparams"
+)
+
+func TestSendRespectsCoinControl(t *testing.T) {
+    m, w := fundedMemoryProvider(t, crypto.AddrP2WPKH, 50_000_000, 30_000_000, 20_000_000)
+    utxos, err := ListUTXOs(context.Background(), m, w.Address)
+    if err != nil || len(utxos) != 3 || utxos[0].Address != w.Address {
+        t.Fatalf("ListUTXOs = %+v, %v", utxos, err)
+    }
+    to := "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ"
+    req := SendRequest{Params: &netparams.MainNetParams, From: w.Address, To: to, Amount: 10_000_000}
+
+    // The first coin alone would do, but it is frozen.
+    req.Coins = CoinControl{Frozen: map[string]bool{utxos[0].Outpoint(): true}}
+    d, err := BuildSend(context.Background(), m, req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(d.Inputs) != 1 || d.Inputs[0].Outpoint() != utxos[1].Outpoint() {
+        t.Errorf("spent %+v, want only %s", d.Inputs, utxos[1].Outpoint())
+    }
+
+    // Selected coins are all spent, even the unneeded one.
+    req.Coins = CoinControl{Selected: []string{utxos[2].Outpoint(), utxos[1].Outpoint()}}
+    d, err = BuildSend(context.Background(), m, req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(d.Inputs) != 2 || d.Inputs[0].Outpoint() != utxos[2].Outpoint() || d.Change <= 0 {
+        t.Errorf("spent %+v with change %d, want both selected coins", d.Inputs, d.Change)
+    }
+
+    // Selecting a frozen or vanished coin is refused.
+    req.Coins = CoinControl{Selected: []string{utxos[0].Outpoint()}, Frozen: map[string]bool{utxos[0].Outpoint(): true}}
+    if _, err := BuildSend(context.Background(), m, req); err == nil {
+        t.Error("spending a frozen selected coin should fail")
+    }
+    req.Coins = CoinControl{Selected: []string{"ff:9"}}
+    if _, err := BuildSend(context.Background(), m, req); err == nil {
+        t.Error("spending a missing selected coin should fail")
+    }
+
+    // Everything frozen leaves nothing to spend.
+    req.Coins = CoinControl{Frozen: map[string]bool{}}
+    for _, u := range utxos {
+        req.Coins.Frozen[u.Outpoint()] = true
+    }
+    if _, err := BuildSend(context.Background(), m, req); !errors.Is(err, ErrNoUTXOs) {
+        t.Errorf("err = %v, want ErrNoUTXOs", err)
+    }
+}
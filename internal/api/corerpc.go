@@ -0,0 +1,633 @@
+package api
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math/big"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sync/atomic"
+    "time"
+
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "github.com/btcsuite/btcd/chaincfg"
+)
+
+// CoreRPCClient talks to a Litecoin Core node over JSON-RPC, so addresses are
+// only ever revealed to our own node. UTXOs come from scantxoutset, or from
+// listunspent when Wallet names a (watch-only) wallet that tracks them.
+type CoreRPCClient struct {
+    URL      string
+    User     string
+    Password string
+    // CookieFile is read on every call when User is empty, because
+    // litecoind writes a new cookie each time it starts.
+    CookieFile string
+    Wallet     string
+    Params     *chaincfg.Params
+    Client     *http.Client
+    // Timeout bounds calls made without a context deadline; scantxoutset,
+    // which reads the whole UTXO set, gets ScanTimeout instead.
+    Timeout     time.Duration
+    ScanTimeout time.Duration
+
+    nextID uint64
+}
+
+var _ ChainProvider = (*CoreRPCClient)(nil)
+
+const defaultScanTimeout = 5 * time.Minute
+
+// RPCError is an error object returned by the node.
+type RPCError struct {
+    Code    int    `json:"code"`
+    Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string { return fmt.Sprintf("litecoind: %s (code %d)", e.Message, e.Code) }
+
+// Is matches ErrTxNotFound for RPC_INVALID_ADDRESS_OR_KEY, which is what
+// getrawtransaction returns for an unknown txid.
+func (e *RPCError) Is(target error) bool { return target == ErrTxNotFound && e.Code == -5 }
+
+// NewCoreRPCClient returns a client for the node serving params. An empty url
+// uses the network's default RPC port on localhost; with no user the cookie
+// file (by default in ~/.litecoin) is used for authentication.
+func NewCoreRPCClient(params *chaincfg.Params, url, user, password, cookieFile, wallet string) *CoreRPCClient {
+    if url == "" {
+        url = "http://127.0.0.1:" + defaultRPCPort(params)
+    }
+    if user == "" && cookieFile == "" {
+        cookieFile = defaultCookieFile(params)
+    }
+    return &CoreRPCClient{
+        URL:         strings.TrimRight(url, "/"),
+        User:        user,
+        Password:    password,
+        CookieFile:  cookieFile,
+        Wallet:      wallet,
+        Params:      params,
+        Client:      &http.Client{},
+        Timeout:     DefaultTimeout,
+        ScanTimeout: defaultScanTimeout,
+    }
+}
+
+func defaultRPCPort(params *chaincfg.Params) string {
+    switch params.Net {
+    case netparams.TestNet4Params.Net:
+        return "19332"
+    case netparams.RegressionNetParams.Net:
+        return "19443"
+    }
+    return "9332"
+}
+
+func defaultCookieFile(params *chaincfg.Params) string {
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return ""
+    }
+    dir := filepath.Join(home, ".litecoin")
+    if params.Net != netparams.MainNetParams.Net {
+        dir = filepath.Join(dir, params.Name)
+    }
+    return filepath.Join(dir, ".cookie")
+}
+
+func (c *CoreRPCClient) Name() string { return "litecoind" }
+
+func (c *CoreRPCClient) credentials() (string, string, error) {
+    if c.User != "" {
+        return c.User, c.Password, nil
+    }
+    if c.CookieFile == "" {
+        return "", "", fmt.Errorf("litecoind: no rpc_user or rpc_cookie configured")
+    }
+    data, err := os.ReadFile(c.CookieFile)
+    if err != nil {
+        return "", "", fmt.Errorf("litecoind: read cookie: %w", err)
+    }
+    user, pass, ok := strings.Cut(strings.TrimSpace(string(data)), ":")
+    if !ok {
+        return "", "", fmt.Errorf("litecoind: malformed cookie file %s", c.CookieFile)
+    }
+    return user, pass, nil
+}
+
+// call invokes method and decodes its result into out. Node-side failures
+// come back as *RPCError.
+func (c *CoreRPCClient) call(ctx context.Context, url, method string, out interface{}, params ...interface{}) error {
+    if params == nil {
+        params = []interface{}{}
+    }
+    timeout := c.Timeout
+    if method == "scantxoutset" {
+        timeout = c.ScanTimeout
+    }
+    ctx, cancel := withTimeout(ctx, timeout)
+    defer cancel()
+    body, err := json.Marshal(map[string]interface{}{
+        "jsonrpc": "1.0",
+        "id":      atomic.AddUint64(&c.nextID, 1),
+        "method":  method,
+        "params":  params,
+    })
+    if err != nil {
+        return err
+    }
+    user, pass, err := c.credentials()
+    if err != nil {
+        return err
+    }
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.SetBasicAuth(user, pass)
+    resp, err := c.Client.Do(req)
+    if err != nil {
+        return &transportError{err}
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode == http.StatusUnauthorized {
+        return fmt.Errorf("litecoind: authentication failed, check rpc_user/rpc_password or rpc_cookie")
+    }
+    var reply struct {
+        Result json.RawMessage `json:"result"`
+        Error  *RPCError       `json:"error"`
+    }
+    dec := json.NewDecoder(resp.Body)
+    dec.UseNumber()
+    if err := dec.Decode(&reply); err != nil {
+        if resp.StatusCode < 200 || resp.StatusCode > 299 {
+            return &HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status, Message: "litecoind " + method}
+        }
+        return fmt.Errorf("litecoind: %s: malformed response: %v", method, err)
+    }
+    if reply.Error != nil {
+        return reply.Error
+    }
+    if out == nil {
+        return nil
+    }
+    return json.Unmarshal(reply.Result, out)
+}
+
+func (c *CoreRPCClient) walletURL() string {
+    return c.URL + "/wallet/" + c.Wallet
+}
+
+// ChainInfo is the subset of getblockchaininfo the wallet uses.
+type ChainInfo struct {
+    Chain  string `json:"chain"`
+    Blocks int64  `json:"blocks"`
+}
+
+// ChainInfo returns the node's chain and height, and fails if the node is on
+// a different network than the client was created for.
+func (c *CoreRPCClient) ChainInfo(ctx context.Context) (ChainInfo, error) {
+    var info ChainInfo
+    if err := c.call(ctx, c.URL, "getblockchaininfo", &info); err != nil {
+        return info, err
+    }
+    want := "main"
+    switch c.Params.Net {
+    case netparams.TestNet4Params.Net:
+        want = "test"
+    case netparams.RegressionNetParams.Net:
+        want = "regtest"
+    }
+    if info.Chain != want {
+        return info, fmt.Errorf("litecoind is on %q but the wallet is using %s", info.Chain, c.Params.Name)
+    }
+    return info, nil
+}
+
+func (c *CoreRPCClient) GetUTXOs(ctx context.Context, address string) ([]models.UTXO, error) {
+    if _, err := netparams.DecodeAddress(address, c.Params); err != nil {
+        return nil, err
+    }
+    if c.Wallet != "" {
+        var unspent []struct {
+            TxID          string      `json:"txid"`
+            Vout          uint32      `json:"vout"`
+            Amount        json.Number `json:"amount"`
+            Confirmations int         `json:"confirmations"`
+            ScriptPubKey  string      `json:"scriptPubKey"`
+        }
+        err := c.call(ctx, c.walletURL(), "listunspent", &unspent, 0, 9999999, []string{address})
+        if err != nil {
+            return nil, err
+        }
+        utxos := make([]models.UTXO, 0, len(unspent))
+        for _, u := range unspent {
+            value, err := parseCoins(u.Amount)
+            if err != nil {
+                return nil, err
+            }
+            utxos = append(utxos, models.UTXO{TxHash: u.TxID, OutputIndex: u.Vout, Value: value, Confirmations: u.Confirmations, Script: u.ScriptPubKey})
+        }
+        return utxos, nil
+    }
+    var scan struct {
+        Success  bool  `json:"success"`
+        Height   int64 `json:"height"`
+        Unspents []struct {
+            TxID         string      `json:"txid"`
+            Vout         uint32      `json:"vout"`
+            ScriptPubKey string      `json:"scriptPubKey"`
+            Amount       json.Number `json:"amount"`
+            Height       int64       `json:"height"`
+        } `json:"unspents"`
+    }
+    err := c.call(ctx, c.URL, "scantxoutset", &scan, "start", []string{"addr(" + address + ")"})
+    if err != nil {
+        return nil, err
+    }
+    if !scan.Success {
+        return nil, fmt.Errorf("litecoind: scantxoutset did not complete")
+    }
+    utxos := make([]models.UTXO, 0, len(scan.Unspents))
+    for _, u := range scan.Unspents {
+        value, err := parseCoins(u.Amount)
+        if err != nil {
+            return nil, err
+        }
+        utxos = append(utxos, models.UTXO{
+            TxHash:        u.TxID,
+            OutputIndex:   u.Vout,
+            Value:         value,
+            Confirmations: int(scan.Height - u.Height + 1),
+            Script:        u.ScriptPubKey,
+        })
+    }
+    return utxos, nil
+}
+
+func (c *CoreRPCClient) GetBalance(ctx context.Context, address string) (models.Amount, error) {
+    utxos, err := c.GetUTXOs(ctx, address)
+    if err != nil {
+        return 0, err
+    }
+    var total models.Amount
+    for _, u := range utxos {
+        total += u.Value
+    }
+    return total, nil
+}
+
+// GetAddressInfo reports the balance from the address's UTXOs. Without an
+// address index the node cannot list history, so totals and txrefs are only
+// what the unspent outputs reveal.
+func (c *CoreRPCClient) GetAddressInfo(ctx context.Context, address string) (models.AddressOverview, error) {
+    var info models.AddressOverview
+    utxos, err := c.GetUTXOs(ctx, address)
+    if err != nil {
+        return info, err
+    }
+    chain, err := c.ChainInfo(ctx)
+    if err != nil {
+        return info, err
+    }
+    seen := map[string]bool{}
+    for _, u := range utxos {
+        if u.Confirmations > 0 {
+            info.Balance += u.Value
+        } else {
+            info.UnconfirmedBalance += u.Value
+        }
+        info.TotalReceived += u.Value
+        if !seen[u.TxHash] {
+            seen[u.TxHash] = true
+            ref := models.Transaction{Hash: u.TxHash, Confirmations: u.Confirmations, Value: u.Value, Addresses: []string{address}}
+            if u.Confirmations > 0 {
+                ref.BlockHeight = chain.Blocks - int64(u.Confirmations) + 1
+            }
+            info.Txrefs = append(info.Txrefs, ref)
+        }
+    }
+    info.NTx = len(info.Txrefs)
+    return info, nil
+}
+
+// coreHistoryPage is how many wallet entries one listtransactions call
+// asks for. Entries of other addresses count too, so a page of this
+// address's history can be shorter, or empty.
+const coreHistoryPage = 100
+
+// rpcWalletTx is the subset of gettransaction the history uses.
+type rpcWalletTx struct {
+    TxID          string `json:"txid"`
+    Confirmations int    `json:"confirmations"`
+    BlockHeight   int64  `json:"blockheight"`
+    Time          int64  `json:"time"`
+    Details       []struct {
+        Address  string      `json:"address"`
+        Category string      `json:"category"`
+        Amount   json.Number `json:"amount"`
+        Vout     int         `json:"vout"`
+    } `json:"details"`
+    Decoded struct {
+        Vin []struct {
+            TxID string `json:"txid"`
+            Vout int    `json:"vout"`
+        } `json:"vin"`
+    } `json:"decoded"`
+}
+
+// received is what tx pays address at output vout, or at every output
+// when vout is negative.
+func (tx rpcWalletTx) received(address string, vout int) (models.Amount, bool, error) {
+    var total models.Amount
+    found := false
+    for _, d := range tx.Details {
+        if d.Address != address || (vout >= 0 && d.Vout != vout) {
+            continue
+        }
+        switch d.Category {
+        case "receive", "generate", "immature":
+            value, err := parseCoins(d.Amount)
+            if err != nil {
+                return 0, false, err
+            }
+            total += value
+            found = true
+        }
+    }
+    return total, found, nil
+}
+
+// HistoryPage pages through the transactions of the watch-only Wallet with
+// listtransactions, keeping those that pay or spend address. The cursor is
+// how many wallet entries to skip. Without a Wallet the node cannot list
+// history, and the only page is what GetAddressInfo finds.
+func (c *CoreRPCClient) HistoryPage(ctx context.Context, address, cursor string) ([]models.Transaction, string, error) {
+    if c.Wallet == "" {
+        info, err := c.GetAddressInfo(ctx, address)
+        return info.Txrefs, "", err
+    }
+    skip, err := strconv.Atoi(cursor)
+    if cursor != "" && (err != nil || skip < 0) {
+        return nil, "", fmt.Errorf("litecoind: bad history cursor %q", cursor)
+    }
+    var entries []struct {
+        TxID string `json:"txid"`
+    }
+    if err := c.call(ctx, c.walletURL(), "listtransactions", &entries, "*", coreHistoryPage, skip, true); err != nil {
+        return nil, "", err
+    }
+    wallet := map[string]rpcWalletTx{}
+    lookup := func(txid string) (rpcWalletTx, error) {
+        if tx, ok := wallet[txid]; ok {
+            return tx, nil
+        }
+        var tx rpcWalletTx
+        err := c.call(ctx, c.walletURL(), "gettransaction", &tx, txid, true, true)
+        if err == nil {
+            wallet[txid] = tx
+        }
+        return tx, err
+    }
+    var txs []models.Transaction
+    seen := map[string]bool{}
+    // listtransactions lists oldest first.
+    for i := len(entries) - 1; i >= 0; i-- {
+        txid := entries[i].TxID
+        if seen[txid] {
+            continue
+        }
+        seen[txid] = true
+        tx, err := lookup(txid)
+        if err != nil {
+            return nil, "", err
+        }
+        value, ours, err := tx.received(address, -1)
+        if err != nil {
+            return nil, "", err
+        }
+        for _, in := range tx.Decoded.Vin {
+            prev, err := lookup(in.TxID)
+            if errors.Is(err, ErrTxNotFound) {
+                // not a wallet transaction, so not a coin of ours
+                continue
+            }
+            if err != nil {
+                return nil, "", err
+            }
+            spent, found, err := prev.received(address, in.Vout)
+            if err != nil {
+                return nil, "", err
+            }
+            value -= spent
+            ours = ours || found
+        }
+        if !ours {
+            continue
+        }
+        ref := models.Transaction{Hash: txid, BlockHeight: -1, Confirmations: tx.Confirmations, Value: value, Addresses: []string{address}}
+        if tx.Confirmations > 0 {
+            ref.BlockHeight = tx.BlockHeight
+        }
+        if tx.Time > 0 {
+            ref.Received = time.Unix(tx.Time, 0).UTC().Format(time.RFC3339)
+        }
+        txs = append(txs, ref)
+    }
+    next := ""
+    if len(entries) == coreHistoryPage {
+        next = strconv.Itoa(skip + len(entries))
+    }
+    return txs, next, nil
+}
+
+// EstimateFee asks estimatesmartfee for 12, 6 and 2 block targets. When the
+// node has no estimate yet (fresh node, regtest) its relay fee is used.
+func (c *CoreRPCClient) EstimateFee(ctx context.Context) (models.FeeEstimate, error) {
+    var fees models.FeeEstimate
+    var relay struct {
+        RelayFee json.Number `json:"relayfee"`
+    }
+    if err := c.call(ctx, c.URL, "getnetworkinfo", &relay); err != nil {
+        return fees, err
+    }
+    floor, err := parseFeeRate(relay.RelayFee)
+    if err != nil {
+        return fees, err
+    }
+    for _, t := range []struct {
+        blocks int
+        dst    *int64
+    }{{12, &fees.LowPerKB}, {6, &fees.MediumPerKB}, {2, &fees.HighPerKB}} {
+        var est struct {
+            FeeRate json.Number `json:"feerate"`
+        }
+        if err := c.call(ctx, c.URL, "estimatesmartfee", &est, t.blocks); err != nil {
+            return fees, err
+        }
+        *t.dst = floor
+        if est.FeeRate != "" {
+            rate, err := parseFeeRate(est.FeeRate)
+            if err != nil {
+                return fees, err
+            }
+            if rate > floor {
+                *t.dst = rate
+            }
+        }
+    }
+    return fees, nil
+}
+
+type rpcTx struct {
+    TxID          string `json:"txid"`
+    Size          int    `json:"size"`
+    VSize         int    `json:"vsize"`
+    BlockHash     string `json:"blockhash"`
+    Confirmations int    `json:"confirmations"`
+    Time          int64  `json:"time"`
+    Vin           []struct {
+        TxID     string `json:"txid"`
+        Vout     int    `json:"vout"`
+        Coinbase string `json:"coinbase"`
+    } `json:"vin"`
+    Vout []struct {
+        Value        json.Number `json:"value"`
+        N            int         `json:"n"`
+        ScriptPubKey struct {
+            Hex       string   `json:"hex"`
+            Address   string   `json:"address"`
+            Addresses []string `json:"addresses"`
+        } `json:"scriptPubKey"`
+    } `json:"vout"`
+}
+
+func (c *CoreRPCClient) rawTransaction(ctx context.Context, txid string) (rpcTx, error) {
+    var tx rpcTx
+    err := c.call(ctx, c.URL, "getrawtransaction", &tx, txid, true)
+    return tx, err
+}
+
+// GetTransaction looks txid up with getrawtransaction, which needs -txindex
+// for confirmed transactions not in the wallet. Input values are filled in
+// from the spent transactions so the fee can be reported.
+func (c *CoreRPCClient) GetTransaction(ctx context.Context, txid string) (models.TxDetail, error) {
+    tx, err := c.rawTransaction(ctx, txid)
+    if err != nil {
+        return models.TxDetail{}, err
+    }
+    detail := models.TxDetail{
+        Hash:          tx.TxID,
+        BlockHeight:   -1,
+        Confirmations: tx.Confirmations,
+        Size:          tx.Size,
+        VSize:         tx.VSize,
+    }
+    if tx.BlockHash != "" {
+        var header struct {
+            Height int64 `json:"height"`
+        }
+        if err := c.call(ctx, c.URL, "getblockheader", &header, tx.BlockHash); err != nil {
+            return detail, err
+        }
+        detail.BlockHeight = header.Height
+    }
+    var outTotal models.Amount
+    for _, out := range tx.Vout {
+        value, err := parseCoins(out.Value)
+        if err != nil {
+            return detail, err
+        }
+        addrs := out.ScriptPubKey.Addresses
+        if out.ScriptPubKey.Address != "" {
+            addrs = []string{out.ScriptPubKey.Address}
+        }
+        outTotal += value
+        detail.Outputs = append(detail.Outputs, models.TxOutput{Value: value, Addresses: addrs, Script: out.ScriptPubKey.Hex})
+    }
+    var inTotal models.Amount
+    prevs := map[string]rpcTx{}
+    for _, in := range tx.Vin {
+        if in.Coinbase != "" {
+            continue
+        }
+        prev, ok := prevs[in.TxID]
+        if !ok {
+            if prev, err = c.rawTransaction(ctx, in.TxID); err != nil {
+                return detail, err
+            }
+            prevs[in.TxID] = prev
+        }
+        if in.Vout >= len(prev.Vout) {
+            return detail, fmt.Errorf("litecoind: input %s:%d does not exist", in.TxID, in.Vout)
+        }
+        spent := prev.Vout[in.Vout]
+        value, err := parseCoins(spent.Value)
+        if err != nil {
+            return detail, err
+        }
+        addrs := spent.ScriptPubKey.Addresses
+        if spent.ScriptPubKey.Address != "" {
+            addrs = []string{spent.ScriptPubKey.Address}
+        }
+        inTotal += value
+        detail.Inputs = append(detail.Inputs, models.TxInput{PrevHash: in.TxID, OutputIndex: in.Vout, Value: value, Addresses: addrs})
+    }
+    if len(detail.Inputs) > 0 {
+        detail.Fees = inTotal - outTotal
+    }
+    return detail, nil
+}
+
+// BroadcastRawTx submits the transaction with sendrawtransaction.
+func (c *CoreRPCClient) BroadcastRawTx(ctx context.Context, rawHex string) (string, error) {
+    var txid string
+    if err := c.call(ctx, c.URL, "sendrawtransaction", &txid, rawHex); err != nil {
+        var re *RPCError
+        if errors.As(err, &re) {
+            return "", &BroadcastError{Provider: c.Name(), Reason: re.Message, Err: err}
+        }
+        return "", fmt.Errorf("broadcast: %w", err)
+    }
+    return txid, nil
+}
+
+// parseCoins converts a JSON amount in LTC, such as 0.00012345 or 1e-05, to
+// litoshis without going through float64. Sub-litoshi amounts are an error.
+func parseCoins(n json.Number) (models.Amount, error) {
+    r, ok := new(big.Rat).SetString(string(n))
+    if !ok {
+        return 0, fmt.Errorf("invalid amount %q", string(n))
+    }
+    r.Mul(r, big.NewRat(1e8, 1))
+    if !r.IsInt() || !r.Num().IsInt64() {
+        return 0, fmt.Errorf("invalid amount %q", string(n))
+    }
+    return models.Amount(r.Num().Int64()), nil
+}
+
+// parseFeeRate converts a fee rate in LTC/kB to litoshis per kB, rounding
+// any fraction of a litoshi up.
+func parseFeeRate(n json.Number) (int64, error) {
+    r, ok := new(big.Rat).SetString(string(n))
+    if !ok {
+        return 0, fmt.Errorf("invalid fee rate %q", string(n))
+    }
+    r.Mul(r, big.NewRat(1e8, 1))
+    q, m := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+    if m.Sign() != 0 {
+        q.Add(q, big.NewInt(1))
+    }
+    if !q.IsInt64() {
+        return 0, fmt.Errorf("invalid fee rate %q", string(n))
+    }
+    return q.Int64(), nil
+}
@@ -0,0 +1,295 @@
+package api
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "litecoin-wallet/internal/crypto"
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+)
+
+// fakeCore is an httptest stand-in for litecoind. Handlers map RPC methods
+// to canned results; a handler returning *RPCError becomes an RPC error.
+type fakeCore struct {
+    t        *testing.T
+    user     string
+    pass     string
+    handlers map[string]func(params []json.RawMessage) interface{}
+    calls    []string
+}
+
+func (f *fakeCore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if u, p, ok := r.BasicAuth(); !ok || u != f.user || p != f.pass {
+        w.WriteHeader(http.StatusUnauthorized)
+        return
+    }
+    var req struct {
+        ID     json.RawMessage   `json:"id"`
+        Method string            `json:"method"`
+        Params []json.RawMessage `json:"params"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        f.t.Fatal(err)
+    }
+    f.calls = append(f.calls, r.URL.Path+" "+req.Method)
+    reply := map[string]interface{}{"id": req.ID, "result": nil, "error": nil}
+    h, ok := f.handlers[req.Method]
+    if !ok {
+        reply["error"] = &RPCError{Code: -32601, Message: "Method not found"}
+        w.WriteHeader(http.StatusNotFound)
+    } else if res := h(req.Params); res != nil {
+        if rpcErr, ok := res.(*RPCError); ok {
+            reply["error"] = rpcErr
+            w.WriteHeader(http.StatusInternalServerError)
+        } else {
+            reply["result"] = res
+        }
+    }
+    json.NewEncoder(w).Encode(reply)
+}
+
+func newFakeCore(t *testing.T) (*fakeCore, *CoreRPCClient) {
+    f := &fakeCore{t: t, user: "alice", pass: "secret", handlers: map[string]func([]json.RawMessage) interface{}{}}
+    srv := httptest.NewServer(f)
+    t.Cleanup(srv.Close)
+    return f, NewCoreRPCClient(&netparams.MainNetParams, srv.URL, "alice", "secret", "", "")
+}
+
+func rawJSON(s string) func([]json.RawMessage) interface{} {
+    return func([]json.RawMessage) interface{} { return json.RawMessage(s) }
+}
+
+func TestCoreRPCScanUTXOs(t *testing.T) {
+    f, c := newFakeCore(t)
+    addr := "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ"
+    f.handlers["scantxoutset"] = func(params []json.RawMessage) interface{} {
+        if string(params[0]) != `"start"` || string(params[1]) != `["addr(`+addr+`)"]` {
+            t.Errorf("scantxoutset params = %s", params)
+        }
+        return json.RawMessage(`{"success":true,"height":100,"unspents":[
+            {"txid":"aa","vout":1,"scriptPubKey":"76a9","amount":0.12345678,"height":100},
+            {"txid":"bb","vout":0,"scriptPubKey":"76a9","amount":2.00000000,"height":91}]}`)
+    }
+    utxos, err := c.GetUTXOs(context.Background(), addr)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(utxos) != 2 || utxos[0].Value != 12345678 || utxos[1].Value != 200000000 {
+        t.Fatalf("utxos = %+v", utxos)
+    }
+    if utxos[0].Confirmations != 1 || utxos[1].Confirmations != 10 {
+        t.Errorf("confirmations = %d, %d, want 1, 10", utxos[0].Confirmations, utxos[1].Confirmations)
+    }
+    balance, err := c.GetBalance(context.Background(), addr)
+    if err != nil || balance != 212345678 {
+        t.Errorf("balance = %d, %v", balance, err)
+    }
+}
+
+func TestCoreRPCListUnspentUsesWallet(t *testing.T) {
+    f, c := newFakeCore(t)
+    c.Wallet = "watch"
+    f.handlers["listunspent"] = rawJSON(`[{"txid":"cc","vout":2,"amount":0.001,"confirmations":0,"scriptPubKey":"0014"}]`)
+    utxos, err := c.GetUTXOs(context.Background(), "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(utxos) != 1 || utxos[0].Value != 100000 || utxos[0].Confirmations != 0 {
+        t.Fatalf("utxos = %+v", utxos)
+    }
+    if f.calls[0] != "/wallet/watch listunspent" {
+        t.Errorf("call = %q, want wallet endpoint", f.calls[0])
+    }
+}
+
+func TestCoreRPCHistoryPagesThroughWallet(t *testing.T) {
+    f, c := newFakeCore(t)
+    c.Wallet = "watch"
+    addr := "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ"
+    // r1 pays us 1 LTC; s1 spends it, sending 0.3 away with 0.69 change
+    // back. Other addresses' transactions fill more than a page between.
+    txs := map[string]string{
+        "r1": `{"txid":"r1","confirmations":200,"blockheight":100,"time":1700000000,
+            "details":[{"address":"` + addr + `","category":"receive","amount":1.0,"vout":0}],
+            "decoded":{"vin":[{"txid":"outside","vout":0}]}}`,
+        "s1": `{"txid":"s1","confirmations":0,"time":1700009999,
+            "details":[{"address":"Lother","category":"send","amount":-0.3,"vout":0},
+                       {"address":"` + addr + `","category":"receive","amount":0.69,"vout":1}],
+            "decoded":{"vin":[{"txid":"r1","vout":0}]}}`,
+    }
+    entries := []string{"r1"}
+    for i := 0; i < coreHistoryPage; i++ {
+        id := fmt.Sprintf("o%d", i)
+        entries = append(entries, id)
+        txs[id] = `{"txid":"` + id + `","confirmations":50,"blockheight":150,
+            "details":[{"address":"Lother","category":"receive","amount":0.1,"vout":0}],
+            "decoded":{"vin":[{"txid":"outside","vout":0}]}}`
+    }
+    entries = append(entries, "s1", "s1")
+    f.handlers["listtransactions"] = func(params []json.RawMessage) interface{} {
+        var count, skip int
+        json.Unmarshal(params[1], &count)
+        json.Unmarshal(params[2], &skip)
+        end := max(len(entries)-skip, 0)
+        var out []map[string]string
+        for _, id := range entries[max(end-count, 0):end] {
+            out = append(out, map[string]string{"txid": id})
+        }
+        return out
+    }
+    f.handlers["gettransaction"] = func(params []json.RawMessage) interface{} {
+        var txid string
+        json.Unmarshal(params[0], &txid)
+        if raw, ok := txs[txid]; ok {
+            return json.RawMessage(raw)
+        }
+        return &RPCError{Code: -5, Message: "Invalid or non-wallet transaction id"}
+    }
+
+    history, err := FullHistory(context.Background(), c, addr)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(history) != 2 || history[0].Hash != "s1" || history[1].Hash != "r1" {
+        t.Fatalf("history = %+v, want s1 then r1", history)
+    }
+    if history[0].Value != -31_000_000 || history[0].BlockHeight != -1 {
+        t.Errorf("s1 = %+v, want -0.31 LTC unconfirmed", history[0])
+    }
+    if history[1].Value != 100_000_000 || history[1].BlockHeight != 100 || history[1].Received == "" {
+        t.Errorf("r1 = %+v, want +1 LTC at height 100", history[1])
+    }
+}
+
+func TestCoreRPCEstimateFee(t *testing.T) {
+    f, c := newFakeCore(t)
+    f.handlers["getnetworkinfo"] = rawJSON(`{"relayfee":0.00001000}`)
+    f.handlers["estimatesmartfee"] = func(params []json.RawMessage) interface{} {
+        switch string(params[0]) {
+        case "2":
+            return json.RawMessage(`{"feerate":0.00020000,"blocks":2}`)
+        case "6":
+            return json.RawMessage(`{"feerate":0.00005000,"blocks":6}`)
+        }
+        return json.RawMessage(`{"errors":["Insufficient data or no feerate found"],"blocks":0}`)
+    }
+    fees, err := c.EstimateFee(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if fees.LowPerKB != 1000 || fees.MediumPerKB != 5000 || fees.HighPerKB != 20000 {
+        t.Errorf("fees = %+v", fees)
+    }
+}
+
+func TestCoreRPCGetTransaction(t *testing.T) {
+    f, c := newFakeCore(t)
+    f.handlers["getrawtransaction"] = func(params []json.RawMessage) interface{} {
+        switch string(params[0]) {
+        case `"child"`:
+            return json.RawMessage(`{"txid":"child","size":225,"vsize":225,"blockhash":"bh","confirmations":3,
+                "vin":[{"txid":"parent","vout":1}],
+                "vout":[{"value":0.5,"n":0,"scriptPubKey":{"hex":"76a9","address":"Ldest"}}]}`)
+        case `"parent"`:
+            return json.RawMessage(`{"txid":"parent","vin":[{"coinbase":"03"}],
+                "vout":[{"value":1,"n":0,"scriptPubKey":{"hex":"00"}},{"value":0.5001,"n":1,"scriptPubKey":{"hex":"76a9","addresses":["Lsrc"]}}]}`)
+        }
+        return &RPCError{Code: -5, Message: "No such mempool or blockchain transaction"}
+    }
+    f.handlers["getblockheader"] = rawJSON(`{"height":2500000}`)
+    tx, err := c.GetTransaction(context.Background(), "child")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if tx.BlockHeight != 2500000 || tx.Fees != 10000 || tx.Confirmations != 3 {
+        t.Errorf("tx = %+v", tx)
+    }
+    if len(tx.Inputs) != 1 || tx.Inputs[0].Addresses[0] != "Lsrc" || tx.Outputs[0].Addresses[0] != "Ldest" {
+        t.Errorf("inputs/outputs = %+v / %+v", tx.Inputs, tx.Outputs)
+    }
+    _, err = c.GetTransaction(context.Background(), "missing")
+    var rpcErr *RPCError
+    if !errors.As(err, &rpcErr) || rpcErr.Code != -5 {
+        t.Errorf("err = %v, want RPC error -5", err)
+    }
+}
+
+func TestCoreRPCBroadcastAndSend(t *testing.T) {
+    f, c := newFakeCore(t)
+    m, w := fundedMemoryProvider(t, crypto.AddrP2PKH, 1_000_000)
+    var pushed string
+    f.handlers["scantxoutset"] = func([]json.RawMessage) interface{} {
+        u := m.UTXOs[w.Address][0]
+        return map[string]interface{}{"success": true, "height": 10, "unspents": []interface{}{
+            map[string]interface{}{"txid": u.TxHash, "vout": u.OutputIndex, "scriptPubKey": u.Script, "amount": json.Number("0.01"), "height": 5},
+        }}
+    }
+    f.handlers["getnetworkinfo"] = rawJSON(`{"relayfee":0.00001}`)
+    f.handlers["estimatesmartfee"] = rawJSON(`{"feerate":0.0001}`)
+    f.handlers["sendrawtransaction"] = func(params []json.RawMessage) interface{} {
+        json.Unmarshal(params[0], &pushed)
+        return "feedface"
+    }
+    txid, err := testSend(c, w.Address, w.Address, 500_000)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if txid != "feedface" || pushed == "" {
+        t.Errorf("txid = %q, pushed %d hex chars", txid, len(pushed))
+    }
+
+    f.handlers["sendrawtransaction"] = func([]json.RawMessage) interface{} {
+        return &RPCError{Code: -26, Message: "min relay fee not met"}
+    }
+    _, err = c.BroadcastRawTx(context.Background(), "00")
+    var be *BroadcastError
+    if !errors.As(err, &be) || be.Reason != "min relay fee not met" {
+        t.Errorf("err = %v, want rejection with reason", err)
+    }
+}
+
+func TestCoreRPCCookieAuthAndChainCheck(t *testing.T) {
+    f, _ := newFakeCore(t)
+    f.user, f.pass = "__cookie__", "c00k1e"
+    f.handlers["getblockchaininfo"] = rawJSON(`{"chain":"test","blocks":42}`)
+    srv := httptest.NewServer(f)
+    defer srv.Close()
+    cookie := filepath.Join(t.TempDir(), ".cookie")
+    os.WriteFile(cookie, []byte("__cookie__:c00k1e\n"), 0o600)
+
+    c := NewCoreRPCClient(&netparams.TestNet4Params, srv.URL, "", "", cookie, "")
+    info, err := c.ChainInfo(context.Background())
+    if err != nil || info.Blocks != 42 {
+        t.Fatalf("info = %+v, err %v", info, err)
+    }
+    c = NewCoreRPCClient(&netparams.MainNetParams, srv.URL, "", "", cookie, "")
+    if _, err := c.ChainInfo(context.Background()); err == nil {
+        t.Error("expected network mismatch error")
+    }
+    c = NewCoreRPCClient(&netparams.TestNet4Params, srv.URL, "alice", "wrong", "", "")
+    if _, err := c.ChainInfo(context.Background()); err == nil {
+        t.Error("expected authentication failure")
+    }
+}
+
+func TestParseCoins(t *testing.T) {
+    for in, want := range map[string]models.Amount{"0": 0, "1": 100000000, "0.00000001": 1, "84000000.12345678": 8400000012345678, "-0.5": -50000000, "1e-05": 1000} {
+        if got, err := parseCoins(json.Number(in)); err != nil || got != want {
+            t.Errorf("parseCoins(%s) = %d, %v; want %d", in, got, err, want)
+        }
+    }
+    if _, err := parseCoins("0.000000001"); err == nil {
+        t.Error("expected sub-litoshi amount to fail")
+    }
+    if got, err := parseFeeRate("0.000123451"); err != nil || got != 12346 {
+        t.Errorf("parseFeeRate = %d, %v; want 12346 (rounded up)", got, err)
+    }
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+    "context"
+    "errors"
+    "fmt"
+
+    "litecoin-wallet/internal/crypto"
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "litecoin-wallet/internal/txbuilder"
+    "github.com/btcsuite/btcd/chaincfg"
+)
+
+// CPFPRequest asks to speed up an unconfirmed transaction paying us by
+// spending what it pays us back to Address.
+type CPFPRequest struct {
+    Params     *chaincfg.Params
+    Address    string
+    // Addresses are where the parent's outputs to us are looked for, for a
+    // wallet with more than one; empty means Address alone.
+    Addresses  []string
+    ParentTxid string
+    // Frozen outpoints are never spent, even if the parent pays them.
+    Frozen map[string]bool
+    // FeePerKB is the target rate for parent and child together, in
+    // litoshis per kB; 0 means the provider's FeeNormal estimate.
+    FeePerKB int64
+}
+
+// BuildCPFP looks up the parent and our unspent outputs of it and builds
+// the unsigned child. It fails with ErrAlreadyConfirmed if the parent made
+// it into a block meanwhile and with ErrNoUTXOs if it pays us nothing we
+// can spend.
+func BuildCPFP(ctx context.Context, p ChainProvider, req CPFPRequest) (*txbuilder.Package, error) {
+    addr, err := netparams.DecodeAddress(req.Address, req.Params)
+    if err != nil {
+        return nil, fmt.Errorf("invalid address: %w", err)
+    }
+    parent, err := p.GetTransaction(ctx, req.ParentTxid)
+    if err != nil {
+        return nil, err
+    }
+    if parent.BlockHeight > 0 || parent.Confirmations > 0 {
+        return nil, ErrAlreadyConfirmed
+    }
+    vsize := int64(parent.VSize)
+    if vsize <= 0 {
+        vsize = int64(parent.Size)
+    }
+    utxos, err := ListUTXOs(ctx, p, spendFrom(req.Address, req.Addresses)...)
+    if err != nil {
+        return nil, err
+    }
+    var ours []models.UTXO
+    for _, u := range utxos {
+        if u.TxHash == req.ParentTxid && !req.Frozen[u.Outpoint()] {
+            ours = append(ours, u)
+        }
+    }
+    if len(ours) == 0 {
+        return nil, fmt.Errorf("%w: %s pays us nothing unspent", ErrNoUTXOs, req.ParentTxid)
+    }
+    feePerKB := req.FeePerKB
+    if feePerKB <= 0 {
+        fees, err := p.EstimateFee(ctx)
+        if err != nil {
+            return nil, err
+        }
+        feePerKB = FeeNormal.PerKB(fees)
+    }
+    pkg, err := txbuilder.CPFP(txbuilder.Request{
+        Params:   req.Params,
+        From:     req.Address,
+        AddrType: crypto.AddressTypeOf(addr),
+        UTXOs:    ours,
+    }, parent.Fees, vsize, feePerKB)
+    if errors.Is(err, txbuilder.ErrInsufficientFunds) {
+        return nil, fmt.Errorf("%w: the output is too small to pay for the package", err)
+    }
+    return pkg, err
+}
@@ -0,0 +1,595 @@
+package api
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "crypto/tls"
+    "encoding/binary"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "github.com/btcsuite/btcd/chaincfg"
+    "github.com/btcsuite/btcd/chaincfg/chainhash"
+    "github.com/btcsuite/btcd/txscript"
+    "github.com/btcsuite/btcd/wire"
+)
+
+// ElectrumClient speaks the Electrum protocol (as served by ElectrumX and
+// compatible Litecoin servers): newline-delimited JSON-RPC 2.0 over TCP or
+// TLS. Requests are sent one at a time on a single connection that is
+// reopened after any error.
+type ElectrumClient struct {
+    // Server is host:port.
+    Server string
+    TLS    bool
+    // InsecureSkipVerify accepts self-signed certificates, which many
+    // Electrum servers use.
+    InsecureSkipVerify bool
+    Params             *chaincfg.Params
+    // Timeout bounds calls made without a context deadline.
+    Timeout time.Duration
+
+    mu     sync.Mutex
+    conn   net.Conn
+    reader *bufio.Reader
+    nextID uint64
+    txs    map[string]*wire.MsgTx
+    times  map[int64]int64
+}
+
+var _ ChainProvider = (*ElectrumClient)(nil)
+
+// historyLimit is how many recent transactions GetAddressInfo returns, the
+// same as the BlockCypher overview.
+const historyLimit = 10
+
+// electrumHistoryPage is how many transactions HistoryPage returns at once.
+const electrumHistoryPage = 25
+
+// ElectrumError is an error object returned by the server.
+type ElectrumError struct {
+    Code    int    `json:"code"`
+    Message string `json:"message"`
+}
+
+func (e *ElectrumError) Error() string { return fmt.Sprintf("electrum: %s (code %d)", e.Message, e.Code) }
+
+// Is matches ErrTxNotFound for the daemon error ElectrumX passes on for an
+// unknown txid.
+func (e *ElectrumError) Is(target error) bool {
+    return target == ErrTxNotFound && strings.Contains(e.Message, "No such mempool or blockchain transaction")
+}
+
+// NewElectrumClient parses server as "host:port", "tcp://host:port" or
+// "ssl://host:port" (also "tls://"). Without a scheme TLS is used.
+func NewElectrumClient(params *chaincfg.Params, server string, insecure bool) (*ElectrumClient, error) {
+    useTLS := true
+    if scheme, rest, ok := strings.Cut(server, "://"); ok {
+        switch strings.ToLower(scheme) {
+        case "tcp":
+            useTLS = false
+        case "ssl", "tls":
+        default:
+            return nil, fmt.Errorf("unknown Electrum scheme %q (use tcp:// or ssl://)", scheme)
+        }
+        server = rest
+    }
+    if _, _, err := net.SplitHostPort(server); err != nil {
+        return nil, fmt.Errorf("invalid Electrum server %q: %v", server, err)
+    }
+    return &ElectrumClient{
+        Server:             server,
+        TLS:                useTLS,
+        InsecureSkipVerify: insecure,
+        Params:             params,
+        Timeout:            DefaultTimeout,
+        txs:                map[string]*wire.MsgTx{},
+        times:              map[int64]int64{},
+    }, nil
+}
+
+func (e *ElectrumClient) Name() string { return "electrum" }
+
+// Close drops the connection; the next call reconnects.
+func (e *ElectrumClient) Close() error {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    return e.closeLocked()
+}
+
+func (e *ElectrumClient) closeLocked() error {
+    if e.conn == nil {
+        return nil
+    }
+    err := e.conn.Close()
+    e.conn, e.reader = nil, nil
+    return err
+}
+
+func (e *ElectrumClient) connectLocked(ctx context.Context) error {
+    if e.conn != nil {
+        return nil
+    }
+    var d net.Dialer
+    conn, err := d.DialContext(ctx, "tcp", e.Server)
+    if err != nil {
+        return err
+    }
+    if e.TLS {
+        host, _, _ := net.SplitHostPort(e.Server)
+        tc := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: e.InsecureSkipVerify})
+        if err := tc.HandshakeContext(ctx); err != nil {
+            conn.Close()
+            return err
+        }
+        conn = tc
+    }
+    e.conn, e.reader = conn, bufio.NewReader(conn)
+    // The protocol requires server.version before anything else.
+    var version []string
+    if err := e.roundTripLocked(ctx, "server.version", &version, "crypto-transit", "1.4"); err != nil {
+        e.closeLocked()
+        return err
+    }
+    return nil
+}
+
+// call sends one request and waits for its response, skipping any
+// subscription notifications that arrive in between.
+func (e *ElectrumClient) call(ctx context.Context, method string, out interface{}, params ...interface{}) error {
+    ctx, cancel := withTimeout(ctx, e.Timeout)
+    defer cancel()
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    if err := e.connectLocked(ctx); err != nil {
+        return fmt.Errorf("electrum: connect %s: %w", e.Server, ctxErr(ctx, &transportError{err}))
+    }
+    err := e.roundTripLocked(ctx, method, out, params...)
+    if _, isServerErr := err.(*ElectrumError); err != nil && !isServerErr {
+        e.closeLocked()
+    }
+    return err
+}
+
+func (e *ElectrumClient) roundTripLocked(ctx context.Context, method string, out interface{}, params ...interface{}) error {
+    if params == nil {
+        params = []interface{}{}
+    }
+    e.nextID++
+    id := e.nextID
+    req, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+    if err != nil {
+        return err
+    }
+    // Cancelling ctx unblocks the read by expiring the connection deadline.
+    conn := e.conn
+    conn.SetDeadline(time.Time{})
+    stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+    defer func() {
+        if !stop() {
+            e.closeLocked()
+        }
+    }()
+    if _, err := conn.Write(append(req, '\n')); err != nil {
+        return ctxErr(ctx, &transportError{err})
+    }
+    for {
+        line, err := e.reader.ReadBytes('\n')
+        if err != nil {
+            return ctxErr(ctx, &transportError{err})
+        }
+        var reply struct {
+            ID     *uint64         `json:"id"`
+            Result json.RawMessage `json:"result"`
+            Error  *ElectrumError  `json:"error"`
+        }
+        dec := json.NewDecoder(bytes.NewReader(line))
+        dec.UseNumber()
+        if err := dec.Decode(&reply); err != nil {
+            return fmt.Errorf("electrum: malformed response: %v", err)
+        }
+        if reply.ID == nil || *reply.ID != id {
+            continue
+        }
+        if reply.Error != nil {
+            return reply.Error
+        }
+        if out == nil {
+            return nil
+        }
+        d := json.NewDecoder(bytes.NewReader(reply.Result))
+        d.UseNumber()
+        return d.Decode(out)
+    }
+}
+
+func ctxErr(ctx context.Context, err error) error {
+    if ctx.Err() != nil {
+        return ctx.Err()
+    }
+    return err
+}
+
+// scriptHash returns the Electrum script hash of address: the reversed
+// SHA-256 of its output script, in hex.
+func (e *ElectrumClient) scriptHash(address string) (string, []byte, error) {
+    addr, err := netparams.DecodeAddress(address, e.Params)
+    if err != nil {
+        return "", nil, err
+    }
+    script, err := txscript.PayToAddrScript(addr)
+    if err != nil {
+        return "", nil, err
+    }
+    sum := sha256.Sum256(script)
+    for i, j := 0, len(sum)-1; i < j; i, j = i+1, j-1 {
+        sum[i], sum[j] = sum[j], sum[i]
+    }
+    return hex.EncodeToString(sum[:]), script, nil
+}
+
+// TipHeight returns the server's current chain height.
+func (e *ElectrumClient) TipHeight(ctx context.Context) (int64, error) {
+    var tip struct {
+        Height int64 `json:"height"`
+    }
+    err := e.call(ctx, "blockchain.headers.subscribe", &tip)
+    return tip.Height, err
+}
+
+func confirmations(tip, height int64) int {
+    if height <= 0 {
+        return 0
+    }
+    return int(tip - height + 1)
+}
+
+func (e *ElectrumClient) GetBalance(ctx context.Context, address string) (models.Amount, error) {
+    sh, _, err := e.scriptHash(address)
+    if err != nil {
+        return 0, err
+    }
+    var bal struct {
+        Confirmed   models.Amount `json:"confirmed"`
+        Unconfirmed models.Amount `json:"unconfirmed"`
+    }
+    if err := e.call(ctx, "blockchain.scripthash.get_balance", &bal, sh); err != nil {
+        return 0, err
+    }
+    return bal.Confirmed + bal.Unconfirmed, nil
+}
+
+func (e *ElectrumClient) GetUTXOs(ctx context.Context, address string) ([]models.UTXO, error) {
+    sh, script, err := e.scriptHash(address)
+    if err != nil {
+        return nil, err
+    }
+    tip, err := e.TipHeight(ctx)
+    if err != nil {
+        return nil, err
+    }
+    var unspent []struct {
+        TxHash string        `json:"tx_hash"`
+        TxPos  uint32        `json:"tx_pos"`
+        Height int64         `json:"height"`
+        Value  models.Amount `json:"value"`
+    }
+    if err := e.call(ctx, "blockchain.scripthash.listunspent", &unspent, sh); err != nil {
+        return nil, err
+    }
+    utxos := make([]models.UTXO, 0, len(unspent))
+    for _, u := range unspent {
+        utxos = append(utxos, models.UTXO{
+            TxHash:        u.TxHash,
+            OutputIndex:   u.TxPos,
+            Value:         u.Value,
+            Confirmations: confirmations(tip, u.Height),
+            Script:        hex.EncodeToString(script),
+        })
+    }
+    return utxos, nil
+}
+
+type electrumHistoryItem struct {
+    TxHash string `json:"tx_hash"`
+    Height int64  `json:"height"`
+}
+
+// GetAddressInfo combines get_balance and get_history. Totals are computed
+// from every transaction in the history (transactions are cached, so this
+// is only slow the first time); Txrefs holds the newest ten with Value set to
+// the net change of the address's balance.
+func (e *ElectrumClient) GetAddressInfo(ctx context.Context, address string) (models.AddressOverview, error) {
+    var info models.AddressOverview
+    sh, script, err := e.scriptHash(address)
+    if err != nil {
+        return info, err
+    }
+    var bal struct {
+        Confirmed   models.Amount `json:"confirmed"`
+        Unconfirmed models.Amount `json:"unconfirmed"`
+    }
+    if err := e.call(ctx, "blockchain.scripthash.get_balance", &bal, sh); err != nil {
+        return info, err
+    }
+    info.Balance = bal.Confirmed + bal.Unconfirmed
+    info.UnconfirmedBalance = bal.Unconfirmed
+    history, err := e.history(ctx, sh)
+    if err != nil {
+        return info, err
+    }
+    info.NTx = len(history)
+    tip, err := e.TipHeight(ctx)
+    if err != nil {
+        return info, err
+    }
+    for i, h := range history {
+        in, out, err := e.netFlow(ctx, h.TxHash, script)
+        if err != nil {
+            return info, err
+        }
+        info.TotalReceived += out
+        info.TotalSent += in
+        if i < historyLimit {
+            info.Txrefs = append(info.Txrefs, e.txref(ctx, tip, h, address, out-in))
+        }
+    }
+    return info, nil
+}
+
+// HistoryPage returns electrumHistoryPage transactions of address starting
+// at the offset in cursor. The server sends the whole history list at once;
+// paging bounds the transaction fetches needed to value each entry.
+func (e *ElectrumClient) HistoryPage(ctx context.Context, address, cursor string) ([]models.Transaction, string, error) {
+    offset := 0
+    if cursor != "" {
+        var err error
+        if offset, err = strconv.Atoi(cursor); err != nil || offset < 0 {
+            return nil, "", fmt.Errorf("electrum: bad history cursor %q", cursor)
+        }
+    }
+    sh, script, err := e.scriptHash(address)
+    if err != nil {
+        return nil, "", err
+    }
+    history, err := e.history(ctx, sh)
+    if err != nil {
+        return nil, "", err
+    }
+    tip, err := e.TipHeight(ctx)
+    if err != nil {
+        return nil, "", err
+    }
+    end := min(offset+electrumHistoryPage, len(history))
+    var page []models.Transaction
+    for _, h := range history[min(offset, end):end] {
+        in, out, err := e.netFlow(ctx, h.TxHash, script)
+        if err != nil {
+            return nil, "", err
+        }
+        page = append(page, e.txref(ctx, tip, h, address, out-in))
+    }
+    next := ""
+    if end < len(history) {
+        next = strconv.Itoa(end)
+    }
+    return page, next, nil
+}
+
+// history returns the script hash's transactions, newest first: mempool
+// entries (height <= 0), then by descending block height.
+func (e *ElectrumClient) history(ctx context.Context, sh string) ([]electrumHistoryItem, error) {
+    var history []electrumHistoryItem
+    if err := e.call(ctx, "blockchain.scripthash.get_history", &history, sh); err != nil {
+        return nil, err
+    }
+    sort.SliceStable(history, func(i, j int) bool {
+        hi, hj := history[i].Height, history[j].Height
+        if (hi <= 0) != (hj <= 0) {
+            return hi <= 0
+        }
+        return hi > hj
+    })
+    return history, nil
+}
+
+// netFlow returns how much txid spends from (in) and pays to (out) script.
+func (e *ElectrumClient) netFlow(ctx context.Context, txid string, script []byte) (in, out models.Amount, err error) {
+    tx, err := e.rawTx(ctx, txid)
+    if err != nil {
+        return 0, 0, err
+    }
+    for _, o := range tx.TxOut {
+        if bytes.Equal(o.PkScript, script) {
+            out += models.Amount(o.Value)
+        }
+    }
+    if isCoinbase(tx) {
+        return in, out, nil
+    }
+    for _, txIn := range tx.TxIn {
+        prev, err := e.rawTx(ctx, txIn.PreviousOutPoint.Hash.String())
+        if err != nil {
+            return 0, 0, err
+        }
+        if idx := txIn.PreviousOutPoint.Index; int(idx) < len(prev.TxOut) && bytes.Equal(prev.TxOut[idx].PkScript, script) {
+            in += models.Amount(prev.TxOut[idx].Value)
+        }
+    }
+    return in, out, nil
+}
+
+// txref describes one history entry; value is the net change for address.
+func (e *ElectrumClient) txref(ctx context.Context, tip int64, h electrumHistoryItem, address string, value models.Amount) models.Transaction {
+    ref := models.Transaction{Hash: h.TxHash, Confirmations: confirmations(tip, h.Height), Value: value, Addresses: []string{address}}
+    if h.Height > 0 {
+        ref.BlockHeight = h.Height
+        if ts, err := e.blockTime(ctx, h.Height); err == nil {
+            ref.Received = time.Unix(ts, 0).UTC().Format(time.RFC3339)
+        }
+    }
+    return ref
+}
+
+// rawTx fetches and decodes a transaction, caching it by id.
+func (e *ElectrumClient) rawTx(ctx context.Context, txid string) (*wire.MsgTx, error) {
+    e.mu.Lock()
+    tx, ok := e.txs[txid]
+    e.mu.Unlock()
+    if ok {
+        return tx, nil
+    }
+    var rawHex string
+    if err := e.call(ctx, "blockchain.transaction.get", &rawHex, txid); err != nil {
+        return nil, err
+    }
+    raw, err := hex.DecodeString(rawHex)
+    if err != nil {
+        return nil, fmt.Errorf("electrum: bad transaction hex for %s", txid)
+    }
+    tx = new(wire.MsgTx)
+    if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+        return nil, fmt.Errorf("electrum: decode %s: %v", txid, err)
+    }
+    e.mu.Lock()
+    e.txs[txid] = tx
+    e.mu.Unlock()
+    return tx, nil
+}
+
+// blockTime returns the timestamp from the header at height.
+func (e *ElectrumClient) blockTime(ctx context.Context, height int64) (int64, error) {
+    e.mu.Lock()
+    ts, ok := e.times[height]
+    e.mu.Unlock()
+    if ok {
+        return ts, nil
+    }
+    var headerHex string
+    if err := e.call(ctx, "blockchain.block.header", &headerHex, height); err != nil {
+        return 0, err
+    }
+    header, err := hex.DecodeString(headerHex)
+    if err != nil || len(header) != 80 {
+        return 0, fmt.Errorf("electrum: bad header at height %d", height)
+    }
+    ts = int64(binary.LittleEndian.Uint32(header[68:72]))
+    e.mu.Lock()
+    e.times[height] = ts
+    e.mu.Unlock()
+    return ts, nil
+}
+
+func (e *ElectrumClient) addresses(script []byte) []string {
+    _, addrs, _, err := txscript.ExtractPkScriptAddrs(script, e.Params)
+    if err != nil {
+        return nil
+    }
+    out := make([]string, len(addrs))
+    for i, a := range addrs {
+        out[i] = a.EncodeAddress()
+    }
+    return out
+}
+
+// GetTransaction decodes the transaction and its inputs' previous outputs.
+// Confirmations come from the verbose form when the server supports it.
+func (e *ElectrumClient) GetTransaction(ctx context.Context, txid string) (models.TxDetail, error) {
+    detail := models.TxDetail{Hash: txid, BlockHeight: -1}
+    var verbose struct {
+        Confirmations int `json:"confirmations"`
+    }
+    if err := e.call(ctx, "blockchain.transaction.get", &verbose, txid, true); err == nil && verbose.Confirmations > 0 {
+        detail.Confirmations = verbose.Confirmations
+        if tip, err := e.TipHeight(ctx); err == nil {
+            detail.BlockHeight = tip - int64(verbose.Confirmations) + 1
+        }
+    }
+    tx, err := e.rawTx(ctx, txid)
+    if err != nil {
+        return detail, err
+    }
+    detail.Size = tx.SerializeSize()
+    detail.VSize = (tx.SerializeSizeStripped()*3 + detail.Size + 3) / 4
+    var inTotal, outTotal models.Amount
+    for _, o := range tx.TxOut {
+        outTotal += models.Amount(o.Value)
+        detail.Outputs = append(detail.Outputs, models.TxOutput{Value: models.Amount(o.Value), Addresses: e.addresses(o.PkScript), Script: hex.EncodeToString(o.PkScript)})
+    }
+    if isCoinbase(tx) {
+        return detail, nil
+    }
+    for _, in := range tx.TxIn {
+        op := in.PreviousOutPoint
+        prev, err := e.rawTx(ctx, op.Hash.String())
+        if err != nil {
+            return detail, err
+        }
+        if int(op.Index) >= len(prev.TxOut) {
+            return detail, fmt.Errorf("electrum: input %s does not exist", op)
+        }
+        spent := prev.TxOut[op.Index]
+        inTotal += models.Amount(spent.Value)
+        detail.Inputs = append(detail.Inputs, models.TxInput{PrevHash: op.Hash.String(), OutputIndex: int(op.Index), Value: models.Amount(spent.Value), Addresses: e.addresses(spent.PkScript)})
+    }
+    detail.Fees = inTotal - outTotal
+    return detail, nil
+}
+
+func isCoinbase(tx *wire.MsgTx) bool {
+    return len(tx.TxIn) == 1 && tx.TxIn[0].PreviousOutPoint.Index == wire.MaxPrevOutIndex &&
+        tx.TxIn[0].PreviousOutPoint.Hash == chainhash.Hash{}
+}
+
+// EstimateFee asks blockchain.estimatefee for 12, 6 and 2 blocks, falling
+// back to the server's relay fee when it has no estimate (-1).
+func (e *ElectrumClient) EstimateFee(ctx context.Context) (models.FeeEstimate, error) {
+    var fees models.FeeEstimate
+    var relay json.Number
+    if err := e.call(ctx, "blockchain.relayfee", &relay); err != nil {
+        return fees, err
+    }
+    floor, err := parseFeeRate(relay)
+    if err != nil {
+        return fees, err
+    }
+    for _, t := range []struct {
+        blocks int
+        dst    *int64
+    }{{12, &fees.LowPerKB}, {6, &fees.MediumPerKB}, {2, &fees.HighPerKB}} {
+        var est json.Number
+        if err := e.call(ctx, "blockchain.estimatefee", &est, t.blocks); err != nil {
+            return fees, err
+        }
+        *t.dst = floor
+        if rate, err := parseFeeRate(est); err == nil && rate > floor {
+            *t.dst = rate
+        }
+    }
+    return fees, nil
+}
+
+// BroadcastRawTx submits the transaction with blockchain.transaction.broadcast.
+func (e *ElectrumClient) BroadcastRawTx(ctx context.Context, rawHex string) (string, error) {
+    var txid string
+    if err := e.call(ctx, "blockchain.transaction.broadcast", &txid, rawHex); err != nil {
+        var ee *ElectrumError
+        if errors.As(err, &ee) {
+            return "", &BroadcastError{Provider: e.Name(), Reason: ee.Message, Err: err}
+        }
+        return "", fmt.Errorf("broadcast: %w", err)
+    }
+    return txid, nil
+}
@@ -0,0 +1,287 @@
+package api
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "crypto/tls"
+    "encoding/binary"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "net"
+    "net/http/httptest"
+    "reflect"
+    "sync"
+    "testing"
+    "time"
+
+    "litecoin-wallet/internal/crypto"
+    "litecoin-wallet/internal/netparams"
+    "github.com/btcsuite/btcd/btcutil"
+    "github.com/btcsuite/btcd/chaincfg/chainhash"
+    "github.com/btcsuite/btcd/txscript"
+    "github.com/btcsuite/btcd/wire"
+)
+
+// fakeElectrum is a line-based Electrum server on a local listener. Every
+// response is preceded by a headers notification, which clients must skip.
+type fakeElectrum struct {
+    t        *testing.T
+    mu       sync.Mutex
+    handlers map[string]func(params []json.RawMessage) interface{}
+    calls    []string
+}
+
+func (f *fakeElectrum) serve(l net.Listener) {
+    for {
+        conn, err := l.Accept()
+        if err != nil {
+            return
+        }
+        go f.handle(conn)
+    }
+}
+
+func (f *fakeElectrum) handle(conn net.Conn) {
+    defer conn.Close()
+    r := bufio.NewReader(conn)
+    for {
+        line, err := r.ReadBytes('\n')
+        if err != nil {
+            return
+        }
+        var req struct {
+            ID     uint64            `json:"id"`
+            Method string            `json:"method"`
+            Params []json.RawMessage `json:"params"`
+        }
+        if err := json.Unmarshal(line, &req); err != nil {
+            f.t.Errorf("bad request %q: %v", line, err)
+            return
+        }
+        f.mu.Lock()
+        f.calls = append(f.calls, req.Method)
+        h, ok := f.handlers[req.Method]
+        f.mu.Unlock()
+        if req.Method == "server.version" {
+            h, ok = func([]json.RawMessage) interface{} { return []string{"ElectrumX 1.16", "1.4"} }, true
+        }
+        reply := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+        if !ok {
+            reply["error"] = &ElectrumError{Code: -32601, Message: "unknown method"}
+        } else {
+            res := h(req.Params)
+            if res == nil {
+                continue // never answer
+            }
+            if e, isErr := res.(*ElectrumError); isErr {
+                reply["error"] = e
+            } else {
+                reply["result"] = res
+            }
+        }
+        note, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": "blockchain.headers.subscribe", "params": []interface{}{map[string]int{"height": 1}}})
+        out, _ := json.Marshal(reply)
+        conn.Write(append(append(note, '\n'), append(out, '\n')...))
+    }
+}
+
+func newFakeElectrum(t *testing.T) (*fakeElectrum, *ElectrumClient) {
+    f := &fakeElectrum{t: t, handlers: map[string]func([]json.RawMessage) interface{}{}}
+    l, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { l.Close() })
+    go f.serve(l)
+    c, err := NewElectrumClient(&netparams.MainNetParams, "tcp://"+l.Addr().String(), false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { c.Close() })
+    return f, c
+}
+
+func result(v interface{}) func([]json.RawMessage) interface{} {
+    return func([]json.RawMessage) interface{} { return v }
+}
+
+func txHex(tx *wire.MsgTx) string {
+    var buf bytes.Buffer
+    tx.Serialize(&buf)
+    return hex.EncodeToString(buf.Bytes())
+}
+
+func TestElectrumScriptHash(t *testing.T) {
+    // Vector from the Electrum protocol docs (the genesis P2PKH script).
+    hash, _ := hex.DecodeString("62e907b15cbf27d5425399ebf6f0fb50ebb88f18")
+    addr, _ := btcutil.NewAddressPubKeyHash(hash, &netparams.MainNetParams)
+    c, _ := NewElectrumClient(&netparams.MainNetParams, "localhost:50002", false)
+    sh, _, err := c.scriptHash(addr.EncodeAddress())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if sh != "8b01df4e368ea28f8dc0423bcf7a4923e3a12d307c875e47a0cfbf90b5c39161" {
+        t.Errorf("scripthash = %s", sh)
+    }
+}
+
+func TestElectrumAddressInfoAndUTXOs(t *testing.T) {
+    f, c := newFakeElectrum(t)
+    _, w := fundedMemoryProvider(t, crypto.AddrP2WPKH)
+    addr, _ := netparams.DecodeAddress(w.Address, &netparams.MainNetParams)
+    ours, _ := txscript.PayToAddrScript(addr)
+    other, _ := txscript.PayToAddrScript(mustDecode(t, "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ"))
+
+    parent := wire.NewMsgTx(2)
+    parent.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), []byte{1, 1}, nil))
+    parent.AddTxOut(wire.NewTxOut(1_000_000, ours))
+    child := wire.NewMsgTx(2)
+    parentHash := parent.TxHash()
+    child.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&parentHash, 0), nil, nil))
+    child.AddTxOut(wire.NewTxOut(400_000, other))
+    child.AddTxOut(wire.NewTxOut(590_000, ours))
+    childHash := child.TxHash()
+
+    header := make([]byte, 80)
+    binary.LittleEndian.PutUint32(header[68:], 1700000000)
+    f.handlers["blockchain.scripthash.get_balance"] = result(map[string]int64{"confirmed": 0, "unconfirmed": 590_000})
+    f.handlers["blockchain.scripthash.get_history"] = result([]map[string]interface{}{
+        {"tx_hash": parentHash.String(), "height": 100},
+        {"tx_hash": childHash.String(), "height": 0, "fee": 10_000},
+    })
+    f.handlers["blockchain.scripthash.listunspent"] = result([]map[string]interface{}{
+        {"tx_hash": childHash.String(), "tx_pos": 1, "height": 0, "value": 590_000},
+        {"tx_hash": parentHash.String(), "tx_pos": 0, "height": 100, "value": 1_000_000},
+    })
+    f.handlers["blockchain.headers.subscribe"] = result(map[string]interface{}{"height": 105, "hex": ""})
+    f.handlers["blockchain.block.header"] = result(hex.EncodeToString(header))
+    f.handlers["blockchain.transaction.get"] = func(params []json.RawMessage) interface{} {
+        switch string(params[0]) {
+        case `"` + parentHash.String() + `"`:
+            return txHex(parent)
+        case `"` + childHash.String() + `"`:
+            return txHex(child)
+        }
+        return &ElectrumError{Code: 2, Message: "no such transaction"}
+    }
+
+    info, err := c.GetAddressInfo(context.Background(), w.Address)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if info.Balance != 590_000 || info.UnconfirmedBalance != 590_000 || info.NTx != 2 {
+        t.Errorf("info = %+v", info)
+    }
+    if info.TotalReceived != 1_590_000 || info.TotalSent != 1_000_000 {
+        t.Errorf("totals = %d received, %d sent", info.TotalReceived, info.TotalSent)
+    }
+    if len(info.Txrefs) != 2 || info.Txrefs[0].Hash != childHash.String() || info.Txrefs[0].Value != -410_000 {
+        t.Fatalf("txrefs = %+v", info.Txrefs)
+    }
+    if ref := info.Txrefs[1]; ref.Value != 1_000_000 || ref.Confirmations != 6 || ref.Received != "2023-11-14T22:13:20Z" {
+        t.Errorf("parent ref = %+v", ref)
+    }
+
+    history, err := FullHistory(context.Background(), c, w.Address)
+    if err != nil || !reflect.DeepEqual(history, info.Txrefs) {
+        t.Errorf("history = %+v, %v; want the same entries as the overview", history, err)
+    }
+
+    utxos, err := c.GetUTXOs(context.Background(), w.Address)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(utxos) != 2 || utxos[0].Confirmations != 0 || utxos[1].Confirmations != 6 || utxos[1].Script != hex.EncodeToString(ours) {
+        t.Errorf("utxos = %+v", utxos)
+    }
+
+    detail, err := c.GetTransaction(context.Background(), childHash.String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if detail.Fees != 10_000 || len(detail.Inputs) != 1 || detail.Outputs[0].Addresses[0] != "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ" {
+        t.Errorf("detail = %+v", detail)
+    }
+}
+
+func TestElectrumFeesAndBroadcast(t *testing.T) {
+    f, c := newFakeElectrum(t)
+    f.handlers["blockchain.relayfee"] = result(json.Number("1e-05"))
+    f.handlers["blockchain.estimatefee"] = func(params []json.RawMessage) interface{} {
+        if string(params[0]) == "12" {
+            return -1
+        }
+        return json.Number("0.0005")
+    }
+    fees, err := c.EstimateFee(context.Background())
+    if err != nil {
+        t.Fatal(err)
+    }
+    if fees.LowPerKB != 1000 || fees.MediumPerKB != 50_000 || fees.HighPerKB != 50_000 {
+        t.Errorf("fees = %+v", fees)
+    }
+
+    f.handlers["blockchain.transaction.broadcast"] = result("abcd")
+    if txid, err := c.BroadcastRawTx(context.Background(), "00"); err != nil || txid != "abcd" {
+        t.Errorf("broadcast = %q, %v", txid, err)
+    }
+    f.handlers["blockchain.transaction.broadcast"] = result(&ElectrumError{Code: 1, Message: "min relay fee not met"})
+    _, err = c.BroadcastRawTx(context.Background(), "00")
+    var ee *ElectrumError
+    var be *BroadcastError
+    if !errors.As(err, &ee) || ee.Code != 1 || !errors.As(err, &be) || be.Reason != "min relay fee not met" {
+        t.Errorf("err = %v, want server rejection", err)
+    }
+}
+
+func TestElectrumTimeoutReconnects(t *testing.T) {
+    f, c := newFakeElectrum(t)
+    f.handlers["blockchain.headers.subscribe"] = result(nil)
+    ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+    defer cancel()
+    if _, err := c.TipHeight(ctx); !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("err = %v, want deadline exceeded", err)
+    }
+    f.mu.Lock()
+    f.handlers["blockchain.headers.subscribe"] = result(map[string]int{"height": 7})
+    f.mu.Unlock()
+    if h, err := c.TipHeight(context.Background()); err != nil || h != 7 {
+        t.Errorf("height = %d, %v", h, err)
+    }
+}
+
+func TestElectrumTLS(t *testing.T) {
+    srv := httptest.NewUnstartedServer(nil)
+    srv.StartTLS()
+    defer srv.Close()
+    l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: srv.TLS.Certificates})
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer l.Close()
+    f := &fakeElectrum{t: t, handlers: map[string]func([]json.RawMessage) interface{}{
+        "blockchain.headers.subscribe": result(map[string]int{"height": 42}),
+    }}
+    go f.serve(l)
+
+    c, _ := NewElectrumClient(&netparams.MainNetParams, "ssl://"+l.Addr().String(), false)
+    if _, err := c.TipHeight(context.Background()); err == nil {
+        t.Error("expected self-signed certificate to be rejected")
+    }
+    c, _ = NewElectrumClient(&netparams.MainNetParams, "ssl://"+l.Addr().String(), true)
+    defer c.Close()
+    if h, err := c.TipHeight(context.Background()); err != nil || h != 42 {
+        t.Errorf("height = %d, %v", h, err)
+    }
+}
+
+func mustDecode(t *testing.T, s string) btcutil.Address {
+    t.Helper()
+    a, err := netparams.DecodeAddress(s, &netparams.MainNetParams)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return a
+}
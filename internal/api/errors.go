@@ -0,0 +1,72 @@
+package api
+
+import (
+    "errors"
+    "fmt"
+    "time"
+
+    "litecoin-wallet/internal/txbuilder"
+)
+
+// Errors returned by the providers and the send helpers, for callers to test
+// with errors.Is. Backend-specific types (HTTPStatusError, RPCError,
+// ElectrumError, RateLimitError) match the sentinel that describes them.
+var (
+    // ErrInsufficientFunds: the wallet cannot cover the amount plus fee.
+    ErrInsufficientFunds = txbuilder.ErrInsufficientFunds
+    // ErrNoUTXOs: the address has nothing to spend.
+    ErrNoUTXOs = txbuilder.ErrNoUTXOs
+    // ErrOnlyUnconfirmed: a sweep found coins, but none confirmed yet. It
+    // comes wrapped together with ErrNoUTXOs.
+    ErrOnlyUnconfirmed = errors.New("all coins are unconfirmed")
+    // ErrFeeNotHigher: a fee bump does not pay enough more than the
+    // transaction it replaces.
+    ErrFeeNotHigher = txbuilder.ErrFeeNotHigher
+    // ErrAlreadyConfirmed: the transaction to speed up is in a block.
+    ErrAlreadyConfirmed = errors.New("transaction already confirmed")
+    // ErrPackageRateMet: a CPFP target is not above the parent's own rate.
+    ErrPackageRateMet = txbuilder.ErrPackageRateMet
+    // ErrZeroValue: the amount, or what is left after the fee, is zero.
+    ErrZeroValue = txbuilder.ErrZeroValue
+    // ErrRateLimited: the backend's request quota is used up for now.
+    ErrRateLimited = errors.New("rate limited")
+    // ErrBroadcastRejected: the backend refused to relay the transaction.
+    // The reason is in the *BroadcastError that matches it.
+    ErrBroadcastRejected = errors.New("transaction rejected")
+    // ErrNetwork: the backend could not be reached or failed to answer.
+    ErrNetwork = errors.New("network error")
+    // ErrTxNotFound: GetTransaction found no such transaction, in a block
+    // or in the mempool.
+    ErrTxNotFound = errors.New("transaction not found")
+)
+
+// BroadcastError is a definite refusal of a transaction by a backend, such
+// as a double spend or a fee below the relay minimum. Nothing was sent.
+type BroadcastError struct {
+    Provider string
+    // Reason is the backend's reject message.
+    Reason string
+    Err    error
+}
+
+func (e *BroadcastError) Error() string {
+    return fmt.Sprintf("%s rejected the transaction: %s", e.Provider, e.Reason)
+}
+
+func (e *BroadcastError) Unwrap() error { return e.Err }
+
+func (e *BroadcastError) Is(target error) bool { return target == ErrBroadcastRejected }
+
+// RetryAfter returns how long err says to wait before trying again, or zero
+// if it carries no hint.
+func RetryAfter(err error) time.Duration {
+    var rl *RateLimitError
+    if errors.As(err, &rl) {
+        return rl.RetryAfter
+    }
+    var se *HTTPStatusError
+    if errors.As(err, &se) {
+        return se.RetryAfter
+    }
+    return 0
+}
@@ -0,0 +1,349 @@
+package api
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "litecoin-wallet/internal/models"
+)
+
+// Circuit breaker defaults: a backend that fails FailureThreshold times in a
+// row is skipped for Cooldown, then given one trial request.
+const (
+    DefaultFailureThreshold = 3
+    DefaultCooldown         = time.Minute
+)
+
+// ErrAllProvidersFailed is wrapped by the error returned when no backend
+// could answer a request.
+var ErrAllProvidersFailed = errors.New("all providers failed")
+
+// DisagreementError reports that two backends returned different UTXO sets
+// for an address during a cross-check, so a send was refused.
+type DisagreementError struct {
+    Address  string
+    Provider [2]string
+    Balance  [2]models.Amount
+    Detail   string
+}
+
+func (e *DisagreementError) Error() string {
+    return fmt.Sprintf("%s and %s disagree about %s (%s; balances %d vs %d litoshis)",
+        e.Provider[0], e.Provider[1], e.Address, e.Detail, e.Balance[0], e.Balance[1])
+}
+
+// BackendStatus is the health of one backend as seen by FailoverProvider.
+type BackendStatus struct {
+    Name      string
+    Failures  int
+    OpenUntil time.Time
+    LastErr   error
+}
+
+// Healthy reports whether requests are currently sent to the backend.
+func (s BackendStatus) Healthy(now time.Time) bool { return !now.Before(s.OpenUntil) }
+
+// FailoverProvider tries Providers in priority order, skipping those whose
+// circuit is open. With CrossCheck set, GetUTXOs asks two backends and
+// returns a *DisagreementError when their confirmed UTXO sets differ; since
+// every send starts with GetUTXOs this stops a send on a lying or stale
+// backend.
+type FailoverProvider struct {
+    Providers        []ChainProvider
+    FailureThreshold int
+    Cooldown         time.Duration
+    CrossCheck       bool
+    // OnFailover, if set, is called when a backend fails and the request
+    // moves on to the next one.
+    OnFailover func(failed string, err error)
+
+    mu     sync.Mutex
+    status []BackendStatus
+    now    func() time.Time
+}
+
+var _ ChainProvider = (*FailoverProvider)(nil)
+
+func NewFailoverProvider(providers ...ChainProvider) *FailoverProvider {
+    f := &FailoverProvider{
+        Providers:        providers,
+        FailureThreshold: DefaultFailureThreshold,
+        Cooldown:         DefaultCooldown,
+        now:              time.Now,
+    }
+    for _, p := range providers {
+        f.status = append(f.status, BackendStatus{Name: p.Name()})
+    }
+    return f
+}
+
+func (f *FailoverProvider) Name() string {
+    names := make([]string, len(f.Providers))
+    for i, p := range f.Providers {
+        names[i] = p.Name()
+    }
+    return "failover(" + strings.Join(names, ",") + ")"
+}
+
+// Status returns a snapshot of every backend's health.
+func (f *FailoverProvider) Status() []BackendStatus {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return append([]BackendStatus(nil), f.status...)
+}
+
+// order returns provider indexes to try: closed circuits in priority order,
+// then open ones by how soon they close, so a request is never refused
+// outright just because every circuit is open.
+func (f *FailoverProvider) order() []int {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    now := f.now()
+    var ready, open []int
+    for i, s := range f.status {
+        if s.Healthy(now) {
+            ready = append(ready, i)
+        } else {
+            open = append(open, i)
+        }
+    }
+    sort.SliceStable(open, func(a, b int) bool { return f.status[open[a]].OpenUntil.Before(f.status[open[b]].OpenUntil) })
+    return append(ready, open...)
+}
+
+func (f *FailoverProvider) record(i int, err error) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    s := &f.status[i]
+    if err == nil {
+        s.Failures, s.OpenUntil, s.LastErr = 0, time.Time{}, nil
+        return
+    }
+    s.Failures++
+    s.LastErr = err
+    if s.Failures >= f.FailureThreshold {
+        s.OpenUntil = f.now().Add(f.Cooldown)
+    }
+}
+
+// do runs fn against each backend in turn until one succeeds.
+func (f *FailoverProvider) do(ctx context.Context, fn func(p ChainProvider) error) error {
+    var errs []error
+    for _, i := range f.order() {
+        p := f.Providers[i]
+        err := fn(p)
+        if ctx.Err() != nil {
+            return ctx.Err()
+        }
+        // A rejected transaction would be rejected by every backend, and a
+        // transaction the backend does not know is an answer, not an
+        // outage; neither says anything about this one's health.
+        if errors.Is(err, ErrBroadcastRejected) || errors.Is(err, ErrTxNotFound) {
+            f.record(i, nil)
+            return err
+        }
+        f.record(i, err)
+        if err == nil {
+            return nil
+        }
+        errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+        if f.OnFailover != nil {
+            f.OnFailover(p.Name(), err)
+        }
+    }
+    return fmt.Errorf("%w: %w", ErrAllProvidersFailed, errors.Join(errs...))
+}
+
+func (f *FailoverProvider) GetAddressInfo(ctx context.Context, address string) (models.AddressOverview, error) {
+    var info models.AddressOverview
+    err := f.do(ctx, func(p ChainProvider) (err error) {
+        info, err = p.GetAddressInfo(ctx, address)
+        return err
+    })
+    return info, err
+}
+
+func (f *FailoverProvider) GetBalance(ctx context.Context, address string) (models.Amount, error) {
+    var balance models.Amount
+    err := f.do(ctx, func(p ChainProvider) (err error) {
+        balance, err = p.GetBalance(ctx, address)
+        return err
+    })
+    return balance, err
+}
+
+func (f *FailoverProvider) GetBalances(ctx context.Context, addresses []string) (map[string]models.Amount, error) {
+    var balances map[string]models.Amount
+    err := f.do(ctx, func(p ChainProvider) (err error) {
+        balances, err = GetBalances(ctx, p, addresses)
+        return err
+    })
+    return balances, err
+}
+
+// HistoryPage gets the first page from whichever backend answers. Cursors
+// mean nothing to other backends, so later pages stay with that backend,
+// whose position in Providers is recorded in front of its cursor.
+func (f *FailoverProvider) HistoryPage(ctx context.Context, address, cursor string) ([]models.Transaction, string, error) {
+    tag := func(i int, next string) string {
+        if next == "" {
+            return ""
+        }
+        return strconv.Itoa(i) + "|" + next
+    }
+    if cursor != "" {
+        pos, inner, _ := strings.Cut(cursor, "|")
+        i, err := strconv.Atoi(pos)
+        if err != nil || i < 0 || i >= len(f.Providers) {
+            return nil, "", fmt.Errorf("bad history cursor %q", cursor)
+        }
+        txs, next, err := historyPage(ctx, f.Providers[i], address, inner)
+        if ctx.Err() == nil {
+            f.record(i, err)
+        }
+        return txs, tag(i, next), err
+    }
+    var txs []models.Transaction
+    var next string
+    err := f.do(ctx, func(p ChainProvider) (err error) {
+        txs, next, err = historyPage(ctx, p, address, "")
+        for i := range f.Providers {
+            if f.Providers[i] == p {
+                next = tag(i, next)
+            }
+        }
+        return err
+    })
+    return txs, next, err
+}
+
+func (f *FailoverProvider) GetUTXOs(ctx context.Context, address string) ([]models.UTXO, error) {
+    if f.CrossCheck {
+        return f.crossCheckUTXOs(ctx, address)
+    }
+    var utxos []models.UTXO
+    err := f.do(ctx, func(p ChainProvider) (err error) {
+        utxos, err = p.GetUTXOs(ctx, address)
+        return err
+    })
+    return utxos, err
+}
+
+// crossCheckUTXOs fetches UTXOs from the first two backends that answer and
+// compares them. Only outputs confirmed on one side must be present, with the
+// same value, on the other; mempool contents legitimately differ.
+func (f *FailoverProvider) crossCheckUTXOs(ctx context.Context, address string) ([]models.UTXO, error) {
+    type answer struct {
+        name  string
+        utxos []models.UTXO
+    }
+    var answers []answer
+    var errs []error
+    for _, i := range f.order() {
+        p := f.Providers[i]
+        utxos, err := p.GetUTXOs(ctx, address)
+        if ctx.Err() != nil {
+            return nil, ctx.Err()
+        }
+        f.record(i, err)
+        if err != nil {
+            errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+            if f.OnFailover != nil {
+                f.OnFailover(p.Name(), err)
+            }
+            continue
+        }
+        if answers = append(answers, answer{p.Name(), utxos}); len(answers) == 2 {
+            break
+        }
+    }
+    if len(answers) < 2 {
+        errs = append(errs, fmt.Errorf("cross-check needs two reachable providers, got %d", len(answers)))
+        return nil, fmt.Errorf("%w: %w", ErrAllProvidersFailed, errors.Join(errs...))
+    }
+    a, b := answers[0], answers[1]
+    if detail := compareUTXOs(a.utxos, b.utxos); detail != "" {
+        return nil, &DisagreementError{
+            Address:  address,
+            Provider: [2]string{a.name, b.name},
+            Balance:  [2]models.Amount{sumUTXOs(a.utxos), sumUTXOs(b.utxos)},
+            Detail:   detail,
+        }
+    }
+    return a.utxos, nil
+}
+
+func compareUTXOs(a, b []models.UTXO) string {
+    index := func(us []models.UTXO) map[string]models.UTXO {
+        m := make(map[string]models.UTXO, len(us))
+        for _, u := range us {
+            m[u.Outpoint()] = u
+        }
+        return m
+    }
+    ma, mb := index(a), index(b)
+    for _, pair := range [][2]map[string]models.UTXO{{ma, mb}, {mb, ma}} {
+        keys := make([]string, 0, len(pair[0]))
+        for k := range pair[0] {
+            keys = append(keys, k)
+        }
+        sort.Strings(keys)
+        for _, k := range keys {
+            u := pair[0][k]
+            if u.Confirmations == 0 {
+                continue
+            }
+            other, ok := pair[1][k]
+            switch {
+            case !ok:
+                return "output " + k + " is missing on one side"
+            case other.Value != u.Value:
+                return fmt.Sprintf("output %s is worth %d on one side and %d on the other", k, u.Value, other.Value)
+            }
+        }
+    }
+    return ""
+}
+
+func sumUTXOs(us []models.UTXO) models.Amount {
+    var total models.Amount
+    for _, u := range us {
+        total += u.Value
+    }
+    return total
+}
+
+// BroadcastRawTx sends the transaction to the first backend that accepts it.
+// Rebroadcasting the same signed transaction elsewhere is harmless.
+func (f *FailoverProvider) BroadcastRawTx(ctx context.Context, rawHex string) (string, error) {
+    var txid string
+    err := f.do(ctx, func(p ChainProvider) (err error) {
+        txid, err = p.BroadcastRawTx(ctx, rawHex)
+        return err
+    })
+    return txid, err
+}
+
+func (f *FailoverProvider) EstimateFee(ctx context.Context) (models.FeeEstimate, error) {
+    var fees models.FeeEstimate
+    err := f.do(ctx, func(p ChainProvider) (err error) {
+        fees, err = p.EstimateFee(ctx)
+        return err
+    })
+    return fees, err
+}
+
+func (f *FailoverProvider) GetTransaction(ctx context.Context, txid string) (models.TxDetail, error) {
+    var tx models.TxDetail
+    err := f.do(ctx, func(p ChainProvider) (err error) {
+        tx, err = p.GetTransaction(ctx, txid)
+        return err
+    })
+    return tx, err
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "litecoin-wallet/internal/crypto"
+    "litecoin-wallet/internal/models"
+)
+
+// countingProvider counts GetBalance calls on top of a MemoryProvider.
+type countingProvider struct {
+    *MemoryProvider
+    name  string
+    calls int
+}
+
+func (c *countingProvider) Name() string { return c.name }
+
+func (c *countingProvider) GetBalance(ctx context.Context, address string) (models.Amount, error) {
+    c.calls++
+    return c.MemoryProvider.GetBalance(ctx, address)
+}
+
+func TestFailoverCircuitBreaker(t *testing.T) {
+    down := &countingProvider{MemoryProvider: NewMemoryProvider(), name: "primary"}
+    down.Err = errors.New("503 Service Unavailable")
+    up := &countingProvider{MemoryProvider: NewMemoryProvider(), name: "backup"}
+    up.Addresses["L1"] = models.AddressOverview{Balance: 42}
+
+    f := NewFailoverProvider(down, up)
+    now := time.Unix(1000, 0)
+    f.now = func() time.Time { return now }
+    var failovers int
+    f.OnFailover = func(string, error) { failovers++ }
+
+    for i := 0; i < 5; i++ {
+        if b, err := f.GetBalance(context.Background(), "L1"); err != nil || b != 42 {
+            t.Fatalf("call %d: balance = %d, %v", i, b, err)
+        }
+    }
+    if down.calls != DefaultFailureThreshold || failovers != DefaultFailureThreshold {
+        t.Errorf("primary tried %d times (%d failovers), want circuit open after %d", down.calls, failovers, DefaultFailureThreshold)
+    }
+    if s := f.Status()[0]; s.Healthy(now) || s.Failures != DefaultFailureThreshold {
+        t.Errorf("primary status = %+v", s)
+    }
+
+    // After the cooldown the primary gets a trial request and closes again.
+    now = now.Add(DefaultCooldown)
+    down.Err = nil
+    down.Addresses["L1"] = models.AddressOverview{Balance: 7}
+    if b, _ := f.GetBalance(context.Background(), "L1"); b != 7 {
+        t.Errorf("balance = %d, want answer from recovered primary", b)
+    }
+    if s := f.Status()[0]; s.Failures != 0 {
+        t.Errorf("primary failures = %d after success", s.Failures)
+    }
+}
+
+func TestFailoverAllFail(t *testing.T) {
+    a, b := NewMemoryProvider(), NewMemoryProvider()
+    a.Err, b.Err = errors.New("timeout"), errors.New("429 Too Many Requests")
+    _, err := NewFailoverProvider(a, b).GetBalance(context.Background(), "L1")
+    if !errors.Is(err, ErrAllProvidersFailed) || !errors.Is(err, a.Err) || !errors.Is(err, b.Err) {
+        t.Errorf("err = %v, want all backend errors", err)
+    }
+}
+
+func TestFailoverStopsOnRejection(t *testing.T) {
+    a, b := NewMemoryProvider(), NewMemoryProvider()
+    a.Err = &BroadcastError{Provider: "memory", Reason: "bad-txns-inputs-missingorspent"}
+    f := NewFailoverProvider(a, b)
+    if _, err := f.BroadcastRawTx(context.Background(), "00"); !errors.Is(err, ErrBroadcastRejected) {
+        t.Fatalf("err = %v, want the rejection", err)
+    }
+    if len(b.Broadcasts) != 0 || f.Status()[0].Failures != 0 {
+        t.Errorf("rejection was retried elsewhere or counted against the backend: %+v", f.Status())
+    }
+}
+
+func TestFailoverStopsOnTxNotFound(t *testing.T) {
+    a, b := NewMemoryProvider(), NewMemoryProvider()
+    b.Txs["evicted"] = models.TxDetail{Hash: "evicted"}
+    f := NewFailoverProvider(a, b)
+    var failovers int
+    f.OnFailover = func(string, error) { failovers++ }
+    for i := 0; i < DefaultFailureThreshold; i++ {
+        if _, err := f.GetTransaction(context.Background(), "evicted"); !errors.Is(err, ErrTxNotFound) {
+            t.Fatalf("err = %v, want ErrTxNotFound from the first backend", err)
+        }
+    }
+    if failovers != 0 || f.Status()[0].Failures != 0 || !f.Status()[0].Healthy(time.Now()) {
+        t.Errorf("a missing transaction counted against the backend: %d failovers, %+v", failovers, f.Status()[0])
+    }
+}
+
+func TestFailoverCrossCheck(t *testing.T) {
+    a, w := fundedMemoryProvider(t, crypto.AddrP2WPKH, 50_000, 70_000)
+    b, _ := fundedMemoryProvider(t, crypto.AddrP2WPKH, 50_000, 70_000)
+    // A mempool-only output on one side is not a disagreement.
+    b.UTXOs[w.Address] = append(b.UTXOs[w.Address], models.UTXO{TxHash: "ff", Value: 1000})
+    f := NewFailoverProvider(a, b)
+    f.CrossCheck = true
+    if utxos, err := f.GetUTXOs(context.Background(), w.Address); err != nil || len(utxos) != 2 {
+        t.Fatalf("utxos = %d, %v", len(utxos), err)
+    }
+
+    b.UTXOs[w.Address][1].Value = 69_000
+    _, err := testSend(f, w.Address, w.Address, 10_000)
+    var de *DisagreementError
+    if !errors.As(err, &de) || de.Balance != [2]models.Amount{120_000, 120_000} {
+        t.Fatalf("err = %v, want disagreement", err)
+    }
+    if len(a.Broadcasts)+len(b.Broadcasts) != 0 {
+        t.Error("transaction was broadcast despite disagreement")
+    }
+
+    b.Err = errors.New("down")
+    if _, err := f.GetUTXOs(context.Background(), w.Address); !errors.Is(err, ErrAllProvidersFailed) {
+        t.Errorf("err = %v, want refusal with one reachable provider", err)
+    }
+}
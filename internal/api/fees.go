@@ -0,0 +1,63 @@
+package api
+
+import (
+    "fmt"
+    "math/big"
+    "strings"
+
+    "litecoin-wallet/internal/models"
+)
+
+// FeeTier is a point on the provider's fee estimate.
+type FeeTier string
+
+const (
+    FeeSlow   FeeTier = "slow"   // about 12 blocks
+    FeeNormal FeeTier = "normal" // about 6 blocks
+    FeeFast   FeeTier = "fast"   // about 2 blocks
+)
+
+// FeeTiers lists the tiers slowest first.
+var FeeTiers = []FeeTier{FeeSlow, FeeNormal, FeeFast}
+
+// MaxFeePerKB is the highest custom fee rate accepted, 10000 litoshis per
+// vbyte. Anything above is far more likely a typo than intent.
+const MaxFeePerKB = 10_000_000
+
+// PerKB returns the tier's rate from fees in litoshis per kB.
+func (t FeeTier) PerKB(fees models.FeeEstimate) int64 {
+    switch t {
+    case FeeSlow:
+        return fees.LowPerKB
+    case FeeFast:
+        return fees.HighPerKB
+    default:
+        return fees.MediumPerKB
+    }
+}
+
+// FormatFeePerVByte shows a rate in litoshis per kB as litoshis (sat) per vbyte.
+func FormatFeePerVByte(perKB int64) string {
+    s := new(big.Rat).SetFrac64(perKB, 1000).FloatString(3)
+    s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+    return s + " sat/vB"
+}
+
+// ParseFeePerVByte reads a custom rate in litoshis (sat) per vbyte, such as "2"
+// or "1.5", and returns it in litoshis per kB, rounded up.
+func ParseFeePerVByte(s string) (int64, error) {
+    s = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(strings.ToLower(s)), "sat/vb"))
+    r, ok := new(big.Rat).SetString(s)
+    if !ok || r.Sign() <= 0 {
+        return 0, fmt.Errorf("invalid fee rate %q: give litoshis per vbyte, e.g. 2 or 1.5", s)
+    }
+    r.Mul(r, big.NewRat(1000, 1))
+    q, m := new(big.Int).DivMod(r.Num(), r.Denom(), new(big.Int))
+    if m.Sign() != 0 {
+        q.Add(q, big.NewInt(1))
+    }
+    if !q.IsInt64() || q.Int64() > MaxFeePerKB {
+        return 0, fmt.Errorf("fee rate %s is above the %s limit", s, FormatFeePerVByte(MaxFeePerKB))
+    }
+    return q.Int64(), nil
+}
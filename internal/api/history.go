@@ -0,0 +1,108 @@
+package api
+
+import (
+    "context"
+
+    "litecoin-wallet/internal/models"
+)
+
+// HistoryPager is implemented by providers that can list every transaction
+// of an address a page at a time, newest first. The first page has an empty
+// cursor and the last returns an empty next. Cursors are opaque and only
+// mean something to the provider that returned them.
+type HistoryPager interface {
+    HistoryPage(ctx context.Context, address, cursor string) (txs []models.Transaction, next string, err error)
+}
+
+// History iterates over the complete history of an address, newest first,
+// fetching pages as they are needed:
+//
+//    h := api.NewHistory(provider, address)
+//    for h.Next(ctx) {
+//        show(h.Tx())
+//    }
+//    if err := h.Err(); err != nil { ... }
+//
+// Providers without a HistoryPager yield the Txrefs of GetAddressInfo.
+type History struct {
+    p       ChainProvider
+    address string
+    cursor  string
+    done    bool
+    buf     []models.Transaction
+    tx      models.Transaction
+    err     error
+    seen    map[string]bool
+}
+
+func NewHistory(p ChainProvider, address string) *History {
+    return &History{p: p, address: address, seen: map[string]bool{}}
+}
+
+// Next advances to the next transaction, fetching a page if needed. It
+// returns false at the end of the history or on error; see Err.
+func (h *History) Next(ctx context.Context) bool {
+    for len(h.buf) == 0 {
+        if h.done || h.err != nil {
+            return false
+        }
+        h.fetch(ctx)
+    }
+    h.tx, h.buf = h.buf[0], h.buf[1:]
+    return true
+}
+
+// Tx is the transaction Next moved to.
+func (h *History) Tx() models.Transaction { return h.tx }
+
+// Err is the error that stopped the iteration, if any.
+func (h *History) Err() error { return h.err }
+
+// Take returns up to n more transactions. A short result with a nil error
+// means the history is exhausted.
+func (h *History) Take(ctx context.Context, n int) ([]models.Transaction, error) {
+    var txs []models.Transaction
+    for len(txs) < n && h.Next(ctx) {
+        txs = append(txs, h.tx)
+    }
+    return txs, h.err
+}
+
+func (h *History) fetch(ctx context.Context) {
+    var page []models.Transaction
+    prev := h.cursor
+    page, h.cursor, h.err = historyPage(ctx, h.p, h.address, h.cursor)
+    if h.err != nil {
+        return
+    }
+    // A cursor that does not move would page forever.
+    h.done = h.cursor == "" || h.cursor == prev
+    // New transactions arriving while paging can shift an entry onto the
+    // next page as well.
+    for _, tx := range page {
+        if !h.seen[tx.Hash] {
+            h.seen[tx.Hash] = true
+            h.buf = append(h.buf, tx)
+        }
+    }
+}
+
+// historyPage fetches one page from p, treating GetAddressInfo as the only
+// page when p cannot do better.
+func historyPage(ctx context.Context, p ChainProvider, address, cursor string) ([]models.Transaction, string, error) {
+    if pager, ok := p.(HistoryPager); ok {
+        return pager.HistoryPage(ctx, address, cursor)
+    }
+    info, err := p.GetAddressInfo(ctx, address)
+    return info.Txrefs, "", err
+}
+
+// FullHistory returns every transaction of address, newest first.
+func FullHistory(ctx context.Context, p ChainProvider, address string) ([]models.Transaction, error) {
+    h := NewHistory(p, address)
+    var txs []models.Transaction
+    for h.Next(ctx) {
+        txs = append(txs, h.Tx())
+    }
+    return txs, h.Err()
+}
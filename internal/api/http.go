@@ -0,0 +1,219 @@
+package api
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "math/rand"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// HTTP defaults shared by the REST providers.
+const (
+    DefaultTimeout    = 30 * time.Second
+    DefaultMaxRetries = 3
+    retryBaseDelay    = 500 * time.Millisecond
+    maxErrorBody      = 512
+)
+
+// HTTPStatusError is a non-2xx response. Message holds the API's own error
+// text when the body contained one.
+type HTTPStatusError struct {
+    StatusCode int
+    Status     string
+    Message    string
+    RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+    if e.Message != "" {
+        return fmt.Sprintf("%s: %s", e.Status, e.Message)
+    }
+    return e.Status
+}
+
+// Is matches ErrRateLimited for 429 and ErrNetwork for server errors. A 404
+// is only a missing transaction where a transaction was asked for, so the
+// caller decides that.
+func (e *HTTPStatusError) Is(target error) bool {
+    switch target {
+    case ErrRateLimited:
+        return e.StatusCode == http.StatusTooManyRequests
+    case ErrNetwork:
+        return e.StatusCode >= 500
+    }
+    return false
+}
+
+func (e *HTTPStatusError) retryable() bool {
+    return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// restClient performs JSON requests with rate limiting, and retries GETs on
+// network errors, 429 and 5xx with exponential backoff.
+type restClient struct {
+    client     *http.Client
+    limiter    *RateLimiter
+    maxRetries int
+    sleep      func(context.Context, time.Duration) error
+    // cost is how many requests one call counts as against the quota;
+    // zero means one.
+    cost int
+}
+
+// doJSON sends payload (if any) as JSON and decodes a 2xx response into out.
+// Only GET is retried; a POST may have been applied before the error.
+func (c *restClient) doJSON(ctx context.Context, method, endpoint string, payload, out interface{}) error {
+    var body []byte
+    if payload != nil {
+        var err error
+        if body, err = json.Marshal(payload); err != nil {
+            return err
+        }
+    }
+    attempts := 1
+    if method == http.MethodGet {
+        attempts += c.maxRetries
+    }
+    var err error
+    for attempt := 0; attempt < attempts; attempt++ {
+        if attempt > 0 {
+            delay := retryBaseDelay << (attempt - 1)
+            delay += time.Duration(rand.Int63n(int64(delay) / 2))
+            var se *HTTPStatusError
+            if errors.As(err, &se) && se.RetryAfter > delay {
+                delay = se.RetryAfter
+            }
+            if c.limiter != nil && delay > c.limiter.MaxWait {
+                return err
+            }
+            if serr := c.sleep(ctx, delay); serr != nil {
+                return serr
+            }
+        }
+        err = c.once(ctx, method, endpoint, body, out)
+        if err == nil || ctx.Err() != nil || !isRetryable(err) {
+            return err
+        }
+    }
+    return err
+}
+
+// transportError marks failures to get any response (timeout, reset, DNS),
+// which are safe to retry for GETs.
+type transportError struct{ err error }
+
+func (e *transportError) Error() string { return e.err.Error() }
+func (e *transportError) Unwrap() error { return e.err }
+func (e *transportError) Is(target error) bool { return target == ErrNetwork }
+
+func isRetryable(err error) bool {
+    var se *HTTPStatusError
+    if errors.As(err, &se) {
+        return se.retryable()
+    }
+    var te *transportError
+    return errors.As(err, &te)
+}
+
+func (c *restClient) once(ctx context.Context, method, endpoint string, body []byte, out interface{}) error {
+    if c.limiter != nil {
+        if err := c.limiter.WaitN(ctx, max(c.cost, 1)); err != nil {
+            return err
+        }
+    }
+    var reader io.Reader
+    if body != nil {
+        reader = bytes.NewReader(body)
+    }
+    req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+    if err != nil {
+        return err
+    }
+    if body != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    resp, err := c.client.Do(req)
+    if err != nil {
+        // Drop the query string, which may carry an API token.
+        var ue *url.Error
+        if errors.As(err, &ue) {
+            ue.URL, _, _ = strings.Cut(ue.URL, "?")
+        }
+        return &transportError{err}
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        se := statusError(resp)
+        if se.StatusCode == http.StatusTooManyRequests && c.limiter != nil {
+            wait := se.RetryAfter
+            if wait == 0 {
+                wait = time.Second
+            }
+            c.limiter.Block(wait)
+        }
+        return se
+    }
+    if out == nil {
+        return nil
+    }
+    return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// statusError builds an HTTPStatusError, pulling the message out of
+// {"error": "..."} or {"errors": [{"error": "..."}]} bodies.
+func statusError(resp *http.Response) *HTTPStatusError {
+    raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+    se := &HTTPStatusError{StatusCode: resp.StatusCode, Status: resp.Status, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}
+    var apiErr struct {
+        Error  string `json:"error"`
+        Errors []struct {
+            Error string `json:"error"`
+        } `json:"errors"`
+    }
+    if json.Unmarshal(raw, &apiErr) == nil {
+        msgs := []string{}
+        if apiErr.Error != "" {
+            msgs = append(msgs, apiErr.Error)
+        }
+        for _, e := range apiErr.Errors {
+            msgs = append(msgs, e.Error)
+        }
+        se.Message = strings.Join(msgs, "; ")
+    }
+    if se.Message == "" {
+        se.Message = strings.TrimSpace(string(raw))
+    }
+    return se
+}
+
+// withTimeout bounds ctx by d unless the caller already set a deadline.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+    if _, ok := ctx.Deadline(); ok || d <= 0 {
+        return ctx, func() {}
+    }
+    return context.WithTimeout(ctx, d)
+}
+
+// parseRetryAfter accepts delay-seconds or an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+    if v == "" {
+        return 0
+    }
+    if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+        return time.Duration(secs) * time.Second
+    }
+    if t, err := http.ParseTime(v); err == nil {
+        if d := time.Until(t); d > 0 {
+            return d
+        }
+    }
+    return 0
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+    "bytes"
+    "context"
+    "encoding/hex"
+    "fmt"
+    "sync"
+
+    "litecoin-wallet/internal/models"
+    "github.com/btcsuite/btcd/wire"
+)
+
+// MemoryProvider is an in-memory ChainProvider. Tests seed Addresses, UTXOs,
+// Txs and Fees directly; broadcasts are decoded and recorded in Broadcasts.
+// Setting Err makes every call fail with it.
+type MemoryProvider struct {
+    mu         sync.Mutex
+    Addresses  map[string]models.AddressOverview
+    UTXOs      map[string][]models.UTXO
+    Txs        map[string]models.TxDetail
+    Fees       models.FeeEstimate
+    Broadcasts []*wire.MsgTx
+    Err        error
+}
+
+var _ ChainProvider = (*MemoryProvider)(nil)
+
+func NewMemoryProvider() *MemoryProvider {
+    return &MemoryProvider{
+        Addresses: map[string]models.AddressOverview{},
+        UTXOs:     map[string][]models.UTXO{},
+        Txs:       map[string]models.TxDetail{},
+        Fees:      models.FeeEstimate{LowPerKB: 1000, MediumPerKB: 10000, HighPerKB: 20000},
+    }
+}
+
+func (m *MemoryProvider) Name() string { return "memory" }
+
+func (m *MemoryProvider) GetAddressInfo(ctx context.Context, address string) (models.AddressOverview, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.Addresses[address], m.Err
+}
+
+func (m *MemoryProvider) GetBalance(ctx context.Context, address string) (models.Amount, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.Addresses[address].Balance, m.Err
+}
+
+func (m *MemoryProvider) GetUTXOs(ctx context.Context, address string) ([]models.UTXO, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return append([]models.UTXO(nil), m.UTXOs[address]...), m.Err
+}
+
+func (m *MemoryProvider) EstimateFee(ctx context.Context) (models.FeeEstimate, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.Fees, m.Err
+}
+
+func (m *MemoryProvider) GetTransaction(ctx context.Context, txid string) (models.TxDetail, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if m.Err != nil {
+        return models.TxDetail{}, m.Err
+    }
+    tx, ok := m.Txs[txid]
+    if !ok {
+        return tx, fmt.Errorf("%w: %s", ErrTxNotFound, txid)
+    }
+    return tx, nil
+}
+
+func (m *MemoryProvider) BroadcastRawTx(ctx context.Context, rawHex string) (string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if m.Err != nil {
+        return "", m.Err
+    }
+    raw, err := hex.DecodeString(rawHex)
+    if err != nil {
+        return "", err
+    }
+    tx := wire.NewMsgTx(wire.TxVersion)
+    if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+        return "", err
+    }
+    m.Broadcasts = append(m.Broadcasts, tx)
+    return tx.TxHash().String(), nil
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+    "context"
+    "fmt"
+
+    "litecoin-wallet/internal/coinselect"
+    "litecoin-wallet/internal/crypto"
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "litecoin-wallet/internal/txbuilder"
+    "github.com/btcsuite/btcd/chaincfg"
+)
+
+// ChainProvider is everything the wallet needs from a blockchain backend.
+// BlockCypherClient is one implementation; MemoryProvider is an in-memory
+// fake for tests and offline work.
+type ChainProvider interface {
+    // Name identifies the backend in messages, e.g. "blockcypher".
+    Name() string
+    GetAddressInfo(ctx context.Context, address string) (models.AddressOverview, error)
+    GetBalance(ctx context.Context, address string) (models.Amount, error)
+    GetUTXOs(ctx context.Context, address string) ([]models.UTXO, error)
+    // BroadcastRawTx submits a signed transaction in hex and returns its hash.
+    BroadcastRawTx(ctx context.Context, rawHex string) (string, error)
+    EstimateFee(ctx context.Context) (models.FeeEstimate, error)
+    GetTransaction(ctx context.Context, txid string) (models.TxDetail, error)
+}
+
+// BalanceBatcher is implemented by providers that can look up many
+// addresses in one request.
+type BalanceBatcher interface {
+    GetBalances(ctx context.Context, addresses []string) (map[string]models.Amount, error)
+}
+
+// GetBalances returns the balance of every address, in one batch when p
+// supports it and one lookup per address otherwise.
+func GetBalances(ctx context.Context, p ChainProvider, addresses []string) (map[string]models.Amount, error) {
+    if b, ok := p.(BalanceBatcher); ok {
+        return b.GetBalances(ctx, addresses)
+    }
+    balances := make(map[string]models.Amount, len(addresses))
+    for _, a := range addresses {
+        bal, err := p.GetBalance(ctx, a)
+        if err != nil {
+            return balances, err
+        }
+        balances[a] = bal
+    }
+    return balances, nil
+}
+
+// SkeletonSender is implemented by providers that can assemble legacy
+// transactions server-side and only need the sighashes signed. It is kept
+// for the optional "skeleton" send mode; the default builds locally with
+// BuildSend.
+type SkeletonSender interface {
+    SendTransaction(ctx context.Context, privateKeyHex, fromAddress, toAddress string, amount models.Amount, sendAll bool) (string, error)
+}
+
+// SendRequest is a payment from our addresses.
+type SendRequest struct {
+    Params   *chaincfg.Params
+    From     string
+    // Addresses are all the addresses whose coins may be spent, From among
+    // them, for a wallet with more than one; empty means From alone.
+    Addresses []string
+    // Change receives the change; empty means From.
+    Change   string
+    To       string
+    Amount   models.Amount
+    // SendAll sweeps: every confirmed coin goes to To, less the fee for
+    // the transaction's size, with no change. Amount is ignored.
+    SendAll bool
+    // IncludeUnconfirmed lets a sweep spend unconfirmed coins too.
+    IncludeUnconfirmed bool
+    Coins    CoinControl
+    // Strategy picks the inputs when none were selected by hand; empty
+    // means coinselect.LeastCost.
+    Strategy coinselect.Strategy
+    // FeePerKB is the fee rate in litoshis per kB; 0 means the provider's
+    // FeeNormal estimate.
+    FeePerKB int64
+}
+
+// BuildSend fetches the UTXOs of req.From, or of req.Addresses, applies the
+// coin control and assembles an unsigned payment at req.FeePerKB. Coins
+// picked by hand are all spent; otherwise req.Strategy chooses among the
+// rest.
+func BuildSend(ctx context.Context, p ChainProvider, req SendRequest) (*txbuilder.Draft, error) {
+    from, err := netparams.DecodeAddress(req.From, req.Params)
+    if err != nil {
+        return nil, fmt.Errorf("invalid sender address: %w", err)
+    }
+    if _, err := netparams.DecodeAddress(req.To, req.Params); err != nil {
+        return nil, fmt.Errorf("invalid recipient address: %w", err)
+    }
+    utxos, err := ListUTXOs(ctx, p, spendFrom(req.From, req.Addresses)...)
+    if err != nil {
+        return nil, err
+    }
+    utxos, err = req.Coins.Filter(utxos)
+    if err != nil {
+        return nil, err
+    }
+    if req.SendAll && !req.IncludeUnconfirmed && len(req.Coins.Selected) == 0 {
+        if utxos, err = confirmedOnly(utxos); err != nil {
+            return nil, err
+        }
+    }
+    feePerKB := req.FeePerKB
+    if feePerKB <= 0 {
+        fees, err := p.EstimateFee(ctx)
+        if err != nil {
+            return nil, err
+        }
+        feePerKB = FeeNormal.PerKB(fees)
+    }
+    strategy := req.Strategy
+    if strategy == "" {
+        strategy = coinselect.LeastCost
+    }
+    draft, err := txbuilder.Build(txbuilder.Request{
+        Params:   req.Params,
+        From:     req.From,
+        Change:   req.Change,
+        AddrType: crypto.AddressTypeOf(from),
+        UTXOs:    utxos,
+        Outputs:  []txbuilder.Payment{{Address: req.To, Value: req.Amount}},
+        FeePerKB: feePerKB,
+        SendAll:  req.SendAll,
+        SpendAll: len(req.Coins.Selected) > 0,
+        Strategy: strategy,
+    })
+    return draft, err
+}
+
+func confirmedOnly(utxos []models.UTXO) ([]models.UTXO, error) {
+    var out []models.UTXO
+    for _, u := range utxos {
+        if u.Confirmations > 0 {
+            out = append(out, u)
+        }
+    }
+    if len(out) == 0 {
+        return nil, fmt.Errorf("%w: %w", ErrNoUTXOs, ErrOnlyUnconfirmed)
+    }
+    return out, nil
+}
+
+// SignAndBroadcast signs each input of draft with the key keys holds for
+// its address and submits the transaction through p.
+func SignAndBroadcast(ctx context.Context, p ChainProvider, draft *txbuilder.Draft, keys map[string]string) (string, error) {
+    if err := draft.SignWith(keys); err != nil {
+        return "", err
+    }
+    raw, err := draft.RawHex()
+    if err != nil {
+        return "", err
+    }
+    return p.BroadcastRawTx(ctx, raw)
+}
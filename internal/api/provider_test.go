@@ -0,0 +1,219 @@
+package api
+
+import (
+    "context"
+    "encoding/hex"
+    "errors"
+    "strconv"
+    "testing"
+
+    "litecoin-wallet/internal/crypto"
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "litecoin-wallet/internal/txbuilder"
+    "github.com/btcsuite/btcd/txscript"
+)
+
+const testKey = "0000000000000000000000000000000000000000000000000000000000000001"
+
+func fundedMemoryProvider(t *testing.T, addrType crypto.AddressType, values ...models.Amount) (*MemoryProvider, *crypto.LitecoinWallet) {
+    t.Helper()
+    w, err := crypto.LoadLitecoinWallet(&netparams.MainNetParams, testKey, addrType)
+    if err != nil {
+        t.Fatal(err)
+    }
+    addr, _ := netparams.DecodeAddress(w.Address, &netparams.MainNetParams)
+    script, _ := txscript.PayToAddrScript(addr)
+    m := NewMemoryProvider()
+    for i, v := range values {
+        m.UTXOs[w.Address] = append(m.UTXOs[w.Address], models.UTXO{
+            TxHash:        hex.EncodeToString([]byte{31: byte(i + 1)}),
+            OutputIndex:   uint32(i),
+            Value:         v,
+            Confirmations: 6,
+            Script:        hex.EncodeToString(script),
+        })
+    }
+    return m, w
+}
+
+// testSend builds, signs and broadcasts a payment from the test key, as the
+// wallet's send screen does.
+func testSend(p ChainProvider, from, to string, amount models.Amount) (string, error) {
+    draft, err := BuildSend(context.Background(), p, SendRequest{Params: &netparams.MainNetParams, From: from, To: to, Amount: amount})
+    if err != nil {
+        return "", err
+    }
+    return SignAndBroadcast(context.Background(), p, draft, map[string]string{from: testKey})
+}
+
+func TestSendTransactionLocalWithChange(t *testing.T) {
+    m, w := fundedMemoryProvider(t, crypto.AddrP2WPKH, 50_000_000, 30_000_000)
+    to := "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ"
+    hash, err := testSend(m, w.Address, to, 60_000_000)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(m.Broadcasts) != 1 || m.Broadcasts[0].TxHash().String() != hash {
+        t.Fatalf("broadcasts = %d, hash %s", len(m.Broadcasts), hash)
+    }
+    tx := m.Broadcasts[0]
+    if len(tx.TxIn) != 2 || len(tx.TxOut) != 2 {
+        t.Fatalf("got %d inputs / %d outputs, want 2 / 2", len(tx.TxIn), len(tx.TxOut))
+    }
+    if tx.TxOut[0].Value != 60_000_000 {
+        t.Errorf("payment = %d, want 60000000", tx.TxOut[0].Value)
+    }
+    fee := 80_000_000 - tx.TxOut[0].Value - tx.TxOut[1].Value
+    if fee <= 0 || fee > 10_000 {
+        t.Errorf("fee = %d, want a small positive fee", fee)
+    }
+}
+
+func TestSendTransactionInsufficientFunds(t *testing.T) {
+    m, w := fundedMemoryProvider(t, crypto.AddrP2WPKH, 1_000)
+    _, err := testSend(m, w.Address, w.Address, 5_000)
+    if !errors.Is(err, ErrInsufficientFunds) {
+        t.Fatalf("err = %v, want ErrInsufficientFunds", err)
+    }
+    if len(m.Broadcasts) != 0 {
+        t.Errorf("nothing should be broadcast")
+    }
+}
+
+func TestSendFeeRate(t *testing.T) {
+    m, w := fundedMemoryProvider(t, crypto.AddrP2WPKH, 50_000_000)
+    req := SendRequest{Params: &netparams.MainNetParams, From: w.Address, To: "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ", Amount: 10_000_000}
+    normal, err := BuildSend(context.Background(), m, req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if normal.FeePerKB != m.Fees.MediumPerKB || normal.Fee != models.Amount(normal.VSize*m.Fees.MediumPerKB/1000) {
+        t.Errorf("default: %d/kB, fee %d for %d vB", normal.FeePerKB, normal.Fee, normal.VSize)
+    }
+    req.FeePerKB, err = ParseFeePerVByte("2.5")
+    if err != nil || req.FeePerKB != 2500 {
+        t.Fatalf("ParseFeePerVByte(2.5) = %d, %v", req.FeePerKB, err)
+    }
+    custom, err := BuildSend(context.Background(), m, req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if custom.Fee != models.Amount(custom.VSize*2500/1000) || FormatFeePerVByte(custom.FeePerKB) != "2.5 sat/vB" {
+        t.Errorf("custom: fee %d for %d vB at %s", custom.Fee, custom.VSize, FormatFeePerVByte(custom.FeePerKB))
+    }
+    for _, bad := range []string{"0", "-1", "abc", "20000"} {
+        if _, err := ParseFeePerVByte(bad); err == nil {
+            t.Errorf("ParseFeePerVByte(%q) accepted", bad)
+        }
+    }
+}
+
+func TestSweepConfirmedCoins(t *testing.T) {
+    m, w := fundedMemoryProvider(t, crypto.AddrP2WPKH, 40_000_000, 25_000_000, 5_000_000)
+    m.UTXOs[w.Address][2].Confirmations = 0
+    req := SendRequest{Params: &netparams.MainNetParams, From: w.Address, To: "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ", SendAll: true, FeePerKB: 2000}
+    d, err := BuildSend(context.Background(), m, req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(d.Inputs) != 2 || len(d.Tx.TxOut) != 1 || d.ChangeIndex != -1 {
+        t.Fatalf("got %d inputs / %d outputs, want the 2 confirmed coins and no change", len(d.Inputs), len(d.Tx.TxOut))
+    }
+    if d.Fee != models.Amount(d.VSize*2) || d.Payments[0].Value != 65_000_000-d.Fee {
+        t.Errorf("paid %d with fee %d for %d vB, want everything less 2 litoshis/vB", d.Payments[0].Value, d.Fee, d.VSize)
+    }
+
+    req.IncludeUnconfirmed = true
+    if d, err = BuildSend(context.Background(), m, req); err != nil || len(d.Inputs) != 3 {
+        t.Errorf("with unconfirmed: %v, want all 3 coins", err)
+    }
+
+    m.UTXOs[w.Address] = m.UTXOs[w.Address][2:]
+    req.IncludeUnconfirmed = false
+    if _, err := BuildSend(context.Background(), m, req); !errors.Is(err, ErrOnlyUnconfirmed) || !errors.Is(err, ErrNoUTXOs) {
+        t.Errorf("err = %v, want ErrOnlyUnconfirmed", err)
+    }
+}
+
+func TestBuildCPFP(t *testing.T) {
+    m, w := fundedMemoryProvider(t, crypto.AddrP2WPKH, 40_000_000, 3_000_000)
+    parent := m.UTXOs[w.Address][1].TxHash
+    m.UTXOs[w.Address][1].Confirmations = 0
+    m.Txs[parent] = models.TxDetail{Hash: parent, Fees: 250, VSize: 250}
+    req := CPFPRequest{Params: &netparams.MainNetParams, Address: w.Address, ParentTxid: parent, FeePerKB: 20_000}
+    pkg, err := BuildCPFP(context.Background(), m, req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(pkg.Child.Inputs) != 1 || pkg.Child.Inputs[0].TxHash != parent {
+        t.Fatalf("child spends %+v, want only the parent's output", pkg.Child.Inputs)
+    }
+    if rate := pkg.FeePerKB(); rate < 20_000 {
+        t.Errorf("package rate = %d, want at least 20000", rate)
+    }
+
+    m.Txs[parent] = models.TxDetail{Hash: parent, Fees: 250, VSize: 250, BlockHeight: 100}
+    if _, err := BuildCPFP(context.Background(), m, req); !errors.Is(err, ErrAlreadyConfirmed) {
+        t.Errorf("err = %v, want ErrAlreadyConfirmed", err)
+    }
+}
+
+func TestBuildBatchSpendsSelectedCoins(t *testing.T) {
+    m, w := fundedMemoryProvider(t, crypto.AddrP2WPKH, 30_000_000, 20_000_000, 10_000_000)
+    coins := m.UTXOs[w.Address]
+    req := BatchRequest{
+        Params: &netparams.MainNetParams, From: w.Address, FeePerKB: 1000,
+        Payments: []txbuilder.Payment{
+            {Address: "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ", Value: 1_000_000},
+            {Address: "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9", Value: 1_000_000},
+        },
+        Coins: CoinControl{Selected: []string{coins[1].Outpoint(), coins[2].Outpoint()}},
+    }
+    drafts, err := BuildBatch(context.Background(), m, req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Either selected coin pays alone, but like BuildSend every one is spent.
+    if len(drafts) != 1 || len(drafts[0].Inputs) != 2 {
+        t.Fatalf("got %d transactions, first spending %d coins; want one spending both selected", len(drafts), len(drafts[0].Inputs))
+    }
+    for _, u := range drafts[0].Inputs {
+        if u.Outpoint() == coins[0].Outpoint() {
+            t.Error("spent a coin that was not selected")
+        }
+    }
+}
+
+func TestBuildBatchSplitsOversizedBatch(t *testing.T) {
+    m, w := fundedMemoryProvider(t, crypto.AddrP2WPKH, 30_000_000, 30_000_000)
+    req := BatchRequest{Params: &netparams.MainNetParams, From: w.Address, FeePerKB: 1000}
+    for i := 0; i < 3500; i++ {
+        req.Payments = append(req.Payments, txbuilder.Payment{Address: "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9", Value: 10_000, Label: strconv.Itoa(i)})
+    }
+    drafts, err := BuildBatch(context.Background(), m, req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(drafts) != 2 {
+        t.Fatalf("got %d transactions, want the batch split in 2", len(drafts))
+    }
+    if drafts[0].Inputs[0].Outpoint() == drafts[1].Inputs[0].Outpoint() {
+        t.Error("both parts spend the same coin")
+    }
+    n := 0
+    for _, d := range drafts {
+        if d.VSize > txbuilder.MaxStandardVSize {
+            t.Errorf("part of %d vB is over the relay limit", d.VSize)
+        }
+        for _, p := range d.Payments {
+            if p.Label != strconv.Itoa(n) {
+                t.Fatalf("payment %d has label %q, want rows kept in order", n, p.Label)
+            }
+            n++
+        }
+    }
+    if n != 3500 {
+        t.Errorf("paid %d payments, want 3500", n)
+    }
+}
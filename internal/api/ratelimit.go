@@ -0,0 +1,164 @@
+package api
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "time"
+)
+
+// RateLimitError is returned when a request would have to wait longer than
+// allowed for the local quota or a server Retry-After to pass.
+type RateLimitError struct {
+    RetryAfter time.Duration
+    // Quota describes the exhausted limit, e.g. "100 requests per 1h0m0s".
+    Quota string
+}
+
+func (e *RateLimitError) Error() string {
+    return fmt.Sprintf("rate limited (%s); retry in %s", e.Quota, e.RetryAfter.Round(time.Second))
+}
+
+func (e *RateLimitError) Is(target error) bool { return target == ErrRateLimited }
+
+// TokenBucket allows Capacity requests per Period, refilled continuously.
+type TokenBucket struct {
+    Capacity float64
+    Period   time.Duration
+    tokens   float64
+    last     time.Time
+}
+
+func NewTokenBucket(capacity int, period time.Duration) *TokenBucket {
+    return &TokenBucket{Capacity: float64(capacity), Period: period, tokens: float64(capacity)}
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+    if !b.last.IsZero() {
+        b.tokens += now.Sub(b.last).Seconds() * b.Capacity / b.Period.Seconds()
+        if b.tokens > b.Capacity {
+            b.tokens = b.Capacity
+        }
+    }
+    b.last = now
+}
+
+// wait is how long until n tokens are available.
+func (b *TokenBucket) wait(n float64) time.Duration {
+    if b.tokens >= n {
+        return 0
+    }
+    return time.Duration((n - b.tokens) * float64(b.Period) / b.Capacity)
+}
+
+func cost(b *TokenBucket, n int) float64 {
+    if float64(n) > b.Capacity {
+        return b.Capacity
+    }
+    return float64(n)
+}
+
+// RateLimiter enforces several token buckets at once (BlockCypher limits
+// both per second and per hour) plus any Retry-After the server sent.
+type RateLimiter struct {
+    // MaxWait is the longest Wait sleeps before giving up with a
+    // *RateLimitError instead.
+    MaxWait time.Duration
+
+    mu           sync.Mutex
+    buckets      []*TokenBucket
+    blockedUntil time.Time
+    now          func() time.Time
+    sleep        func(context.Context, time.Duration) error
+}
+
+func NewRateLimiter(maxWait time.Duration, buckets ...*TokenBucket) *RateLimiter {
+    return &RateLimiter{MaxWait: maxWait, buckets: buckets, now: time.Now, sleep: sleepCtx}
+}
+
+// Wait blocks until a request may be made and takes a token from every
+// bucket.
+func (l *RateLimiter) Wait(ctx context.Context) error { return l.WaitN(ctx, 1) }
+
+// WaitN is Wait for a request that counts as n against the quota, such as a
+// batch lookup. A bucket smaller than n only has to be full.
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+    l.mu.Lock()
+    now := l.now()
+    var delay time.Duration
+    quota := "server Retry-After"
+    if d := l.blockedUntil.Sub(now); d > 0 {
+        delay = d
+    }
+    for _, b := range l.buckets {
+        b.refill(now)
+        if d := b.wait(cost(b, n)); d > delay {
+            delay = d
+            quota = fmt.Sprintf("%.0f requests per %s", b.Capacity, b.Period)
+        }
+    }
+    if delay > l.MaxWait {
+        l.mu.Unlock()
+        return &RateLimitError{RetryAfter: delay, Quota: quota}
+    }
+    if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+        l.mu.Unlock()
+        return &RateLimitError{RetryAfter: delay, Quota: quota}
+    }
+    // Reserve the tokens now so concurrent callers queue behind us.
+    for _, b := range l.buckets {
+        b.tokens -= cost(b, n)
+    }
+    l.mu.Unlock()
+    return l.sleep(ctx, delay)
+}
+
+// SetBuckets replaces the quotas, e.g. once a token's real limits are known.
+func (l *RateLimiter) SetBuckets(buckets ...*TokenBucket) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.buckets = buckets
+}
+
+// BucketStatus is a snapshot of one quota.
+type BucketStatus struct {
+    Capacity  int
+    Period    time.Duration
+    Available int
+}
+
+// Remaining reports how many requests each quota currently allows.
+func (l *RateLimiter) Remaining() []BucketStatus {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    now := l.now()
+    out := make([]BucketStatus, len(l.buckets))
+    for i, b := range l.buckets {
+        b.refill(now)
+        out[i] = BucketStatus{Capacity: int(b.Capacity), Period: b.Period, Available: int(b.tokens)}
+    }
+    return out
+}
+
+// Block makes requests wait until d from now, e.g. after a 429.
+func (l *RateLimiter) Block(d time.Duration) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if until := l.now().Add(d); until.After(l.blockedUntil) {
+        l.blockedUntil = until
+    }
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+    if d <= 0 {
+        return ctx.Err()
+    }
+    t := time.NewTimer(d)
+    defer t.Stop()
+    select {
+    case <-t.C:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
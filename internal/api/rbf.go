@@ -0,0 +1,56 @@
+package api
+
+import (
+    "context"
+    "fmt"
+
+    "litecoin-wallet/internal/crypto"
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "litecoin-wallet/internal/txbuilder"
+    "github.com/btcsuite/btcd/chaincfg"
+)
+
+// BumpRequest is a payment we already broadcast, to be replaced by the same
+// payment at a higher fee.
+type BumpRequest struct {
+    Params   *chaincfg.Params
+    From     string
+    // Change receives the replacement's change; empty means From.
+    Change   string
+    Payments []txbuilder.Payment
+    SendAll  bool
+    // Inputs are the coins the original spent; the replacement spends
+    // exactly these.
+    Inputs []models.UTXO
+    Fee    models.Amount
+    // FeePerKB is the new rate in litoshis per kB; 0 means the provider's
+    // FeeNormal estimate.
+    FeePerKB int64
+}
+
+// BuildBump assembles the unsigned replacement for req. The extra fee is
+// taken from the change, or from the output of a sweep.
+func BuildBump(ctx context.Context, p ChainProvider, req BumpRequest) (*txbuilder.Draft, error) {
+    from, err := netparams.DecodeAddress(req.From, req.Params)
+    if err != nil {
+        return nil, fmt.Errorf("invalid sender address: %w", err)
+    }
+    feePerKB := req.FeePerKB
+    if feePerKB <= 0 {
+        fees, err := p.EstimateFee(ctx)
+        if err != nil {
+            return nil, err
+        }
+        feePerKB = FeeNormal.PerKB(fees)
+    }
+    return txbuilder.Replace(txbuilder.Request{
+        Params:   req.Params,
+        From:     req.From,
+        Change:   req.Change,
+        AddrType: crypto.AddressTypeOf(from),
+        UTXOs:    req.Inputs,
+        Outputs:  req.Payments,
+        SendAll:  req.SendAll,
+    }, req.Fee, feePerKB)
+}
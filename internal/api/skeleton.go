@@ -0,0 +1,162 @@
+package api
+
+import (
+    "bytes"
+    "encoding/hex"
+    "errors"
+    "fmt"
+
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "github.com/btcsuite/btcd/chaincfg"
+    "github.com/btcsuite/btcd/chaincfg/chainhash"
+    "github.com/btcsuite/btcd/txscript"
+    "github.com/btcsuite/btcd/wire"
+)
+
+// DefaultMaxFee is the fee ceiling, in litoshis, applied to BlockCypher
+// skeletons when none is configured (0.01 LTC).
+const DefaultMaxFee = 1_000_000
+
+// ErrSkeletonRejected is wrapped by every SkeletonError.
+var ErrSkeletonRejected = errors.New("transaction skeleton rejected")
+
+// SkeletonCheck names the rule a /txs/new skeleton failed.
+type SkeletonCheck string
+
+const (
+    CheckDestination SkeletonCheck = "destination"
+    CheckAmount      SkeletonCheck = "amount"
+    CheckExtraOutput SkeletonCheck = "unexpected-output"
+    CheckChange      SkeletonCheck = "change"
+    CheckFee         SkeletonCheck = "fee"
+    CheckSighash     SkeletonCheck = "sighash"
+)
+
+// SkeletonError explains why a skeleton returned by BlockCypher was not
+// signed. Nothing is signed or broadcast when it is returned.
+type SkeletonError struct {
+    Check  SkeletonCheck
+    Detail string
+}
+
+func (e *SkeletonError) Error() string {
+    return fmt.Sprintf("refusing to sign server transaction (%s): %s", e.Check, e.Detail)
+}
+
+func (e *SkeletonError) Unwrap() error { return ErrSkeletonRejected }
+
+func rejectSkeleton(check SkeletonCheck, format string, args ...interface{}) error {
+    return &SkeletonError{Check: check, Detail: fmt.Sprintf(format, args...)}
+}
+
+type skeletonTx struct {
+    Fees    models.Amount `json:"fees"`
+    Outputs []struct {
+        Value     models.Amount `json:"value"`
+        Addresses []string      `json:"addresses"`
+        Script    string        `json:"script"`
+    } `json:"outputs"`
+}
+
+type skeletonRequest struct {
+    params  *chaincfg.Params
+    from    string
+    to      string
+    amount  models.Amount
+    sendAll bool
+    maxFee  models.Amount
+}
+
+// verifySkeleton checks that the decoded skeleton pays exactly what was asked
+// for and that every hash we are about to sign commits to those same outputs.
+func verifySkeleton(req skeletonRequest, tx skeletonTx, toSign, toSignTx []string) error {
+    toScript, err := addressScript(req.to, req.params)
+    if err != nil {
+        return err
+    }
+    fromScript, err := addressScript(req.from, req.params)
+    if err != nil {
+        return err
+    }
+    if len(tx.Outputs) == 0 {
+        return rejectSkeleton(CheckDestination, "transaction has no outputs")
+    }
+
+    var expected []*wire.TxOut
+    paid := false
+    for i, out := range tx.Outputs {
+        script, err := hex.DecodeString(out.Script)
+        if err != nil {
+            return rejectSkeleton(CheckExtraOutput, "output %d has an unreadable script", i)
+        }
+        switch {
+        case bytes.Equal(script, toScript) && !paid:
+            paid = true
+            if !req.sendAll && out.Value != req.amount {
+                return rejectSkeleton(CheckAmount, "pays %d litoshis to %s, requested %d", out.Value, req.to, req.amount)
+            }
+        case bytes.Equal(script, fromScript) && !req.sendAll:
+            // change back to us
+        case req.sendAll && bytes.Equal(script, fromScript):
+            return rejectSkeleton(CheckChange, "send-all transaction keeps change at %s", req.from)
+        default:
+            return rejectSkeleton(CheckExtraOutput, "output %d pays %d litoshis to %v, which was not requested", i, out.Value, out.Addresses)
+        }
+        expected = append(expected, wire.NewTxOut(int64(out.Value), script))
+    }
+    if !paid {
+        return rejectSkeleton(CheckDestination, "no output pays %s", req.to)
+    }
+    if tx.Fees < 0 || tx.Fees > req.maxFee {
+        return rejectSkeleton(CheckFee, "fee of %d litoshis exceeds the %d litoshi ceiling", tx.Fees, req.maxFee)
+    }
+
+    if len(toSignTx) != len(toSign) {
+        return rejectSkeleton(CheckSighash, "server sent %d hashes but %d preimages", len(toSign), len(toSignTx))
+    }
+    for i := range toSign {
+        if err := verifyPreimage(toSign[i], toSignTx[i], expected); err != nil {
+            return rejectSkeleton(CheckSighash, "hash %d: %v", i, err)
+        }
+    }
+    return nil
+}
+
+// verifyPreimage recomputes a legacy SIGHASH_ALL digest from its preimage and
+// checks that the preimage spends to exactly the expected outputs, so the
+// signature cannot authorise a different transaction than the one reviewed.
+func verifyPreimage(hashHex, preimageHex string, expected []*wire.TxOut) error {
+    preimage, err := hex.DecodeString(preimageHex)
+    if err != nil || len(preimage) < 4 {
+        return fmt.Errorf("unreadable preimage")
+    }
+    if hex.EncodeToString(chainhash.DoubleHashB(preimage)) != hashHex {
+        return fmt.Errorf("hash does not match its preimage")
+    }
+    hashType := txscript.SigHashType(preimage[len(preimage)-4])
+    if hashType != txscript.SigHashAll {
+        return fmt.Errorf("unexpected sighash type %#x", hashType)
+    }
+    var msg wire.MsgTx
+    if err := msg.DeserializeNoWitness(bytes.NewReader(preimage[:len(preimage)-4])); err != nil {
+        return fmt.Errorf("cannot decode preimage: %v", err)
+    }
+    if len(msg.TxOut) != len(expected) {
+        return fmt.Errorf("signs %d outputs, expected %d", len(msg.TxOut), len(expected))
+    }
+    for i, out := range msg.TxOut {
+        if out.Value != expected[i].Value || !bytes.Equal(out.PkScript, expected[i].PkScript) {
+            return fmt.Errorf("signed output %d differs from the reviewed transaction", i)
+        }
+    }
+    return nil
+}
+
+func addressScript(address string, params *chaincfg.Params) ([]byte, error) {
+    addr, err := netparams.DecodeAddress(address, params)
+    if err != nil {
+        return nil, err
+    }
+    return txscript.PayToAddrScript(addr)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+    "bytes"
+    "encoding/binary"
+    "encoding/hex"
+    "errors"
+    "testing"
+
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "github.com/btcsuite/btcd/chaincfg/chainhash"
+    "github.com/btcsuite/btcd/txscript"
+    "github.com/btcsuite/btcd/wire"
+)
+
+const (
+    skeletonFrom = "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ"
+    skeletonTo   = "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9"
+)
+
+type skeletonOut struct {
+    addr  string
+    value models.Amount
+}
+
+// fakeSkeleton returns the decoded "tx" plus tosign/tosign_tx for a one-input
+// transaction paying outs, the way /txs/new?includeToSignTx=true does.
+func fakeSkeleton(t *testing.T, fee models.Amount, outs ...skeletonOut) (skeletonTx, []string, []string) {
+    t.Helper()
+    var tx skeletonTx
+    msg := wire.NewMsgTx(1)
+    prev := wire.NewOutPoint(&chainhash.Hash{1}, 0)
+    fromScript, _ := addressScript(skeletonFrom, &netparams.MainNetParams)
+    msg.AddTxIn(wire.NewTxIn(prev, fromScript, nil))
+    for _, o := range outs {
+        script, err := addressScript(o.addr, &netparams.MainNetParams)
+        if err != nil {
+            t.Fatal(err)
+        }
+        msg.AddTxOut(wire.NewTxOut(int64(o.value), script))
+        tx.Outputs = append(tx.Outputs, struct {
+            Value     models.Amount `json:"value"`
+            Addresses []string      `json:"addresses"`
+            Script    string        `json:"script"`
+        }{o.value, []string{o.addr}, hex.EncodeToString(script)})
+    }
+    tx.Fees = fee
+    var buf bytes.Buffer
+    msg.SerializeNoWitness(&buf)
+    binary.Write(&buf, binary.LittleEndian, uint32(txscript.SigHashAll))
+    hash := chainhash.DoubleHashB(buf.Bytes())
+    return tx, []string{hex.EncodeToString(hash)}, []string{hex.EncodeToString(buf.Bytes())}
+}
+
+func skeletonReq(amount models.Amount) skeletonRequest {
+    return skeletonRequest{
+        params: &netparams.MainNetParams,
+        from:   skeletonFrom,
+        to:     skeletonTo,
+        amount: amount,
+        maxFee: DefaultMaxFee,
+    }
+}
+
+func TestVerifySkeletonAccepts(t *testing.T) {
+    tx, toSign, toSignTx := fakeSkeleton(t, 10_000, skeletonOut{skeletonTo, 50_000}, skeletonOut{skeletonFrom, 40_000})
+    if err := verifySkeleton(skeletonReq(50_000), tx, toSign, toSignTx); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestVerifySkeletonRejects(t *testing.T) {
+    attacker := "MR8UQSBr5ULwWheBHznrHk2jxyxkHQu8vB"
+    cases := []struct {
+        name string
+        want SkeletonCheck
+        fee  models.Amount
+        outs []skeletonOut
+    }{
+        {"wrong amount", CheckAmount, 10_000, []skeletonOut{{skeletonTo, 49_000}, {skeletonFrom, 41_000}}},
+        {"missing destination", CheckExtraOutput, 10_000, []skeletonOut{{attacker, 50_000}}},
+        {"extra output", CheckExtraOutput, 10_000, []skeletonOut{{skeletonTo, 50_000}, {attacker, 40_000}}},
+        {"fee over ceiling", CheckFee, DefaultMaxFee + 1, []skeletonOut{{skeletonTo, 50_000}}},
+    }
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            tx, toSign, toSignTx := fakeSkeleton(t, tc.fee, tc.outs...)
+            err := verifySkeleton(skeletonReq(50_000), tx, toSign, toSignTx)
+            var se *SkeletonError
+            if !errors.As(err, &se) || se.Check != tc.want {
+                t.Fatalf("err = %v, want %s rejection", err, tc.want)
+            }
+            if !errors.Is(err, ErrSkeletonRejected) {
+                t.Errorf("error does not wrap ErrSkeletonRejected")
+            }
+        })
+    }
+}
+
+func TestVerifySkeletonRejectsHiddenOutputs(t *testing.T) {
+    // The JSON looks right but the hash being signed pays someone else.
+    tx, _, _ := fakeSkeleton(t, 10_000, skeletonOut{skeletonTo, 50_000})
+    _, toSign, toSignTx := fakeSkeleton(t, 10_000, skeletonOut{"MR8UQSBr5ULwWheBHznrHk2jxyxkHQu8vB", 50_000})
+    err := verifySkeleton(skeletonReq(50_000), tx, toSign, toSignTx)
+    var se *SkeletonError
+    if !errors.As(err, &se) || se.Check != CheckSighash {
+        t.Fatalf("err = %v, want sighash rejection", err)
+    }
+
+    // A hash that does not match its preimage is refused too.
+    tx, toSign, toSignTx = fakeSkeleton(t, 10_000, skeletonOut{skeletonTo, 50_000})
+    toSign[0] = hex.EncodeToString(make([]byte, 32))
+    if err := verifySkeleton(skeletonReq(50_000), tx, toSign, toSignTx); !errors.As(err, &se) || se.Check != CheckSighash {
+        t.Fatalf("err = %v, want sighash rejection", err)
+    }
+}
@@ -0,0 +1,121 @@
+// Package batch reads payment lists for sending many payments in one
+// transaction, such as a payroll exported from a spreadsheet.
+package batch
+
+import (
+    "encoding/csv"
+    "errors"
+    "fmt"
+    "io"
+    "strings"
+
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "litecoin-wallet/internal/txbuilder"
+    "github.com/btcsuite/btcd/chaincfg"
+)
+
+var ErrEmpty = errors.New("no payments in file")
+
+// Row is one validated payment.
+type Row struct {
+    Line    int // line in the file, for messages
+    Address string
+    Amount  models.Amount
+    Label   string
+}
+
+// RowError is a row that failed validation.
+type RowError struct {
+    Line int
+    Err  error
+}
+
+func (e *RowError) Error() string { return fmt.Sprintf("line %d: %v", e.Line, e.Err) }
+
+func (e *RowError) Unwrap() error { return e.Err }
+
+// ParseCSV reads address,amount[,label] rows. Amounts take the same forms
+// as everywhere else (a bare number is LTC). An optional header row whose
+// first field is "address" is skipped, as are blank lines and lines
+// starting with #. Every row is checked; if any fail, the returned error
+// joins a *RowError for each of them and no rows are returned.
+func ParseCSV(r io.Reader, params *chaincfg.Params) ([]Row, error) {
+    cr := csv.NewReader(r)
+    cr.Comment = '#'
+    cr.FieldsPerRecord = -1
+    cr.TrimLeadingSpace = true
+    var rows []Row
+    var errs []error
+    for first := true; ; first = false {
+        rec, err := cr.Read()
+        if err == io.EOF {
+            break
+        }
+        line, _ := cr.FieldPos(0)
+        if err != nil {
+            var pe *csv.ParseError
+            if errors.As(err, &pe) {
+                return nil, &RowError{Line: pe.Line, Err: pe.Err}
+            }
+            return nil, err
+        }
+        if first && strings.EqualFold(strings.TrimSpace(rec[0]), "address") {
+            continue
+        }
+        row, err := parseRow(rec, params)
+        if err != nil {
+            errs = append(errs, &RowError{Line: line, Err: err})
+            continue
+        }
+        row.Line = line
+        rows = append(rows, row)
+    }
+    if len(errs) > 0 {
+        return nil, errors.Join(errs...)
+    }
+    if len(rows) == 0 {
+        return nil, ErrEmpty
+    }
+    return rows, nil
+}
+
+func parseRow(rec []string, params *chaincfg.Params) (Row, error) {
+    if len(rec) < 2 || len(rec) > 3 {
+        return Row{}, fmt.Errorf("want address,amount[,label], got %d fields", len(rec))
+    }
+    row := Row{Address: strings.TrimSpace(rec[0])}
+    if _, err := netparams.DecodeAddress(row.Address, params); err != nil {
+        return Row{}, fmt.Errorf("invalid address %q: %v", row.Address, err)
+    }
+    amount, err := models.ParseAmount(rec[1])
+    if err != nil {
+        return Row{}, err
+    }
+    if amount <= txbuilder.DustLimit {
+        return Row{}, fmt.Errorf("amount %s is not above the %s dust limit", amount, models.Amount(txbuilder.DustLimit))
+    }
+    row.Amount = amount
+    if len(rec) == 3 {
+        row.Label = strings.TrimSpace(rec[2])
+    }
+    return row, nil
+}
+
+// Payments turns rows into transaction outputs, labels included.
+func Payments(rows []Row) []txbuilder.Payment {
+    out := make([]txbuilder.Payment, len(rows))
+    for i, r := range rows {
+        out[i] = txbuilder.Payment{Address: r.Address, Value: r.Amount, Label: r.Label}
+    }
+    return out
+}
+
+// Total is the sum of all row amounts.
+func Total(rows []Row) models.Amount {
+    var total models.Amount
+    for _, r := range rows {
+        total += r.Amount
+    }
+    return total
+}
@@ -0,0 +1,65 @@
+package batch
+
+import (
+    "errors"
+    "strings"
+    "testing"
+
+    "litecoin-wallet/internal/netparams"
+)
+
+func TestParseCSV(t *testing.T) {
+    in := `address,amount,label
+# March payroll
+LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ,0.5,Alice
+
+ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9, 250 mLTC
+MR8UQSBr5ULwWheBHznrHk2jxyxkHQu8vB,1500 litoshi,"Carol, contractor"
+`
+    rows, err := ParseCSV(strings.NewReader(in), &netparams.MainNetParams)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(rows) != 3 {
+        t.Fatalf("got %d rows, want 3", len(rows))
+    }
+    if rows[0].Amount != 50_000_000 || rows[0].Label != "Alice" || rows[0].Line != 3 {
+        t.Errorf("row 0 = %+v", rows[0])
+    }
+    if rows[1].Amount != 25_000_000 || rows[1].Label != "" {
+        t.Errorf("row 1 = %+v", rows[1])
+    }
+    if rows[2].Amount != 1500 || rows[2].Label != "Carol, contractor" {
+        t.Errorf("row 2 = %+v", rows[2])
+    }
+    if Total(rows) != 75_001_500 {
+        t.Errorf("total = %d", Total(rows))
+    }
+}
+
+func TestParseCSVReportsEveryBadRow(t *testing.T) {
+    in := `LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ,0.5
+notanaddress,0.5
+LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ,lots
+LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ,100 litoshi
+LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ
+`
+    rows, err := ParseCSV(strings.NewReader(in), &netparams.MainNetParams)
+    if rows != nil || err == nil {
+        t.Fatalf("rows = %v, err = %v; want every row refused", rows, err)
+    }
+    var lines []int
+    for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+        var re *RowError
+        if errors.As(e, &re) {
+            lines = append(lines, re.Line)
+        }
+    }
+    if len(lines) != 4 || lines[0] != 2 || lines[3] != 5 {
+        t.Errorf("bad lines = %v, want 2, 3, 4, 5", lines)
+    }
+
+    if _, err := ParseCSV(strings.NewReader("address,amount\n"), &netparams.MainNetParams); !errors.Is(err, ErrEmpty) {
+        t.Errorf("header only: err = %v, want ErrEmpty", err)
+    }
+}
@@ -0,0 +1,251 @@
+// Package coinselect decides which UTXOs fund a payment. The strategies only
+// see values, confirmations and addresses; the transaction builder tells
+// them what each choice costs through a Target.
+package coinselect
+
+import (
+    "cmp"
+    "errors"
+    "fmt"
+    "slices"
+
+    "litecoin-wallet/internal/models"
+)
+
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// bnbMaxTries bounds the branch-and-bound search so a wallet with many
+// coins cannot stall a send.
+const bnbMaxTries = 100_000
+
+type Strategy string
+
+const (
+    // LeastCost runs the other strategies (except Privacy) and keeps the
+    // choice with the lowest fee plus the cost of later spending its change.
+    LeastCost      Strategy = "auto"
+    BranchAndBound Strategy = "bnb"     // exact match, no change output
+    LargestFirst   Strategy = "largest" // fewest inputs
+    OldestFirst    Strategy = "oldest"  // most confirmations first
+    Privacy        Strategy = "privacy" // stay within as few addresses as possible
+)
+
+// Strategies lists every strategy in menu order.
+var Strategies = []Strategy{LeastCost, BranchAndBound, LargestFirst, OldestFirst, Privacy}
+
+// Label is the human-readable name shown in menus.
+func (s Strategy) Label() string {
+    switch s {
+    case BranchAndBound:
+        return "Branch and bound (no change)"
+    case LargestFirst:
+        return "Largest first"
+    case OldestFirst:
+        return "Oldest first"
+    case Privacy:
+        return "Privacy (avoid mixing addresses)"
+    default:
+        return "Automatic (lowest total cost)"
+    }
+}
+
+// ParseStrategy accepts a strategy name; empty means LeastCost.
+func ParseStrategy(s string) (Strategy, error) {
+    switch st := Strategy(s); st {
+    case "":
+        return LeastCost, nil
+    case LeastCost, BranchAndBound, LargestFirst, OldestFirst, Privacy:
+        return st, nil
+    }
+    return "", fmt.Errorf("unknown coin selection %q", s)
+}
+
+// Target is the payment to fund, with its costs in litoshis.
+type Target struct {
+    // Value is the total paid to the recipients.
+    Value models.Amount
+    // Fee is the fee of the transaction with n inputs, with or without a
+    // change output.
+    Fee func(n int, change bool) models.Amount
+    // ChangeSpendFee is what it will cost to spend a change output later.
+    ChangeSpendFee models.Amount
+    // Dust is the largest change that is left to the miner instead.
+    Dust models.Amount
+}
+
+// Cost is what funding t with coins costs now and later: the fee, plus
+// ChangeSpendFee if there is change, or everything above the payment if
+// there is not. ok is false when the coins do not cover the payment.
+func (t Target) Cost(coins []models.UTXO) (cost models.Amount, ok bool) {
+    total := sum(coins)
+    n := len(coins)
+    if n == 0 || total < t.Value+t.Fee(n, false) {
+        return 0, false
+    }
+    if change := total - t.Value - t.Fee(n, true); change > t.Dust {
+        return t.Fee(n, true) + t.ChangeSpendFee, true
+    }
+    return total - t.Value, true
+}
+
+// Select picks the coins that fund t using strategy s.
+func Select(s Strategy, utxos []models.UTXO, t Target) ([]models.UTXO, error) {
+    switch s {
+    case BranchAndBound:
+        return branchAndBound(utxos, t)
+    case LargestFirst:
+        return largestFirst(utxos, t)
+    case OldestFirst:
+        return oldestFirst(utxos, t)
+    case Privacy:
+        return privacy(utxos, t)
+    case LeastCost, "":
+        return leastCost(utxos, t)
+    }
+    return nil, fmt.Errorf("unknown coin selection %q", s)
+}
+
+func leastCost(utxos []models.UTXO, t Target) ([]models.UTXO, error) {
+    var best []models.UTXO
+    var bestCost models.Amount
+    for _, pick := range []func([]models.UTXO, Target) ([]models.UTXO, error){branchAndBound, largestFirst, oldestFirst} {
+        coins, err := pick(utxos, t)
+        if err != nil {
+            continue
+        }
+        if cost, ok := t.Cost(coins); ok && (best == nil || cost < bestCost) {
+            best, bestCost = coins, cost
+        }
+    }
+    if best == nil {
+        return nil, ErrInsufficientFunds
+    }
+    return best, nil
+}
+
+// branchAndBound searches for a set of coins that pays t without change:
+// the excess over payment and fee must be small enough that the change
+// would be dust. Of those it keeps the one with the least excess.
+func branchAndBound(utxos []models.UTXO, t Target) ([]models.UTXO, error) {
+    // Coins worth less than the fee to spend them only add cost.
+    marginal := t.Fee(1, false) - t.Fee(0, false)
+    var coins []models.UTXO
+    for _, u := range utxos {
+        if u.Value > marginal {
+            coins = append(coins, u)
+        }
+    }
+    slices.SortStableFunc(coins, func(a, b models.UTXO) int { return cmp.Compare(b.Value, a.Value) })
+    // rest[i] is what coins[i:] add at most once their fees are paid.
+    rest := make([]models.Amount, len(coins)+1)
+    for i := len(coins) - 1; i >= 0; i-- {
+        rest[i] = rest[i+1] + coins[i].Value - marginal
+    }
+
+    var pick, best []int
+    bestExcess := models.Amount(-1)
+    tries := 0
+    var search func(i int, total models.Amount)
+    search = func(i int, total models.Amount) {
+        if tries >= bnbMaxTries {
+            return
+        }
+        tries++
+        n := len(pick)
+        excess := total - t.Value - t.Fee(n, false)
+        if n > 0 && excess >= 0 {
+            // Adding more coins only raises the excess.
+            if excess <= t.Fee(n, true)-t.Fee(n, false)+t.Dust && (bestExcess < 0 || excess < bestExcess) {
+                best, bestExcess = slices.Clone(pick), excess
+            }
+            return
+        }
+        if i == len(coins) || excess+rest[i] < 0 {
+            return
+        }
+        pick = append(pick, i)
+        search(i+1, total+coins[i].Value)
+        pick = pick[:n]
+        search(i+1, total)
+    }
+    search(0, 0)
+    if best == nil {
+        return nil, ErrInsufficientFunds
+    }
+    out := make([]models.UTXO, len(best))
+    for i, j := range best {
+        out[i] = coins[j]
+    }
+    return out, nil
+}
+
+func largestFirst(utxos []models.UTXO, t Target) ([]models.UTXO, error) {
+    coins := slices.Clone(utxos)
+    slices.SortStableFunc(coins, func(a, b models.UTXO) int { return cmp.Compare(b.Value, a.Value) })
+    return accumulate(coins, t)
+}
+
+func oldestFirst(utxos []models.UTXO, t Target) ([]models.UTXO, error) {
+    coins := slices.Clone(utxos)
+    slices.SortStableFunc(coins, func(a, b models.UTXO) int { return cmp.Compare(b.Confirmations, a.Confirmations) })
+    return accumulate(coins, t)
+}
+
+// privacy funds t from as few addresses as possible and spends every coin
+// of each address it touches, so no leftover coin later links the
+// addresses together again. An address that can pay alone is preferred,
+// the cheapest one if several can; otherwise addresses are added richest
+// first.
+func privacy(utxos []models.UTXO, t Target) ([]models.UTXO, error) {
+    var order []string
+    clusters := map[string][]models.UTXO{}
+    for _, u := range utxos {
+        if _, ok := clusters[u.Address]; !ok {
+            order = append(order, u.Address)
+        }
+        clusters[u.Address] = append(clusters[u.Address], u)
+    }
+    var best []models.UTXO
+    var bestCost models.Amount
+    for _, addr := range order {
+        if cost, ok := t.Cost(clusters[addr]); ok && (best == nil || cost < bestCost) {
+            best, bestCost = clusters[addr], cost
+        }
+    }
+    if best != nil {
+        return slices.Clone(best), nil
+    }
+    slices.SortStableFunc(order, func(a, b string) int { return cmp.Compare(sum(clusters[b]), sum(clusters[a])) })
+    var coins []models.UTXO
+    for _, addr := range order {
+        coins = append(coins, clusters[addr]...)
+        if _, ok := t.Cost(coins); ok {
+            return coins, nil
+        }
+    }
+    return nil, ErrInsufficientFunds
+}
+
+// accumulate takes coins in order until they pay t with change, or failing
+// that, at least without.
+func accumulate(coins []models.UTXO, t Target) ([]models.UTXO, error) {
+    var total models.Amount
+    for i, u := range coins {
+        total += u.Value
+        if total >= t.Value+t.Fee(i+1, true) {
+            return coins[:i+1], nil
+        }
+    }
+    if _, ok := t.Cost(coins); ok {
+        return coins, nil
+    }
+    return nil, ErrInsufficientFunds
+}
+
+func sum(coins []models.UTXO) models.Amount {
+    var total models.Amount
+    for _, u := range coins {
+        total += u.Value
+    }
+    return total
+}
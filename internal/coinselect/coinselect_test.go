@@ -0,0 +1,107 @@
+package coinselect
+
+import (
+    "errors"
+    "fmt"
+    "reflect"
+    "testing"
+
+    "litecoin-wallet/internal/models"
+)
+
+// testTarget charges 10 litoshis per vbyte for a 41 vbyte base, 68 vbyte
+// inputs and a 31 vbyte change output.
+func testTarget(value models.Amount) Target {
+    return Target{
+        Value: value,
+        Fee: func(n int, change bool) models.Amount {
+            size := models.Amount(41 + 68*n)
+            if change {
+                size += 31
+            }
+            return size * 10
+        },
+        ChangeSpendFee: 680,
+        Dust:           546,
+    }
+}
+
+func coin(addr string, value models.Amount, confs int) models.UTXO {
+    return models.UTXO{TxHash: fmt.Sprintf("%s-%d", addr, value), Value: value, Confirmations: confs, Address: addr}
+}
+
+func values(coins []models.UTXO) []models.Amount {
+    var v []models.Amount
+    for _, c := range coins {
+        v = append(v, c.Value)
+    }
+    return v
+}
+
+func TestStrategies(t *testing.T) {
+    utxos := []models.UTXO{
+        coin("A", 20_000, 50),
+        coin("A", 100_000, 3),
+        coin("B", 30_000, 10),
+        coin("B", 50_000, 0),
+    }
+    tests := []struct {
+        strategy Strategy
+        value    models.Amount
+        want     []models.Amount
+    }{
+        // 50k+30k pays 78k plus a 1770 fee with 230 over: no change needed,
+        // which beats a change output from the 100k coin.
+        {BranchAndBound, 78_000, []models.Amount{50_000, 30_000}},
+        {LeastCost, 78_000, []models.Amount{50_000, 30_000}},
+        {LargestFirst, 78_000, []models.Amount{100_000}},
+        {OldestFirst, 78_000, []models.Amount{20_000, 30_000, 100_000}},
+        // Either address can pay alone; B's coins need no change.
+        {Privacy, 78_000, []models.Amount{30_000, 50_000}},
+        // Both need change and cost the same, so the first address wins.
+        {Privacy, 10_000, []models.Amount{20_000, 100_000}},
+        // Without an exact match the cheapest is one input with change.
+        {LeastCost, 10_000, []models.Amount{100_000}},
+        // No address can pay alone: the richer one first, then the other.
+        {Privacy, 150_000, []models.Amount{20_000, 100_000, 30_000, 50_000}},
+    }
+    for _, tt := range tests {
+        for run := 0; run < 2; run++ {
+            got, err := Select(tt.strategy, utxos, testTarget(tt.value))
+            if err != nil {
+                t.Fatalf("%s %d: %v", tt.strategy, tt.value, err)
+            }
+            if !reflect.DeepEqual(values(got), tt.want) {
+                t.Errorf("%s %d: picked %v, want %v", tt.strategy, tt.value, values(got), tt.want)
+            }
+        }
+    }
+}
+
+func TestBranchAndBoundChangeless(t *testing.T) {
+    utxos := []models.UTXO{coin("A", 100_000, 1), coin("A", 25_000, 1), coin("A", 5_000, 1)}
+    tg := testTarget(70_000)
+    if _, err := Select(BranchAndBound, utxos, tg); !errors.Is(err, ErrInsufficientFunds) {
+        t.Errorf("err = %v, want no changeless solution", err)
+    }
+    tg.Value = 100_000 - tg.Fee(1, false) - 100
+    got, err := Select(BranchAndBound, utxos, tg)
+    if err != nil || !reflect.DeepEqual(values(got), []models.Amount{100_000}) {
+        t.Fatalf("picked %v, %v", values(got), err)
+    }
+    if cost, ok := tg.Cost(got); !ok || cost != tg.Fee(1, false)+100 {
+        t.Errorf("cost = %d, %v; the excess should go to the fee", cost, ok)
+    }
+}
+
+func TestInsufficientFunds(t *testing.T) {
+    utxos := []models.UTXO{coin("A", 10_000, 1), coin("B", 5_000, 1)}
+    for _, s := range Strategies {
+        if _, err := Select(s, utxos, testTarget(14_000)); !errors.Is(err, ErrInsufficientFunds) {
+            t.Errorf("%s: err = %v, want ErrInsufficientFunds", s, err)
+        }
+    }
+    if _, err := ParseStrategy("random"); err == nil {
+        t.Error("ParseStrategy accepted an unknown name")
+    }
+}
@@ -0,0 +1,133 @@
+// Package config loads wallet settings from an optional JSON file in the
+// working directory, with environment variables taking precedence.
+package config
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+
+    "litecoin-wallet/internal/models"
+)
+
+const (
+    DefaultFile = "crypto-transit.json"
+    envFile     = "LTC_CONFIG"
+)
+
+type Config struct {
+    // Network is mainnet, testnet or regtest.
+    Network string `json:"network"`
+    // BlockCypherURL overrides the BlockCypher base URL, e.g. for a
+    // compatible self-hosted API on networks BlockCypher does not serve.
+    BlockCypherURL string `json:"blockcypher_url"`
+    // BlockCypherToken is an optional API token, which raises BlockCypher's
+    // request limits.
+    BlockCypherToken string `json:"blockcypher_token"`
+    // SendMode is "local" (build and sign transactions here) or "skeleton"
+    // (let BlockCypher's /txs/new assemble legacy P2PKH sends).
+    SendMode string `json:"send_mode"`
+    // MaxFee is the largest fee, in litoshis, accepted from a BlockCypher
+    // skeleton in skeleton send mode. Zero keeps the built-in ceiling.
+    MaxFee models.Amount `json:"max_fee"`
+
+    // Provider selects the chain backend: "blockcypher", "litecoind" or
+    // "electrum".
+    Provider string `json:"provider"`
+    // RPCURL is litecoind's JSON-RPC endpoint; empty means localhost on the
+    // network's default RPC port.
+    RPCURL      string `json:"rpc_url"`
+    RPCUser     string `json:"rpc_user"`
+    RPCPassword string `json:"rpc_password"`
+    // RPCCookie is the path to litecoind's .cookie file, used when RPCUser
+    // is empty. Empty means the default data directory.
+    RPCCookie string `json:"rpc_cookie"`
+    // RPCWallet names a litecoind wallet watching our addresses; when set
+    // UTXOs come from listunspent instead of scantxoutset.
+    RPCWallet string `json:"rpc_wallet"`
+
+    // ElectrumServer is "ssl://host:port" or "tcp://host:port".
+    ElectrumServer string `json:"electrum_server"`
+    // ElectrumInsecure accepts self-signed TLS certificates.
+    ElectrumInsecure bool `json:"electrum_insecure"`
+
+    // Providers lists backends in priority order; when set it replaces
+    // Provider and requests fail over down the list.
+    Providers []string `json:"providers"`
+    // CrossCheck compares UTXOs from two backends before every send.
+    CrossCheck bool `json:"cross_check"`
+}
+
+// Load reads the config file (LTC_CONFIG or crypto-transit.json) if it exists
+// and applies environment overrides.
+func Load() (*Config, error) {
+    cfg := &Config{Network: "mainnet", SendMode: "local", Provider: "blockcypher"}
+    path := os.Getenv(envFile)
+    if path == "" {
+        path = DefaultFile
+    }
+    data, err := os.ReadFile(path)
+    switch {
+    case err == nil:
+        if err := json.Unmarshal(data, cfg); err != nil {
+            return nil, fmt.Errorf("parse %s: %w", path, err)
+        }
+    case !errors.Is(err, os.ErrNotExist) || os.Getenv(envFile) != "":
+        return nil, err
+    }
+    if err := applyEnv(cfg); err != nil {
+        return nil, err
+    }
+    return cfg, nil
+}
+
+func applyEnv(cfg *Config) error {
+    for env, dst := range map[string]*string{
+        "LTC_NETWORK":           &cfg.Network,
+        "LTC_BLOCKCYPHER_URL":   &cfg.BlockCypherURL,
+        "LTC_BLOCKCYPHER_TOKEN": &cfg.BlockCypherToken,
+        "LTC_SEND_MODE":         &cfg.SendMode,
+        "LTC_PROVIDER":          &cfg.Provider,
+        "LTC_RPC_URL":           &cfg.RPCURL,
+        "LTC_RPC_USER":          &cfg.RPCUser,
+        "LTC_RPC_PASSWORD":      &cfg.RPCPassword,
+        "LTC_RPC_COOKIE":        &cfg.RPCCookie,
+        "LTC_RPC_WALLET":        &cfg.RPCWallet,
+        "LTC_ELECTRUM_SERVER":   &cfg.ElectrumServer,
+    } {
+        if v, ok := os.LookupEnv(env); ok {
+            *dst = v
+        }
+    }
+    if v, ok := os.LookupEnv("LTC_MAX_FEE"); ok {
+        fee, err := models.ParseAmountIn(v, models.UnitLitoshi)
+        if err != nil || fee < 0 {
+            return fmt.Errorf("LTC_MAX_FEE must be a non-negative number of litoshis, got %q", v)
+        }
+        cfg.MaxFee = fee
+    }
+    for env, dst := range map[string]*bool{
+        "LTC_ELECTRUM_INSECURE": &cfg.ElectrumInsecure,
+        "LTC_CROSS_CHECK":       &cfg.CrossCheck,
+    } {
+        if v, ok := os.LookupEnv(env); ok {
+            b, err := strconv.ParseBool(v)
+            if err != nil {
+                return fmt.Errorf("%s must be true or false, got %q", env, v)
+            }
+            *dst = b
+        }
+    }
+    if v, ok := os.LookupEnv("LTC_PROVIDERS"); ok {
+        cfg.Providers = nil
+        for _, name := range strings.Split(v, ",") {
+            if name = strings.TrimSpace(name); name != "" {
+                cfg.Providers = append(cfg.Providers, name)
+            }
+        }
+    }
+    return nil
+}
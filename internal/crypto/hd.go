@@ -0,0 +1,122 @@
+package crypto
+
+import (
+    "encoding/hex"
+    "fmt"
+    "strconv"
+    "strings"
+
+    "github.com/btcsuite/btcd/btcutil/hdkeychain"
+    "github.com/btcsuite/btcd/chaincfg"
+)
+
+// PurposeBIP44 is the legacy purpose level. BIP49 and BIP84 reuse the same
+// layout with purpose 49 and 84, see AddressType.Purpose. The coin type comes
+// from the network: 2 on mainnet, 1 on testnet and regtest.
+const PurposeBIP44 = 44
+
+// The change level of a BIP44 path: addresses handed out for payments are
+// on the receive chain, change from our own sends goes to the change chain.
+const (
+    ChainReceive uint32 = 0
+    ChainChange  uint32 = 1
+)
+
+// HDWallet is a BIP32 hierarchy rooted at a BIP39 seed. Only the seed is
+// persisted; every key is re-derived on demand.
+type HDWallet struct {
+    Seed   string
+    params *chaincfg.Params
+    master *hdkeychain.ExtendedKey
+}
+
+// NewHDWallet creates a wallet from a freshly generated mnemonic and returns
+// the mnemonic so it can be shown to the user once for backup.
+func NewHDWallet(params *chaincfg.Params, words int, passphrase string) (*HDWallet, string, error) {
+    mnemonic, err := NewMnemonic(words)
+    if err != nil {
+        return nil, "", err
+    }
+    hd, err := RestoreHDWallet(params, mnemonic, passphrase)
+    return hd, mnemonic, err
+}
+
+// RestoreHDWallet rebuilds the wallet from a BIP39 mnemonic and passphrase.
+func RestoreHDWallet(params *chaincfg.Params, mnemonic, passphrase string) (*HDWallet, error) {
+    if err := ValidateMnemonic(mnemonic); err != nil {
+        return nil, err
+    }
+    return LoadHDWallet(params, hex.EncodeToString(MnemonicToSeed(mnemonic, passphrase)))
+}
+
+// LoadHDWallet opens a wallet from the hex seed stored in the database.
+func LoadHDWallet(params *chaincfg.Params, seedHex string) (*HDWallet, error) {
+    seed, err := hex.DecodeString(seedHex)
+    if err != nil {
+        return nil, fmt.Errorf("invalid seed")
+    }
+    master, err := hdkeychain.NewMaster(seed, params)
+    if err != nil {
+        return nil, err
+    }
+    return &HDWallet{Seed: seedHex, params: params, master: master}, nil
+}
+
+// DerivationPath formats m/purpose'/coin'/account'/change/index for addrType.
+func (h *HDWallet) DerivationPath(addrType AddressType, account, change, index uint32) string {
+    return fmt.Sprintf("m/%d'/%d'/%d'/%d/%d", addrType.Purpose(), h.params.HDCoinType, account, change, index)
+}
+
+// SiblingPath returns the path of another address in the same account as
+// path, a full m/purpose'/coin'/account'/change/index path, at change/index.
+func SiblingPath(path string, change, index uint32) (string, error) {
+    parts := strings.Split(strings.TrimSpace(path), "/")
+    if len(parts) != 6 || parts[0] != "m" {
+        return "", fmt.Errorf("invalid derivation path %q", path)
+    }
+    return fmt.Sprintf("%s/%d/%d", strings.Join(parts[:4], "/"), change, index), nil
+}
+
+// Derive returns the key pair at m/purpose'/coin'/account'/change/index.
+func (h *HDWallet) Derive(addrType AddressType, account, change, index uint32) (*LitecoinWallet, error) {
+    return h.DerivePath(h.DerivationPath(addrType, account, change, index))
+}
+
+// DerivePath returns the key pair at an arbitrary path such as "m/84'/2'/0'/0/0".
+// The address type follows the purpose level: 84' native SegWit, 49'
+// P2SH-SegWit, anything else legacy.
+func (h *HDWallet) DerivePath(path string) (*LitecoinWallet, error) {
+    parts := strings.Split(strings.TrimSpace(path), "/")
+    if len(parts) == 0 || parts[0] != "m" {
+        return nil, fmt.Errorf("invalid derivation path %q", path)
+    }
+    key := h.master
+    addrType := AddrP2PKH
+    for depth, p := range parts[1:] {
+        offset := uint32(0)
+        if strings.HasSuffix(p, "'") || strings.HasSuffix(p, "h") {
+            offset = hdkeychain.HardenedKeyStart
+            p = p[:len(p)-1]
+        }
+        n, err := strconv.ParseUint(p, 10, 31)
+        if err != nil {
+            return nil, fmt.Errorf("invalid derivation path %q", path)
+        }
+        if key, err = key.Derive(offset + uint32(n)); err != nil {
+            return nil, err
+        }
+        if depth == 0 && offset != 0 {
+            switch uint32(n) {
+            case AddrP2WPKH.Purpose():
+                addrType = AddrP2WPKH
+            case AddrP2SHP2WPKH.Purpose():
+                addrType = AddrP2SHP2WPKH
+            }
+        }
+    }
+    priv, err := key.ECPrivKey()
+    if err != nil {
+        return nil, err
+    }
+    return walletFromPrivKey(h.params, priv, addrType)
+}
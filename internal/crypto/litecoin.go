@@ -9,44 +9,115 @@ import (
     "github.com/btcsuite/btcd/chaincfg"
 )
 
+// AddressType selects how a public key is turned into a receiving address.
+type AddressType string
+
+const (
+    AddrP2PKH      AddressType = "p2pkh"       // legacy L…, BIP44
+    AddrP2SHP2WPKH AddressType = "p2sh-p2wpkh" // P2SH-wrapped SegWit M…, BIP49
+    AddrP2WPKH     AddressType = "p2wpkh"      // native SegWit ltc1q…, BIP84
+)
+
+// Label is the human-readable name shown in menus.
+func (t AddressType) Label() string {
+    switch t {
+    case AddrP2WPKH:
+        return "Native SegWit (P2WPKH)"
+    case AddrP2SHP2WPKH:
+        return "P2SH-SegWit (P2SH-P2WPKH)"
+    default:
+        return "Legacy (P2PKH)"
+    }
+}
+
+// Purpose is the BIP43 purpose level used for HD derivation of this type.
+func (t AddressType) Purpose() uint32 {
+    switch t {
+    case AddrP2WPKH:
+        return 84
+    case AddrP2SHP2WPKH:
+        return 49
+    default:
+        return PurposeBIP44
+    }
+}
+
+// ParseAddressType accepts the stored name of an address type; empty means legacy.
+func ParseAddressType(s string) (AddressType, error) {
+    switch t := AddressType(s); t {
+    case "", AddrP2PKH:
+        return AddrP2PKH, nil
+    case AddrP2SHP2WPKH, AddrP2WPKH:
+        return t, nil
+    }
+    return "", fmt.Errorf("unknown address type %q", s)
+}
+
 type LitecoinWallet struct {
-    PrivateKey string
-    PublicKey  string
-    Address    string
+    PrivateKey  string
+    PublicKey   string
+    Address     string
+    AddressType AddressType
 }
 
-func GenerateLitecoinWallet() (*LitecoinWallet, error) {
+func GenerateLitecoinWallet(params *chaincfg.Params, addrType AddressType) (*LitecoinWallet, error) {
     priv, err := btcec.NewPrivateKey()
     if err != nil {
         return nil, err
     }
-    pub := priv.PubKey()
-    pkh := btcutil.Hash160(pub.SerializeCompressed())
-    addr, err := btcutil.NewAddressPubKeyHash(pkh, &chaincfg.MainNetParams)
-    if err != nil {
-        return nil, err
-    }
-    return &LitecoinWallet{
-        PrivateKey: hex.EncodeToString(priv.Serialize()),
-        PublicKey:  hex.EncodeToString(pub.SerializeCompressed()),
-        Address:    addr.EncodeAddress(),
-    }, nil
+    return walletFromPrivKey(params, priv, addrType)
 }
 
-func LoadLitecoinWallet(privateKeyHex string) (*LitecoinWallet, error) {
+func LoadLitecoinWallet(params *chaincfg.Params, privateKeyHex string, addrType AddressType) (*LitecoinWallet, error) {
     privBytes, err := hex.DecodeString(privateKeyHex)
     if err != nil {
         return nil, fmt.Errorf("invalid private key")
     }
-    _, pub := btcec.PrivKeyFromBytes(privBytes)
-    pkh := btcutil.Hash160(pub.SerializeCompressed())
-    addr, err := btcutil.NewAddressPubKeyHash(pkh, &chaincfg.MainNetParams)
+    priv, _ := btcec.PrivKeyFromBytes(privBytes)
+    return walletFromPrivKey(params, priv, addrType)
+}
+
+func walletFromPrivKey(params *chaincfg.Params, priv *btcec.PrivateKey, addrType AddressType) (*LitecoinWallet, error) {
+    pub := priv.PubKey()
+    addr, err := AddressFromPubKey(params, pub, addrType)
     if err != nil {
         return nil, err
     }
     return &LitecoinWallet{
-        PrivateKey: privateKeyHex,
-        PublicKey:  hex.EncodeToString(pub.SerializeCompressed()),
-        Address:    addr.EncodeAddress(),
+        PrivateKey:  hex.EncodeToString(priv.Serialize()),
+        PublicKey:   hex.EncodeToString(pub.SerializeCompressed()),
+        Address:     addr.EncodeAddress(),
+        AddressType: addrType,
     }, nil
-}
\ No newline at end of file
+}
+
+// AddressFromPubKey builds the address of the given type for a compressed key.
+func AddressFromPubKey(params *chaincfg.Params, pub *btcec.PublicKey, addrType AddressType) (btcutil.Address, error) {
+    pkh := btcutil.Hash160(pub.SerializeCompressed())
+    switch addrType {
+    case AddrP2WPKH:
+        return btcutil.NewAddressWitnessPubKeyHash(pkh, params)
+    case AddrP2SHP2WPKH:
+        redeem, err := witnessRedeemScript(pkh)
+        if err != nil {
+            return nil, err
+        }
+        return btcutil.NewAddressScriptHash(redeem, params)
+    case AddrP2PKH, "":
+        return btcutil.NewAddressPubKeyHash(pkh, params)
+    }
+    return nil, fmt.Errorf("unknown address type %q", addrType)
+}
+
+// AddressTypeOf reports which of our address types addr is. P2SH is assumed to
+// wrap P2WPKH since that is the only script hash address this wallet creates.
+func AddressTypeOf(addr btcutil.Address) AddressType {
+    switch addr.(type) {
+    case *btcutil.AddressWitnessPubKeyHash:
+        return AddrP2WPKH
+    case *btcutil.AddressScriptHash:
+        return AddrP2SHP2WPKH
+    default:
+        return AddrP2PKH
+    }
+}
@@ -0,0 +1,93 @@
+package crypto
+
+import (
+    "testing"
+
+    "litecoin-wallet/internal/netparams"
+)
+
+const abandonMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestLoadLitecoinWalletGoldenVectors(t *testing.T) {
+    const one = "0000000000000000000000000000000000000000000000000000000000000001"
+    tests := map[AddressType]string{
+        AddrP2PKH:      "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ",
+        AddrP2SHP2WPKH: "MR8UQSBr5ULwWheBHznrHk2jxyxkHQu8vB",
+        AddrP2WPKH:     "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9",
+    }
+    for addrType, want := range tests {
+        w, err := LoadLitecoinWallet(&netparams.MainNetParams, one, addrType)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if w.Address != want {
+            t.Errorf("%s address = %s, want %s", addrType, w.Address, want)
+        }
+    }
+}
+
+func TestHDWalletGoldenVectors(t *testing.T) {
+    hd, err := RestoreHDWallet(&netparams.MainNetParams, abandonMnemonic, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := map[AddressType]string{
+        AddrP2PKH:      "LUWPbpM43E2p7ZSh8cyTBEkvpHmr3cB8Ez",
+        AddrP2SHP2WPKH: "M7wtsL7wSHDBJVMWWhtQfTMSYYkyooAAXM",
+        AddrP2WPKH:     "ltc1qjmxnz78nmc8nq77wuxh25n2es7rzm5c2rkk4wh",
+    }
+    for addrType, want := range tests {
+        w, err := hd.Derive(addrType, 0, 0, 0)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if w.Address != want {
+            t.Errorf("%s address = %s, want %s", hd.DerivationPath(addrType, 0, 0, 0), w.Address, want)
+        }
+    }
+}
+
+func TestHDWalletTestnetCoinType(t *testing.T) {
+    hd, err := RestoreHDWallet(&netparams.TestNet4Params, abandonMnemonic, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got, want := hd.DerivationPath(AddrP2WPKH, 0, 0, 0), "m/84'/1'/0'/0/0"; got != want {
+        t.Errorf("path = %s, want %s", got, want)
+    }
+    w, err := hd.Derive(AddrP2WPKH, 0, 0, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := netparams.DecodeAddress(w.Address, &netparams.TestNet4Params); err != nil {
+        t.Errorf("testnet address %s does not decode: %v", w.Address, err)
+    }
+}
+
+func TestSiblingPathStaysInAccount(t *testing.T) {
+    hd, err := RestoreHDWallet(&netparams.MainNetParams, abandonMnemonic, "")
+    if err != nil {
+        t.Fatal(err)
+    }
+    path, err := SiblingPath(hd.DerivationPath(AddrP2WPKH, 3, ChainReceive, 0), ChainChange, 7)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if path != "m/84'/2'/3'/1/7" {
+        t.Errorf("path = %s, want m/84'/2'/3'/1/7", path)
+    }
+    got, err := hd.DerivePath(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    want, err := hd.Derive(AddrP2WPKH, 3, ChainChange, 7)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got.Address != want.Address {
+        t.Errorf("change address = %s, want %s", got.Address, want.Address)
+    }
+    if _, err := SiblingPath("m/84'/2'/3'", ChainChange, 0); err == nil {
+        t.Error("account-level path accepted")
+    }
+}
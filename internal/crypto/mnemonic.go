@@ -0,0 +1,94 @@
+package crypto
+
+import (
+    "crypto/rand"
+    "crypto/sha256"
+    "crypto/sha512"
+    _ "embed"
+    "fmt"
+    "strings"
+
+    "golang.org/x/crypto/pbkdf2"
+)
+
+//go:embed bip39_english.txt
+var englishWordlist string
+
+var (
+    bip39Words = strings.Fields(englishWordlist)
+    bip39Index = func() map[string]int {
+        m := make(map[string]int, len(bip39Words))
+        for i, w := range bip39Words {
+            m[w] = i
+        }
+        return m
+    }()
+)
+
+// NewMnemonic returns a fresh BIP39 English mnemonic of 12, 15, 18, 21 or 24 words.
+func NewMnemonic(words int) (string, error) {
+    if words < 12 || words > 24 || words%3 != 0 {
+        return "", fmt.Errorf("mnemonic must have 12, 15, 18, 21 or 24 words")
+    }
+    entropy := make([]byte, words/3*4)
+    if _, err := rand.Read(entropy); err != nil {
+        return "", err
+    }
+    return EntropyToMnemonic(entropy)
+}
+
+// EntropyToMnemonic encodes 16-32 bytes of entropy as a BIP39 mnemonic.
+func EntropyToMnemonic(entropy []byte) (string, error) {
+    if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+        return "", fmt.Errorf("invalid entropy length %d", len(entropy))
+    }
+    sum := sha256.Sum256(entropy)
+    csBits := len(entropy) / 4
+    bits := append(append([]byte{}, entropy...), sum[0])
+    n := (len(entropy)*8 + csBits) / 11
+    words := make([]string, n)
+    for i := 0; i < n; i++ {
+        idx := 0
+        for b := i * 11; b < i*11+11; b++ {
+            idx = idx<<1 | int(bits[b/8]>>(7-uint(b%8))&1)
+        }
+        words[i] = bip39Words[idx]
+    }
+    return strings.Join(words, " "), nil
+}
+
+// ValidateMnemonic checks word count, wordlist membership and the checksum.
+func ValidateMnemonic(mnemonic string) error {
+    words := strings.Fields(strings.ToLower(mnemonic))
+    if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+        return fmt.Errorf("mnemonic must have 12, 15, 18, 21 or 24 words, got %d", len(words))
+    }
+    bits := make([]byte, (len(words)*11+7)/8)
+    for i, w := range words {
+        idx, ok := bip39Index[w]
+        if !ok {
+            return fmt.Errorf("word %d (%q) is not in the BIP39 wordlist", i+1, w)
+        }
+        for j := 0; j < 11; j++ {
+            if idx>>(10-uint(j))&1 == 1 {
+                b := i*11 + j
+                bits[b/8] |= 1 << (7 - uint(b%8))
+            }
+        }
+    }
+    entLen := len(words) / 3 * 4
+    sum := sha256.Sum256(bits[:entLen])
+    csBits := uint(entLen / 4)
+    mask := byte(0xff) << (8 - csBits)
+    if bits[entLen]&mask != sum[0]&mask {
+        return fmt.Errorf("mnemonic checksum mismatch")
+    }
+    return nil
+}
+
+// MnemonicToSeed derives the 64-byte BIP39 seed. The optional passphrase is
+// the "25th word"; a different passphrase yields an unrelated wallet.
+func MnemonicToSeed(mnemonic, passphrase string) []byte {
+    normalized := strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+    return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), 2048, 64, sha512.New)
+}
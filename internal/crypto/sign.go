@@ -0,0 +1,114 @@
+package crypto
+
+import (
+    "encoding/hex"
+    "fmt"
+
+    "github.com/btcsuite/btcd/btcec/v2"
+    "github.com/btcsuite/btcd/btcutil"
+    "github.com/btcsuite/btcd/txscript"
+    "github.com/btcsuite/btcd/wire"
+)
+
+// InputWeight is the worst-case weight of one input spending an output of
+// this type: outpoint, sequence and scriptSig count four units per byte,
+// witness data one. That is 148 vB for P2PKH, 91 for P2SH-P2WPKH, 68 for P2WPKH.
+func (t AddressType) InputWeight() int64 {
+    const outpointAndSequence = 32 + 4 + 4
+    const witness = 1 + 1 + 72 + 1 + 33 // item count, signature, pubkey
+    switch t {
+    case AddrP2WPKH:
+        return (outpointAndSequence+1)*4 + witness
+    case AddrP2SHP2WPKH:
+        return (outpointAndSequence+1+23)*4 + witness
+    default:
+        return (outpointAndSequence + 1 + 1 + 72 + 1 + 33) * 4
+    }
+}
+
+// witnessRedeemScript is the P2WPKH program "0 <pkh>" that a BIP49 address commits to.
+func witnessRedeemScript(pkh []byte) ([]byte, error) {
+    return txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(pkh).Script()
+}
+
+// SignInputs signs every input of tx with priv. prevOuts holds the output each
+// input spends, in input order; all of them must pay to priv's address of addrType.
+func SignInputs(tx *wire.MsgTx, prevOuts []*wire.TxOut, privateKeyHex string, addrType AddressType) error {
+    keys := make([]string, len(tx.TxIn))
+    for i := range keys {
+        keys[i] = privateKeyHex
+    }
+    return SignInputsEach(tx, prevOuts, keys, addrType)
+}
+
+// SignInputsEach is SignInputs with a key per input, for inputs spending
+// from several addresses of addrType: input i is signed with keys[i].
+func SignInputsEach(tx *wire.MsgTx, prevOuts []*wire.TxOut, keys []string, addrType AddressType) error {
+    if len(prevOuts) != len(tx.TxIn) {
+        return fmt.Errorf("have %d previous outputs for %d inputs", len(prevOuts), len(tx.TxIn))
+    }
+    if len(keys) != len(tx.TxIn) {
+        return fmt.Errorf("have %d keys for %d inputs", len(keys), len(tx.TxIn))
+    }
+
+    fetcher := txscript.NewMultiPrevOutFetcher(nil)
+    for i, in := range tx.TxIn {
+        fetcher.AddPrevOut(in.PreviousOutPoint, prevOuts[i])
+    }
+    sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+
+    for i, prev := range prevOuts {
+        privBytes, err := hex.DecodeString(keys[i])
+        if err != nil {
+            return fmt.Errorf("invalid private key")
+        }
+        priv, pub := btcec.PrivKeyFromBytes(privBytes)
+        pkh := btcutil.Hash160(pub.SerializeCompressed())
+        switch addrType {
+        case AddrP2WPKH:
+            wit, err := txscript.WitnessSignature(tx, sigHashes, i, prev.Value, prev.PkScript, txscript.SigHashAll, priv, true)
+            if err != nil {
+                return err
+            }
+            tx.TxIn[i].Witness = wit
+        case AddrP2SHP2WPKH:
+            redeem, err := witnessRedeemScript(pkh)
+            if err != nil {
+                return err
+            }
+            wit, err := txscript.WitnessSignature(tx, sigHashes, i, prev.Value, redeem, txscript.SigHashAll, priv, true)
+            if err != nil {
+                return err
+            }
+            sigScript, err := txscript.NewScriptBuilder().AddData(redeem).Script()
+            if err != nil {
+                return err
+            }
+            tx.TxIn[i].Witness = wit
+            tx.TxIn[i].SignatureScript = sigScript
+        default:
+            sigScript, err := txscript.SignatureScript(tx, i, prev.PkScript, txscript.SigHashAll, priv, true)
+            if err != nil {
+                return err
+            }
+            tx.TxIn[i].SignatureScript = sigScript
+        }
+    }
+    return verifyInputs(tx, prevOuts, fetcher)
+}
+
+// verifyInputs runs every signed input through the script engine so a bad
+// signature is caught before it reaches the network.
+func verifyInputs(tx *wire.MsgTx, prevOuts []*wire.TxOut, fetcher txscript.PrevOutputFetcher) error {
+    sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+    for i, prev := range prevOuts {
+        vm, err := txscript.NewEngine(prev.PkScript, tx, i, txscript.StandardVerifyFlags, nil, sigHashes, prev.Value, fetcher)
+        if err != nil {
+            return err
+        }
+        if err := vm.Execute(); err != nil {
+            return fmt.Errorf("input %d failed verification: %w", i, err)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,72 @@
+package db
+
+import "fmt"
+
+// utxo_meta holds what the user decided about individual coins, keyed by
+// outpoint ("txid:vout"). Rows outlive the coin being spent so a label stays
+// attached if the output ever shows up again after a reorg.
+const coinSchema = `
+    CREATE TABLE IF NOT EXISTS utxo_meta (
+        outpoint TEXT PRIMARY KEY,
+        address TEXT NOT NULL,
+        frozen INTEGER NOT NULL DEFAULT 0,
+        label TEXT NOT NULL DEFAULT ''
+    );
+`
+
+// CoinMeta is the coin control state of one UTXO.
+type CoinMeta struct {
+    Frozen bool
+    Label  string
+}
+
+func LoadCoinMeta(address string) (map[string]CoinMeta, error) {
+    fmt.Printf("%s[INFO]%s Loading coin control for %s\n", cCyan, cReset, address)
+    db, err := InitDB()
+    if err != nil {
+        fmt.Print(nice(err))
+        return nil, err
+    }
+    defer db.Close()
+    rows, err := db.Query(`SELECT outpoint, frozen, label FROM utxo_meta WHERE address=?`, address)
+    if err != nil {
+        fmt.Print(nice(err))
+        return nil, err
+    }
+    defer rows.Close()
+    meta := map[string]CoinMeta{}
+    for rows.Next() {
+        var op string
+        var m CoinMeta
+        if err := rows.Scan(&op, &m.Frozen, &m.Label); err != nil {
+            fmt.Print(nice(err))
+            return nil, err
+        }
+        meta[op] = m
+    }
+    return meta, rows.Err()
+}
+
+func SetCoinFrozen(address, outpoint string, frozen bool) error {
+    fmt.Printf("%s[INFO]%s Setting %s frozen=%v... ", cCyan, cReset, outpoint, frozen)
+    return upsertCoinMeta(address, outpoint, `frozen`, frozen)
+}
+
+func SetCoinLabel(address, outpoint, label string) error {
+    fmt.Printf("%s[INFO]%s Labelling %s... ", cCyan, cReset, outpoint)
+    return upsertCoinMeta(address, outpoint, `label`, label)
+}
+
+func upsertCoinMeta(address, outpoint, column string, value any) error {
+    db, err := InitDB()
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    defer db.Close()
+    _, err = db.Exec(fmt.Sprintf(`
+        INSERT INTO utxo_meta(outpoint, address, %[1]s) VALUES(?, ?, ?)
+        ON CONFLICT(outpoint) DO UPDATE SET %[1]s=excluded.%[1]s`, column), outpoint, address, value)
+    fmt.Print(nice(err))
+    return err
+}
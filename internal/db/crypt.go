@@ -0,0 +1,350 @@
+package db
+
+import (
+    "crypto/rand"
+    "database/sql"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+
+    "golang.org/x/crypto/argon2"
+    "golang.org/x/crypto/chacha20poly1305"
+)
+
+// Private keys are sealed with XChaCha20-Poly1305 under a key derived from the
+// user's passphrase with Argon2id. The KDF salt/parameters and a sealed check
+// value live in the meta table so a wrong passphrase is detected up front.
+const (
+    sealPrefix  = "enc1:"
+    checkValue  = "crypto-transit"
+    kdfName     = "argon2id"
+    kdfTime     = 1
+    kdfMemory   = 64 * 1024
+    kdfThreads  = 4
+    kdfKeyLen   = chacha20poly1305.KeySize
+    kdfSaltLen  = 16
+    maxAttempts = 3
+)
+
+var (
+    ErrWrongPassphrase = errors.New("wrong passphrase")
+    ErrLocked          = errors.New("wallet database is locked")
+    ErrNoPrompt        = errors.New("no passphrase prompt configured")
+    ErrNoPassphrase    = errors.New("no passphrase set yet")
+    // ErrNoCheck means the KDF parameters are stored but the value that
+    // verifies a passphrase is not, so no passphrase can be confirmed.
+    ErrNoCheck = errors.New("passphrase check value missing from database")
+)
+
+// PassphraseFunc asks the user for a passphrase. When isNew is true the caller
+// is choosing a passphrase and should confirm it before returning.
+type PassphraseFunc func(isNew bool) (string, error)
+
+var (
+    promptPassphrase PassphraseFunc
+    unlockedKey      []byte
+)
+
+// SetPassphrasePrompt installs the function used to ask for the database
+// passphrase the first time InitDB needs it.
+func SetPassphrasePrompt(fn PassphraseFunc) { promptPassphrase = fn }
+
+// Lock forgets the cached key; the next InitDB prompts again.
+func Lock() {
+    for i := range unlockedKey {
+        unlockedKey[i] = 0
+    }
+    unlockedKey = nil
+}
+
+type kdfParams struct {
+    time    uint32
+    memory  uint32
+    threads uint8
+    salt    []byte
+}
+
+func (p kdfParams) String() string {
+    return fmt.Sprintf("%s:%d:%d:%d:%s", kdfName, p.time, p.memory, p.threads, hex.EncodeToString(p.salt))
+}
+
+func (p kdfParams) derive(passphrase string) []byte {
+    return argon2.IDKey([]byte(passphrase), p.salt, p.time, p.memory, p.threads, kdfKeyLen)
+}
+
+func newKDFParams() (kdfParams, error) {
+    salt := make([]byte, kdfSaltLen)
+    if _, err := rand.Read(salt); err != nil {
+        return kdfParams{}, err
+    }
+    return kdfParams{time: kdfTime, memory: kdfMemory, threads: kdfThreads, salt: salt}, nil
+}
+
+func parseKDFParams(s string) (kdfParams, error) {
+    parts := strings.Split(s, ":")
+    if len(parts) != 5 || parts[0] != kdfName {
+        return kdfParams{}, fmt.Errorf("unsupported kdf %q", s)
+    }
+    t, err1 := strconv.ParseUint(parts[1], 10, 32)
+    m, err2 := strconv.ParseUint(parts[2], 10, 32)
+    p, err3 := strconv.ParseUint(parts[3], 10, 8)
+    salt, err4 := hex.DecodeString(parts[4])
+    if err := errors.Join(err1, err2, err3, err4); err != nil {
+        return kdfParams{}, fmt.Errorf("corrupt kdf parameters: %w", err)
+    }
+    return kdfParams{time: uint32(t), memory: uint32(m), threads: uint8(p), salt: salt}, nil
+}
+
+func seal(key []byte, plaintext, ad string) (string, error) {
+    aead, err := chacha20poly1305.NewX(key)
+    if err != nil {
+        return "", err
+    }
+    nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+    if _, err := rand.Read(nonce); err != nil {
+        return "", err
+    }
+    out := aead.Seal(nonce, nonce, []byte(plaintext), []byte(ad))
+    return sealPrefix + hex.EncodeToString(out), nil
+}
+
+func unseal(key []byte, sealed, ad string) (string, error) {
+    raw, err := hex.DecodeString(strings.TrimPrefix(sealed, sealPrefix))
+    if err != nil {
+        return "", fmt.Errorf("corrupt sealed value: %w", err)
+    }
+    aead, err := chacha20poly1305.NewX(key)
+    if err != nil {
+        return "", err
+    }
+    if len(raw) < aead.NonceSize() {
+        return "", fmt.Errorf("corrupt sealed value")
+    }
+    plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], []byte(ad))
+    if err != nil {
+        return "", ErrWrongPassphrase
+    }
+    return string(plain), nil
+}
+
+func isSealed(v string) bool { return strings.HasPrefix(v, sealPrefix) }
+
+func getMeta(db *sql.DB, key string) (string, bool, error) {
+    var v string
+    err := db.QueryRow(`SELECT value FROM meta WHERE key=?`, key).Scan(&v)
+    if err == sql.ErrNoRows {
+        return "", false, nil
+    }
+    return v, err == nil, err
+}
+
+// unlock makes sure unlockedKey holds the database key, prompting for the
+// passphrase (or a new one on first use). Plaintext rows left by older
+// versions are sealed once, right after the key is first obtained.
+func unlock(db *sql.DB) error {
+    if unlockedKey != nil {
+        return nil
+    }
+    if promptPassphrase == nil {
+        return ErrNoPrompt
+    }
+    kdf, found, err := getMeta(db, "kdf")
+    if err != nil {
+        return err
+    }
+    if !found {
+        pass, err := promptPassphrase(true)
+        if err != nil {
+            return err
+        }
+        key, err := setPassphrase(db, pass)
+        if err != nil {
+            return err
+        }
+        unlockedKey = key
+        fmt.Printf("%s[SUCCESS]%s Wallet database passphrase set.\n", cGreen, cReset)
+        return migratePlaintext(db)
+    }
+    params, err := parseKDFParams(kdf)
+    if err != nil {
+        return err
+    }
+    check, found, err := getMeta(db, "check")
+    if err != nil {
+        return err
+    }
+    if !found {
+        return ErrNoCheck
+    }
+    for attempt := 1; attempt <= maxAttempts; attempt++ {
+        pass, err := promptPassphrase(false)
+        if err != nil {
+            return err
+        }
+        key := params.derive(pass)
+        if v, err := unseal(key, check, "check"); err == nil && v == checkValue {
+            unlockedKey = key
+            fmt.Printf("%s[SUCCESS]%s Wallet database unlocked.\n", cGreen, cReset)
+            return migratePlaintext(db)
+        }
+        fmt.Printf("%s[WARN]%s Wrong passphrase (%d/%d).\n", cYellow, cReset, attempt, maxAttempts)
+    }
+    return ErrWrongPassphrase
+}
+
+// setPassphrase stores fresh KDF parameters and check value for pass and
+// returns the derived key. Callers re-seal existing rows themselves.
+func setPassphrase(db execer, pass string) ([]byte, error) {
+    params, err := newKDFParams()
+    if err != nil {
+        return nil, err
+    }
+    key := params.derive(pass)
+    check, err := seal(key, checkValue, "check")
+    if err != nil {
+        return nil, err
+    }
+    if _, err := db.Exec(`INSERT OR REPLACE INTO meta(key, value) VALUES('kdf', ?), ('check', ?)`, params.String(), check); err != nil {
+        return nil, err
+    }
+    return key, nil
+}
+
+type execer interface {
+    Exec(query string, args ...any) (sql.Result, error)
+}
+
+// migratePlaintext seals any private column still stored as plain hex by
+// versions that predate encryption. It is a no-op once every row is sealed.
+func migratePlaintext(db *sql.DB) error {
+    rows, err := db.Query(`SELECT alias, private, address FROM wallet WHERE private NOT LIKE ?`, sealPrefix+"%")
+    if err != nil {
+        return err
+    }
+    type plainRow struct{ alias, priv, addr string }
+    var pending []plainRow
+    for rows.Next() {
+        var r plainRow
+        if err := rows.Scan(&r.alias, &r.priv, &r.addr); err != nil {
+            rows.Close()
+            return err
+        }
+        pending = append(pending, r)
+    }
+    rows.Close()
+    if len(pending) == 0 {
+        return nil
+    }
+    fmt.Printf("%s[INFO]%s Encrypting %d plaintext wallet(s)... ", cCyan, cReset, len(pending))
+    tx, err := db.Begin()
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    for _, r := range pending {
+        sealed, err := seal(unlockedKey, r.priv, r.addr)
+        if err == nil {
+            _, err = tx.Exec(`UPDATE wallet SET private=? WHERE alias=?`, sealed, r.alias)
+        }
+        if err != nil {
+            tx.Rollback()
+            fmt.Print(nice(err))
+            return err
+        }
+    }
+    err = tx.Commit()
+    fmt.Print(nice(err))
+    return err
+}
+
+// ChangePassphrase re-encrypts every stored private key under newPass after
+// verifying oldPass. The swap happens in one transaction. oldPass is all
+// the unlocking needed, so the user is never prompted here.
+func ChangePassphrase(oldPass, newPass string) error {
+    fmt.Printf("%s[INFO]%s Changing wallet database passphrase... ", cCyan, cReset)
+    db, err := openDB()
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    defer db.Close()
+    kdf, found, err := getMeta(db, "kdf")
+    if err == nil && !found {
+        err = ErrNoPassphrase
+    }
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    params, err := parseKDFParams(kdf)
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    oldKey := params.derive(oldPass)
+    check, found, err := getMeta(db, "check")
+    if err == nil && !found {
+        err = ErrNoCheck
+    }
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    if v, err := unseal(oldKey, check, "check"); err != nil || v != checkValue {
+        fmt.Print(nice(ErrWrongPassphrase))
+        return ErrWrongPassphrase
+    }
+    rows, err := db.Query(`SELECT alias, private, address FROM wallet`)
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    plain := map[string][2]string{}
+    for rows.Next() {
+        var alias, priv, addr string
+        if err := rows.Scan(&alias, &priv, &addr); err != nil {
+            rows.Close()
+            fmt.Print(nice(err))
+            return err
+        }
+        if !isSealed(priv) {
+            // not migrated yet; sealed below with the rest
+        } else if p, err := unseal(oldKey, priv, addr); err == nil {
+            priv = p
+        } else {
+            rows.Close()
+            fmt.Print(nice(err))
+            return err
+        }
+        plain[alias] = [2]string{priv, addr}
+    }
+    rows.Close()
+    tx, err := db.Begin()
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    newKey, err := setPassphrase(tx, newPass)
+    for alias, v := range plain {
+        if err != nil {
+            break
+        }
+        var sealed string
+        if sealed, err = seal(newKey, v[0], v[1]); err == nil {
+            _, err = tx.Exec(`UPDATE wallet SET private=? WHERE alias=?`, sealed, alias)
+        }
+    }
+    if err != nil {
+        tx.Rollback()
+        fmt.Print(nice(err))
+        return err
+    }
+    if err = tx.Commit(); err == nil {
+        Lock()
+        unlockedKey = newKey
+    }
+    fmt.Print(nice(err))
+    return err
+}
@@ -0,0 +1,212 @@
+package db
+
+import (
+    "crypto/rand"
+    "database/sql"
+    "errors"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// useTempDB points the package at a fresh database in a temporary
+// directory, locked, with prompt answering passphrase requests.
+func useTempDB(t *testing.T, prompt PassphraseFunc) string {
+    t.Helper()
+    wd, err := os.Getwd()
+    if err != nil {
+        t.Fatal(err)
+    }
+    dir := t.TempDir()
+    if err := os.Chdir(dir); err != nil {
+        t.Fatal(err)
+    }
+    oldFile, oldPrompt := walletDBFile, promptPassphrase
+    t.Cleanup(func() {
+        os.Chdir(wd)
+        walletDBFile, promptPassphrase = oldFile, oldPrompt
+        Lock()
+    })
+    walletDBFile = "test.db"
+    Lock()
+    SetPassphrasePrompt(prompt)
+    return filepath.Join(dir, walletDBFile)
+}
+
+// answer returns a prompt that always gives pass and counts its calls.
+func answer(pass string, calls *int) PassphraseFunc {
+    return func(bool) (string, error) {
+        *calls++
+        return pass, nil
+    }
+}
+
+func rawPrivate(t *testing.T, path, alias string) string {
+    t.Helper()
+    db, err := sql.Open("sqlite", path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer db.Close()
+    var priv string
+    if err := db.QueryRow(`SELECT private FROM wallet WHERE alias=?`, alias).Scan(&priv); err != nil {
+        t.Fatal(err)
+    }
+    return priv
+}
+
+const testPriv = "0000000000000000000000000000000000000000000000000000000000000001"
+
+func TestSealUnseal(t *testing.T) {
+    key := make([]byte, kdfKeyLen)
+    rand.Read(key)
+    a, err := seal(key, testPriv, "addr")
+    if err != nil {
+        t.Fatal(err)
+    }
+    b, _ := seal(key, testPriv, "addr")
+    if !isSealed(a) || a == b {
+        t.Errorf("sealed values %q, %q: want the prefix and a fresh nonce each time", a, b)
+    }
+    if got, err := unseal(key, a, "addr"); err != nil || got != testPriv {
+        t.Fatalf("unseal = %q, %v", got, err)
+    }
+    other := make([]byte, kdfKeyLen)
+    if _, err := unseal(other, a, "addr"); !errors.Is(err, ErrWrongPassphrase) {
+        t.Errorf("wrong key: err = %v, want ErrWrongPassphrase", err)
+    }
+    // The address is bound in, so a key copied to another row fails.
+    if _, err := unseal(key, a, "other"); !errors.Is(err, ErrWrongPassphrase) {
+        t.Errorf("wrong address: err = %v, want ErrWrongPassphrase", err)
+    }
+    if _, err := unseal(key, sealPrefix+"zz", "addr"); err == nil {
+        t.Error("corrupt value: want an error")
+    }
+}
+
+func TestWrongPassphrase(t *testing.T) {
+    var calls int
+    useTempDB(t, answer("right", &calls))
+    if err := SaveWallet(WalletRecord{Alias: "a", Private: testPriv, Address: "addr"}); err != nil {
+        t.Fatal(err)
+    }
+
+    Lock()
+    calls = 0
+    SetPassphrasePrompt(answer("wrong", &calls))
+    if _, _, err := LoadWallet("a"); !errors.Is(err, ErrWrongPassphrase) {
+        t.Fatalf("err = %v, want ErrWrongPassphrase", err)
+    }
+    if calls != maxAttempts {
+        t.Errorf("prompted %d times, want %d", calls, maxAttempts)
+    }
+
+    SetPassphrasePrompt(answer("right", &calls))
+    rec, found, err := LoadWallet("a")
+    if err != nil || !found || rec.Private != testPriv {
+        t.Fatalf("LoadWallet = %+v, %v, %v", rec, found, err)
+    }
+}
+
+func TestMigratePlaintext(t *testing.T) {
+    var calls int
+    path := useTempDB(t, answer("pw", &calls))
+
+    // A database written before encryption existed.
+    old, err := sql.Open("sqlite", path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    _, err = old.Exec(`
+        CREATE TABLE wallet (alias TEXT PRIMARY KEY, private TEXT NOT NULL, public TEXT NOT NULL, address TEXT NOT NULL);
+        INSERT INTO wallet VALUES ('old', '` + testPriv + `', 'pub', 'addr');`)
+    old.Close()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    rec, found, err := LoadWallet("old")
+    if err != nil || !found || rec.Private != testPriv || rec.Kind != KindKey {
+        t.Fatalf("LoadWallet = %+v, %v, %v", rec, found, err)
+    }
+    if priv := rawPrivate(t, path, "old"); !isSealed(priv) {
+        t.Fatalf("private key still stored as %q", priv)
+    }
+
+    // Migration runs when the database is unlocked, not on every open.
+    db, _ := sql.Open("sqlite", path)
+    db.Exec(`INSERT INTO wallet(alias, private, public, address) VALUES ('late', ?, 'pub', 'addr2')`, testPriv)
+    db.Close()
+    if _, err := ListWalletAliases(); err != nil {
+        t.Fatal(err)
+    }
+    if priv := rawPrivate(t, path, "late"); isSealed(priv) {
+        t.Error("an unlocked database was migrated again")
+    }
+    Lock()
+    if _, err := ListWalletAliases(); err != nil {
+        t.Fatal(err)
+    }
+    if priv := rawPrivate(t, path, "late"); !isSealed(priv) {
+        t.Error("plaintext row not sealed on the next unlock")
+    }
+    if calls != 2 {
+        t.Errorf("prompted %d times, want once per unlock", calls)
+    }
+}
+
+func TestChangePassphrase(t *testing.T) {
+    var calls int
+    path := useTempDB(t, answer("old", &calls))
+    if err := SaveWallet(WalletRecord{Alias: "a", Private: testPriv, Address: "addr"}); err != nil {
+        t.Fatal(err)
+    }
+    before := rawPrivate(t, path, "a")
+
+    // Changing needs no prompt, even from a locked database.
+    Lock()
+    calls = 0
+    if err := ChangePassphrase("nope", "new"); !errors.Is(err, ErrWrongPassphrase) {
+        t.Fatalf("err = %v, want ErrWrongPassphrase", err)
+    }
+    if err := ChangePassphrase("old", "new"); err != nil {
+        t.Fatal(err)
+    }
+    if calls != 0 {
+        t.Errorf("prompted %d times, want none", calls)
+    }
+    if after := rawPrivate(t, path, "a"); after == before || !isSealed(after) {
+        t.Error("private key was not re-sealed")
+    }
+
+    Lock()
+    SetPassphrasePrompt(answer("old", &calls))
+    if _, _, err := LoadWallet("a"); !errors.Is(err, ErrWrongPassphrase) {
+        t.Errorf("old passphrase: err = %v, want ErrWrongPassphrase", err)
+    }
+    SetPassphrasePrompt(answer("new", &calls))
+    if rec, _, err := LoadWallet("a"); err != nil || rec.Private != testPriv {
+        t.Errorf("new passphrase: %+v, %v", rec, err)
+    }
+}
+
+func TestChangePassphraseNeedsOneSet(t *testing.T) {
+    var calls int
+    path := useTempDB(t, answer("old", &calls))
+    if err := ChangePassphrase("old", "new"); !errors.Is(err, ErrNoPassphrase) {
+        t.Fatalf("never unlocked: err = %v, want ErrNoPassphrase", err)
+    }
+    if err := SaveWallet(WalletRecord{Alias: "a", Private: testPriv, Address: "addr"}); err != nil {
+        t.Fatal(err)
+    }
+    db, _ := sql.Open("sqlite", path)
+    db.Exec(`DELETE FROM meta WHERE key='check'`)
+    db.Close()
+    if err := ChangePassphrase("old", "new"); !errors.Is(err, ErrNoCheck) {
+        t.Errorf("check row gone: err = %v, want ErrNoCheck", err)
+    }
+    Lock()
+    if _, _, err := LoadWallet("a"); !errors.Is(err, ErrNoCheck) {
+        t.Errorf("unlock without check row: err = %v, want ErrNoCheck", err)
+    }
+}
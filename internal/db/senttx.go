@@ -0,0 +1,181 @@
+package db
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "litecoin-wallet/internal/models"
+)
+
+// sent_tx keeps every transaction this wallet built and broadcast, with
+// what is needed to build a replacement: the payments, the coins spent and
+// the fee paid. Payments and inputs are JSON. When a transaction is
+// replaced, replaced_by points at its successor and the successor's
+// replaces points back. A child-pays-for-parent child names the incoming
+// transaction it spends in parent.
+const sentTxSchema = `
+    CREATE TABLE IF NOT EXISTS sent_tx (
+        txid TEXT PRIMARY KEY,
+        address TEXT NOT NULL,
+        payments TEXT NOT NULL,
+        send_all INTEGER NOT NULL DEFAULT 0,
+        inputs TEXT NOT NULL,
+        fee INTEGER NOT NULL,
+        fee_per_kb INTEGER NOT NULL,
+        vsize INTEGER NOT NULL,
+        raw TEXT NOT NULL,
+        created TEXT NOT NULL,
+        replaces TEXT NOT NULL DEFAULT '',
+        replaced_by TEXT NOT NULL DEFAULT '',
+        parent TEXT NOT NULL DEFAULT ''
+    );
+`
+
+// SentPayment is one output a sent transaction pays, change excluded.
+type SentPayment struct {
+    Address string        `json:"address"`
+    Value   models.Amount `json:"value"`
+    Label   string        `json:"label,omitempty"`
+}
+
+// SentTx is a transaction the wallet broadcast.
+type SentTx struct {
+    Txid     string
+    Address  string
+    Payments []SentPayment
+    // SendAll marks a sweep, whose single payment takes what the fee
+    // leaves.
+    SendAll  bool
+    Inputs   []models.UTXO
+    Fee      models.Amount
+    FeePerKB int64
+    VSize    int64
+    Raw      string
+    Created  time.Time
+    // Replaces is the txid this one replaced by fee bump, if any.
+    Replaces   string
+    ReplacedBy string
+    // Parent is the incoming transaction this child-pays-for-parent child
+    // spends. Such a child pays nobody, so it is never offered for a fee
+    // bump.
+    Parent string
+}
+
+// SaveSentTx records tx. If it replaces an earlier transaction, that one
+// is marked as replaced in the same database transaction.
+func SaveSentTx(tx SentTx) error {
+    fmt.Printf("%s[INFO]%s Recording sent transaction %s... ", cCyan, cReset, tx.Txid)
+    payments, err := json.Marshal(tx.Payments)
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    inputs, err := json.Marshal(tx.Inputs)
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    db, err := InitDB()
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    defer db.Close()
+    dtx, err := db.Begin()
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    _, err = dtx.Exec(`
+        INSERT OR REPLACE INTO sent_tx(txid, address, payments, send_all, inputs, fee, fee_per_kb, vsize, raw, created, replaces, parent)
+        VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+        tx.Txid, tx.Address, string(payments), tx.SendAll, string(inputs), tx.Fee, tx.FeePerKB, tx.VSize, tx.Raw,
+        tx.Created.UTC().Format(time.RFC3339), tx.Replaces, tx.Parent)
+    if err == nil && tx.Replaces != "" {
+        _, err = dtx.Exec(`UPDATE sent_tx SET replaced_by=? WHERE txid=?`, tx.Txid, tx.Replaces)
+    }
+    if err != nil {
+        dtx.Rollback()
+        fmt.Print(nice(err))
+        return err
+    }
+    err = dtx.Commit()
+    fmt.Print(nice(err))
+    return err
+}
+
+// LoadSentTxs returns the transactions sent from address that have not
+// been replaced, newest first.
+func LoadSentTxs(address string) ([]SentTx, error) {
+    fmt.Printf("%s[INFO]%s Loading sent transactions: %s\n", cCyan, cReset, address)
+    db, err := InitDB()
+    if err != nil {
+        fmt.Print(nice(err))
+        return nil, err
+    }
+    defer db.Close()
+    rows, err := db.Query(`
+        SELECT txid, address, payments, send_all, inputs, fee, fee_per_kb, vsize, raw, created, replaces, replaced_by, parent
+        FROM sent_tx WHERE address=? AND replaced_by='' ORDER BY created DESC`, address)
+    if err != nil {
+        fmt.Print(nice(err))
+        return nil, err
+    }
+    defer rows.Close()
+    var txs []SentTx
+    for rows.Next() {
+        tx, err := scanSentTx(rows)
+        if err != nil {
+            fmt.Print(nice(err))
+            return nil, err
+        }
+        txs = append(txs, tx)
+    }
+    return txs, rows.Err()
+}
+
+func scanSentTx(rows *sql.Rows) (SentTx, error) {
+    var tx SentTx
+    var payments, inputs, created string
+    err := rows.Scan(&tx.Txid, &tx.Address, &payments, &tx.SendAll, &inputs, &tx.Fee, &tx.FeePerKB, &tx.VSize, &tx.Raw, &created, &tx.Replaces, &tx.ReplacedBy, &tx.Parent)
+    if err != nil {
+        return tx, err
+    }
+    if err := json.Unmarshal([]byte(payments), &tx.Payments); err != nil {
+        return tx, err
+    }
+    if err := json.Unmarshal([]byte(inputs), &tx.Inputs); err != nil {
+        return tx, err
+    }
+    tx.Created, _ = time.Parse(time.RFC3339, created)
+    return tx, nil
+}
+
+// Descendants returns the transactions in sent that spend an output of
+// txid, directly or through one another, in the order of sent.
+func Descendants(sent []SentTx, txid string) []SentTx {
+    family := map[string]bool{txid: true}
+    var out []SentTx
+    for grew := true; grew; {
+        grew = false
+        for _, tx := range sent {
+            if family[tx.Txid] {
+                continue
+            }
+            for _, in := range tx.Inputs {
+                if family[in.TxHash] {
+                    family[tx.Txid], grew = true, true
+                    break
+                }
+            }
+        }
+    }
+    for _, tx := range sent {
+        if tx.Txid != txid && family[tx.Txid] {
+            out = append(out, tx)
+        }
+    }
+    return out
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+    "reflect"
+    "testing"
+    "time"
+
+    "litecoin-wallet/internal/models"
+)
+
+func sentTx(txid string, created int, spends ...string) SentTx {
+    tx := SentTx{
+        Txid: txid, Address: "addr", Fee: 1000, FeePerKB: 10_000, VSize: 100, Raw: "00",
+        Payments: []SentPayment{{Address: "to", Value: 5000}},
+        Created:  time.Unix(int64(created), 0),
+    }
+    for _, p := range spends {
+        tx.Inputs = append(tx.Inputs, models.UTXO{TxHash: p, Value: 10_000})
+    }
+    return tx
+}
+
+func txids(txs []SentTx) []string {
+    var ids []string
+    for _, tx := range txs {
+        ids = append(ids, tx.Txid)
+    }
+    return ids
+}
+
+func TestSentTxReplace(t *testing.T) {
+    var calls int
+    useTempDB(t, answer("pw", &calls))
+    if err := SaveSentTx(sentTx("a", 1, "funding")); err != nil {
+        t.Fatal(err)
+    }
+    b := sentTx("b", 2, "funding")
+    b.Replaces = "a"
+    if err := SaveSentTx(b); err != nil {
+        t.Fatal(err)
+    }
+    sent, err := LoadSentTxs("addr")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(sent) != 1 || sent[0].Txid != "b" || sent[0].Replaces != "a" || sent[0].Inputs[0].TxHash != "funding" {
+        t.Errorf("loaded %+v, want only the replacement", sent)
+    }
+
+    child := sentTx("c", 3, "incoming")
+    child.Parent = "incoming"
+    if err := SaveSentTx(child); err != nil {
+        t.Fatal(err)
+    }
+    if sent, err := LoadSentTxs("addr"); err != nil || len(sent) != 2 || sent[0].Parent != "incoming" {
+        t.Errorf("loaded %+v, %v; want the child with its parent", sent, err)
+    }
+}
+
+func TestDescendants(t *testing.T) {
+    // c spends b's change, which spends a's; d is unrelated.
+    sent := []SentTx{sentTx("d", 4, "x"), sentTx("c", 3, "b"), sentTx("b", 2, "a"), sentTx("a", 1, "funding")}
+    if got := txids(Descendants(sent, "a")); !reflect.DeepEqual(got, []string{"c", "b"}) {
+        t.Errorf("descendants of a = %v, want [c b]", got)
+    }
+    if got := Descendants(sent, "d"); len(got) != 0 {
+        t.Errorf("descendants of d = %v, want none", txids(got))
+    }
+}
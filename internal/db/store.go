@@ -4,13 +4,25 @@ import (
     "database/sql"
     "fmt"
     "os"
-	"strings"
+    "strings"
     "path/filepath"
     _ "modernc.org/sqlite"
 )
 
+var walletDBFile = "litecoin_wallet.db"
+
+// SetNetwork selects the database file for a network so test keys never end
+// up next to mainnet ones. Mainnet keeps the original file name.
+func SetNetwork(name string) {
+    if name == "" || name == "mainnet" {
+        walletDBFile = "litecoin_wallet.db"
+    } else {
+        walletDBFile = "litecoin_wallet_" + name + ".db"
+    }
+    Lock()
+}
+
 const (
-    walletDBFile    = "litecoin_wallet.db"
     TempWalletAlias = "TEMP"
 
     cReset  = "\033[0m"
@@ -20,6 +32,45 @@ const (
     cRed    = "\033[31m"
 )
 
+// WalletRecord is one row of the wallet table. For Kind "key" Private holds
+// the hex private key; for Kind "hd" it holds the hex BIP39 seed and Path is
+// the derivation path of the wallet's first receive address, Address.
+// AddrType is one of the crypto.AddressType names. HDAddresses are the
+// further addresses an HD wallet has handed out; they are stored as they
+// are derived, not by SaveWallet.
+type WalletRecord struct {
+    Alias       string
+    Kind        string
+    Private     string
+    Public      string
+    Address     string
+    Path        string
+    AddrType    string
+    HDAddresses []HDAddress
+}
+
+// HDAddress is an address of an HD wallet at change/index below the
+// wallet's account.
+type HDAddress struct {
+    Change  uint32
+    Index   uint32
+    Address string
+}
+
+// Addresses returns every address of the wallet, Address first.
+func (rec WalletRecord) Addresses() []string {
+    addrs := []string{rec.Address}
+    for _, a := range rec.HDAddresses {
+        addrs = append(addrs, a.Address)
+    }
+    return addrs
+}
+
+const (
+    KindKey = "key"
+    KindHD  = "hd"
+)
+
 func getWalletDBPath() string {
     dir, err := os.Getwd()
     if err != nil {
@@ -37,7 +88,25 @@ func nice(err error) string {
     return fmt.Sprintf("%s[ERROR]%s %v\n", cRed, cReset, err)
 }
 
+// InitDB opens the database, creating or upgrading the schema, and unlocks
+// it, prompting for the passphrase the first time.
 func InitDB() (*sql.DB, error) {
+    db, err := openDB()
+    if err != nil {
+        return nil, err
+    }
+    if err := unlock(db); err != nil {
+        db.Close()
+        fmt.Print(nice(err))
+        return nil, err
+    }
+    fmt.Printf("%s[SUCCESS]%s Opened or created wallet database.\n", cGreen, cReset)
+    return db, nil
+}
+
+// openDB opens the database and brings the schema up to date without
+// unlocking it.
+func openDB() (*sql.DB, error) {
     fmt.Printf("%s[INFO]%s Opening wallet database...\n", cCyan, cReset)
     db, err := sql.Open("sqlite", getWalletDBPath())
     if err != nil {
@@ -51,49 +120,125 @@ func InitDB() (*sql.DB, error) {
             public TEXT NOT NULL,
             address TEXT NOT NULL
         );
-    `)
+        CREATE TABLE IF NOT EXISTS meta (
+            key TEXT PRIMARY KEY,
+            value TEXT NOT NULL
+        );
+        CREATE TABLE IF NOT EXISTS wallet_address (
+            wallet TEXT NOT NULL,
+            change INTEGER NOT NULL,
+            idx INTEGER NOT NULL,
+            address TEXT NOT NULL,
+            PRIMARY KEY (wallet, change, idx)
+        );
+    ` + txCacheSchema + coinSchema + sentTxSchema)
     if err != nil {
+        db.Close()
+        fmt.Print(nice(err))
+        return nil, err
+    }
+    if err := ensureColumns(db, "wallet", map[string]string{
+        "kind": "TEXT NOT NULL DEFAULT 'key'",
+        "path": "TEXT NOT NULL DEFAULT ''",
+        "addr_type": "TEXT NOT NULL DEFAULT 'p2pkh'",
+    }); err != nil {
+        db.Close()
+        fmt.Print(nice(err))
+        return nil, err
+    }
+    if err := ensureColumns(db, "sent_tx", map[string]string{
+        "parent": "TEXT NOT NULL DEFAULT ''",
+    }); err != nil {
+        db.Close()
         fmt.Print(nice(err))
         return nil, err
     }
-    fmt.Printf("%s[SUCCESS]%s Opened or created wallet database.\n", cGreen, cReset)
     return db, nil
 }
 
-func SaveWallet(alias, priv, pub, addr string) error {
-    fmt.Printf("%s[INFO]%s Saving wallet: alias=%s... ", cCyan, cReset, alias)
+// ensureColumns adds columns introduced after the table was first created.
+func ensureColumns(db *sql.DB, table string, cols map[string]string) error {
+    rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+    if err != nil {
+        return err
+    }
+    have := map[string]bool{}
+    for rows.Next() {
+        var (
+            cid, notNull, pk int
+            name, typ        string
+            dflt             sql.NullString
+        )
+        if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+            rows.Close()
+            return err
+        }
+        have[name] = true
+    }
+    rows.Close()
+    for name, decl := range cols {
+        if have[name] {
+            continue
+        }
+        if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, name, decl)); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func SaveWallet(rec WalletRecord) error {
+    fmt.Printf("%s[INFO]%s Saving wallet: alias=%s... ", cCyan, cReset, rec.Alias)
+    if rec.Kind == "" {
+        rec.Kind = KindKey
+    }
+    if rec.AddrType == "" {
+        rec.AddrType = "p2pkh"
+    }
     db, err := InitDB()
     if err != nil {
         fmt.Print(nice(err))
         return err
     }
     defer db.Close()
+    sealed, err := seal(unlockedKey, rec.Private, rec.Address)
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
     _, err = db.Exec(`
-        INSERT OR REPLACE INTO wallet(alias, private, public, address) VALUES(?, ?, ?, ?)`, alias, priv, pub, addr)
+        INSERT OR REPLACE INTO wallet(alias, kind, private, public, address, path, addr_type) VALUES(?, ?, ?, ?, ?, ?, ?)`,
+        rec.Alias, rec.Kind, sealed, rec.Public, rec.Address, rec.Path, rec.AddrType)
     fmt.Print(nice(err))
     return err
 }
 
-func LoadWallet(alias string) (priv, pub, addr string, found bool, err error) {
+func LoadWallet(alias string) (rec WalletRecord, found bool, err error) {
     fmt.Printf("%s[INFO]%s Loading wallet: alias=%s...\n", cCyan, cReset, alias)
     db, err := InitDB()
     if err != nil {
         fmt.Print(nice(err))
-        return "", "", "", false, err
+        return WalletRecord{}, false, err
     }
     defer db.Close()
-    row := db.QueryRow(`SELECT private, public, address FROM wallet WHERE alias=?`, alias)
-    err = row.Scan(&priv, &pub, &addr)
+    row := db.QueryRow(`SELECT alias, kind, private, public, address, path, addr_type FROM wallet WHERE alias=?`, alias)
+    err = row.Scan(&rec.Alias, &rec.Kind, &rec.Private, &rec.Public, &rec.Address, &rec.Path, &rec.AddrType)
     if err == sql.ErrNoRows {
         fmt.Printf("%s[WARN]%s No record for alias %s\n", cYellow, cReset, alias)
-        return "", "", "", false, nil
+        return WalletRecord{}, false, nil
+    }
+    if err == nil {
+        rec.Private, err = unseal(unlockedKey, rec.Private, rec.Address)
+    }
+    if err == nil {
+        rec.HDAddresses, err = loadHDAddresses(db, rec.Address)
     }
     if err != nil {
         fmt.Print(nice(err))
-    } else {
-        fmt.Printf("%s[SUCCESS]%s Wallet loaded: %s\n", cGreen, cReset, alias)
+        return WalletRecord{}, false, err
     }
-    return priv, pub, addr, err == nil, err
+    fmt.Printf("%s[SUCCESS]%s Wallet loaded: %s\n", cGreen, cReset, alias)
+    return rec, true, nil
 }
 
 func DeleteWallet(alias string) error {
@@ -104,7 +249,15 @@ func DeleteWallet(alias string) error {
         return err
     }
     defer db.Close()
-    _, err = db.Exec(`DELETE FROM wallet WHERE alias=?`, alias)
+    // The derived addresses belong to the wallet's first address, which
+    // another alias may share after a rename.
+    _, err = db.Exec(`
+        DELETE FROM wallet_address WHERE wallet IN (
+            SELECT address FROM wallet w WHERE alias=?
+            AND NOT EXISTS (SELECT 1 FROM wallet o WHERE o.address = w.address AND o.alias != w.alias))`, alias)
+    if err == nil {
+        _, err = db.Exec(`DELETE FROM wallet WHERE alias=?`, alias)
+    }
     fmt.Print(nice(err))
     return err
 }
@@ -137,3 +290,82 @@ func ListWalletAliases() ([]string, error) {
     }
     return aliases, nil
 }
+
+// ListWallets returns every stored wallet without its private key, for
+// screens that only need aliases and addresses. Nothing is decrypted;
+// LoadWallet does that for the one wallet that signs.
+func ListWallets() ([]WalletRecord, error) {
+    fmt.Printf("%s[INFO]%s Getting list of all wallets...\n", cCyan, cReset)
+    db, err := InitDB()
+    if err != nil {
+        fmt.Print(nice(err))
+        return nil, err
+    }
+    defer db.Close()
+    rows, err := db.Query(`SELECT alias, kind, public, address, path, addr_type FROM wallet`)
+    if err != nil {
+        fmt.Print(nice(err))
+        return nil, err
+    }
+    defer rows.Close()
+    var recs []WalletRecord
+    for rows.Next() {
+        var rec WalletRecord
+        if err := rows.Scan(&rec.Alias, &rec.Kind, &rec.Public, &rec.Address, &rec.Path, &rec.AddrType); err != nil {
+            fmt.Print(nice(err))
+            return nil, err
+        }
+        recs = append(recs, rec)
+    }
+    if err := rows.Err(); err != nil {
+        fmt.Print(nice(err))
+        return nil, err
+    }
+    rows.Close()
+    for i := range recs {
+        if recs[i].HDAddresses, err = loadHDAddresses(db, recs[i].Address); err != nil {
+            fmt.Print(nice(err))
+            return nil, err
+        }
+    }
+    if len(recs) == 0 {
+        fmt.Printf("%s[WARN]%s No wallets stored yet.\n", cYellow, cReset)
+    }
+    return recs, nil
+}
+
+// SaveHDAddress remembers an address the HD wallet whose first address is
+// wallet has handed out, so it is watched and spent from after a restart.
+// Addresses are public, so they are stored in the clear.
+func SaveHDAddress(wallet string, a HDAddress) error {
+    fmt.Printf("%s[INFO]%s Saving address %d/%d of %s... ", cCyan, cReset, a.Change, a.Index, wallet)
+    db, err := InitDB()
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    defer db.Close()
+    _, err = db.Exec(`INSERT OR REPLACE INTO wallet_address(wallet, change, idx, address) VALUES(?, ?, ?, ?)`,
+        wallet, a.Change, a.Index, a.Address)
+    fmt.Print(nice(err))
+    return err
+}
+
+// loadHDAddresses returns the addresses saved for wallet, receive chain
+// first, each in index order.
+func loadHDAddresses(db *sql.DB, wallet string) ([]HDAddress, error) {
+    rows, err := db.Query(`SELECT change, idx, address FROM wallet_address WHERE wallet=? ORDER BY change, idx`, wallet)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+    var addrs []HDAddress
+    for rows.Next() {
+        var a HDAddress
+        if err := rows.Scan(&a.Change, &a.Index, &a.Address); err != nil {
+            return nil, err
+        }
+        addrs = append(addrs, a)
+    }
+    return addrs, rows.Err()
+}
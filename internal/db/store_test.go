@@ -0,0 +1,87 @@
+package db
+
+import (
+    "database/sql"
+    "strings"
+    "testing"
+)
+
+func TestListWalletsLeavesKeysSealed(t *testing.T) {
+    var calls int
+    path := useTempDB(t, answer("pw", &calls))
+    for _, rec := range []WalletRecord{
+        {Alias: "a", Private: testPriv, Public: "pa", Address: "addr-a"},
+        {Alias: "b", Kind: KindHD, Private: testPriv, Public: "pb", Address: "addr-b", Path: "m/44'/2'/0'/0/0", AddrType: "p2wpkh"},
+    } {
+        if err := SaveWallet(rec); err != nil {
+            t.Fatal(err)
+        }
+    }
+    // A key that no longer decrypts must not stop the listing.
+    db, _ := sql.Open("sqlite", path)
+    db.Exec(`UPDATE wallet SET private=? WHERE alias='a'`, sealPrefix+"00")
+    db.Close()
+
+    recs, err := ListWallets()
+    if err != nil {
+        t.Fatal(err)
+    }
+    got := map[string]WalletRecord{}
+    for _, rec := range recs {
+        got[rec.Alias] = rec
+    }
+    if len(recs) != 2 || got["a"].Address != "addr-a" || got["b"].Path != "m/44'/2'/0'/0/0" || got["b"].Kind != KindHD {
+        t.Fatalf("ListWallets = %+v", recs)
+    }
+    for _, rec := range recs {
+        if rec.Private != "" {
+            t.Errorf("%s: private key returned", rec.Alias)
+        }
+    }
+}
+
+func TestHDAddressesFollowTheWallet(t *testing.T) {
+    var calls int
+    path := useTempDB(t, answer("pw", &calls))
+    rec := WalletRecord{Alias: "hd", Kind: KindHD, Private: testPriv, Public: "p0", Address: "rcv-0", Path: "m/84'/2'/0'/0/0", AddrType: "p2wpkh"}
+    if err := SaveWallet(rec); err != nil {
+        t.Fatal(err)
+    }
+    for _, a := range []HDAddress{{Change: 1, Index: 0, Address: "chg-0"}, {Change: 0, Index: 1, Address: "rcv-1"}} {
+        if err := SaveHDAddress(rec.Address, a); err != nil {
+            t.Fatal(err)
+        }
+    }
+    loaded, found, err := LoadWallet("hd")
+    if err != nil || !found {
+        t.Fatalf("LoadWallet: found %v, %v", found, err)
+    }
+    if got := strings.Join(loaded.Addresses(), " "); got != "rcv-0 rcv-1 chg-0" {
+        t.Errorf("addresses = %s, want receive chain first, each in index order", got)
+    }
+
+    // Renaming saves the new alias before deleting the old one; the
+    // addresses must survive that.
+    rec.Alias = "renamed"
+    if err := SaveWallet(rec); err != nil {
+        t.Fatal(err)
+    }
+    if err := DeleteWallet("hd"); err != nil {
+        t.Fatal(err)
+    }
+    recs, err := ListWallets()
+    if err != nil || len(recs) != 1 || len(recs[0].HDAddresses) != 2 {
+        t.Fatalf("after rename ListWallets = %+v, %v", recs, err)
+    }
+
+    if err := DeleteWallet("renamed"); err != nil {
+        t.Fatal(err)
+    }
+    db, _ := sql.Open("sqlite", path)
+    defer db.Close()
+    var left int
+    db.QueryRow(`SELECT COUNT(*) FROM wallet_address`).Scan(&left)
+    if left != 0 {
+        t.Errorf("%d addresses left after deleting the wallet", left)
+    }
+}
@@ -0,0 +1,328 @@
+package db
+
+import (
+    "database/sql"
+    "fmt"
+    "sort"
+    "time"
+
+    "litecoin-wallet/internal/models"
+)
+
+// The transaction cache lets history be shown without the network. A
+// transaction is stored once in tx (with its inputs and outputs, when they
+// have been fetched) and linked to each of our addresses it touches through
+// address_tx, which holds the net change for that address. sync_state keeps
+// each address's checkpoint.
+const txCacheSchema = `
+    CREATE TABLE IF NOT EXISTS tx (
+        txid TEXT PRIMARY KEY,
+        height INTEGER NOT NULL DEFAULT 0,
+        received TEXT NOT NULL DEFAULT '',
+        fee INTEGER NOT NULL DEFAULT 0,
+        has_detail INTEGER NOT NULL DEFAULT 0
+    );
+    CREATE TABLE IF NOT EXISTS tx_input (
+        txid TEXT NOT NULL,
+        n INTEGER NOT NULL,
+        prev_txid TEXT NOT NULL,
+        prev_n INTEGER NOT NULL,
+        value INTEGER NOT NULL,
+        address TEXT NOT NULL DEFAULT '',
+        PRIMARY KEY (txid, n)
+    );
+    CREATE TABLE IF NOT EXISTS tx_output (
+        txid TEXT NOT NULL,
+        n INTEGER NOT NULL,
+        value INTEGER NOT NULL,
+        address TEXT NOT NULL DEFAULT '',
+        script TEXT NOT NULL DEFAULT '',
+        spent_by TEXT NOT NULL DEFAULT '',
+        PRIMARY KEY (txid, n)
+    );
+    CREATE TABLE IF NOT EXISTS address_tx (
+        address TEXT NOT NULL,
+        txid TEXT NOT NULL,
+        value INTEGER NOT NULL,
+        PRIMARY KEY (address, txid)
+    );
+    CREATE TABLE IF NOT EXISTS sync_state (
+        address TEXT PRIMARY KEY,
+        tip_height INTEGER NOT NULL DEFAULT 0,
+        synced_at TEXT NOT NULL DEFAULT ''
+    );
+`
+
+// CachedTx is one transaction in an address's cached history. Value is the
+// net change for the address and Height is 0 while unconfirmed. Fee, Inputs
+// and Outputs are only meaningful once HasDetail is set.
+type CachedTx struct {
+    Txid      string
+    Height    int64
+    Value     models.Amount
+    Received  string
+    Fee       models.Amount
+    HasDetail bool
+    Inputs    []models.TxInput
+    Outputs   []models.TxOutput
+}
+
+// Confirmations returns the confirmation count at chain height tip.
+func (c CachedTx) Confirmations(tip int64) int {
+    if c.Height <= 0 || tip < c.Height {
+        return 0
+    }
+    return int(tip - c.Height + 1)
+}
+
+// TxCache is what is cached for one address. Txs are newest first:
+// unconfirmed, then by descending height.
+type TxCache struct {
+    Address   string
+    TipHeight int64
+    // SyncedAt is the end of the last complete sync, zero if there was none.
+    SyncedAt time.Time
+    Txs      []CachedTx
+}
+
+// TxSyncUpdate is the outcome of one sync, applied atomically by SaveTxSync.
+// Upserted rows replace what was stored, details included, unless they come
+// without details and the stored ones are for the same block.
+type TxSyncUpdate struct {
+    Upsert    []CachedTx
+    Delete    []string
+    TipHeight int64
+    // Complete marks the cache as a full copy of the history as of now.
+    Complete bool
+}
+
+func LoadTxCache(address string) (TxCache, error) {
+    fmt.Printf("%s[INFO]%s Loading cached transactions: %s\n", cCyan, cReset, address)
+    cache := TxCache{Address: address}
+    db, err := InitDB()
+    if err != nil {
+        fmt.Print(nice(err))
+        return cache, err
+    }
+    defer db.Close()
+    var syncedAt string
+    err = db.QueryRow(`SELECT tip_height, synced_at FROM sync_state WHERE address=?`, address).Scan(&cache.TipHeight, &syncedAt)
+    if err != nil && err != sql.ErrNoRows {
+        fmt.Print(nice(err))
+        return cache, err
+    }
+    if syncedAt != "" {
+        cache.SyncedAt, _ = time.Parse(time.RFC3339, syncedAt)
+    }
+    rows, err := db.Query(`
+        SELECT t.txid, t.height, a.value, t.received, t.fee, t.has_detail
+        FROM address_tx a JOIN tx t ON t.txid = a.txid
+        WHERE a.address=?
+        ORDER BY t.height <= 0 DESC, t.height DESC, t.txid`, address)
+    if err != nil {
+        fmt.Print(nice(err))
+        return cache, err
+    }
+    for rows.Next() {
+        var c CachedTx
+        if err := rows.Scan(&c.Txid, &c.Height, &c.Value, &c.Received, &c.Fee, &c.HasDetail); err != nil {
+            rows.Close()
+            fmt.Print(nice(err))
+            return cache, err
+        }
+        cache.Txs = append(cache.Txs, c)
+    }
+    rows.Close()
+    for i := range cache.Txs {
+        if cache.Txs[i].HasDetail {
+            if err := loadTxDetail(db, &cache.Txs[i]); err != nil {
+                fmt.Print(nice(err))
+                return cache, err
+            }
+        }
+    }
+    return cache, nil
+}
+
+// MergeTxCaches combines the caches of one wallet's addresses into a single
+// history under the first one's address. A transaction touching several of
+// them appears once, with its values summed, so a send with change to
+// another of our addresses shows only what left the wallet. The result is
+// as old as the least recently synced cache.
+func MergeTxCaches(caches ...TxCache) TxCache {
+    if len(caches) == 0 {
+        return TxCache{}
+    }
+    merged := TxCache{Address: caches[0].Address, SyncedAt: caches[0].SyncedAt}
+    at := map[string]int{}
+    for _, c := range caches {
+        merged.TipHeight = max(merged.TipHeight, c.TipHeight)
+        if c.SyncedAt.IsZero() || c.SyncedAt.Before(merged.SyncedAt) {
+            merged.SyncedAt = c.SyncedAt
+        }
+        for _, t := range c.Txs {
+            if i, ok := at[t.Txid]; ok {
+                merged.Txs[i].Value += t.Value
+                continue
+            }
+            at[t.Txid] = len(merged.Txs)
+            merged.Txs = append(merged.Txs, t)
+        }
+    }
+    sort.SliceStable(merged.Txs, func(i, j int) bool {
+        a, b := merged.Txs[i], merged.Txs[j]
+        if (a.Height <= 0) != (b.Height <= 0) {
+            return a.Height <= 0
+        }
+        if a.Height != b.Height {
+            return a.Height > b.Height
+        }
+        return a.Txid < b.Txid
+    })
+    return merged
+}
+
+func loadTxDetail(db *sql.DB, c *CachedTx) error {
+    rows, err := db.Query(`SELECT prev_txid, prev_n, value, address FROM tx_input WHERE txid=? ORDER BY n`, c.Txid)
+    if err != nil {
+        return err
+    }
+    for rows.Next() {
+        var in models.TxInput
+        var addr string
+        if err := rows.Scan(&in.PrevHash, &in.OutputIndex, &in.Value, &addr); err != nil {
+            rows.Close()
+            return err
+        }
+        if addr != "" {
+            in.Addresses = []string{addr}
+        }
+        c.Inputs = append(c.Inputs, in)
+    }
+    rows.Close()
+    rows, err = db.Query(`SELECT value, address, script, spent_by FROM tx_output WHERE txid=? ORDER BY n`, c.Txid)
+    if err != nil {
+        return err
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var out models.TxOutput
+        var addr string
+        if err := rows.Scan(&out.Value, &addr, &out.Script, &out.SpentBy); err != nil {
+            return err
+        }
+        if addr != "" {
+            out.Addresses = []string{addr}
+        }
+        c.Outputs = append(c.Outputs, out)
+    }
+    return rows.Err()
+}
+
+func SaveTxSync(address string, u TxSyncUpdate) error {
+    fmt.Printf("%s[INFO]%s Updating transaction cache: %d new or changed, %d removed... ", cCyan, cReset, len(u.Upsert), len(u.Delete))
+    db, err := InitDB()
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    defer db.Close()
+    tx, err := db.Begin()
+    if err != nil {
+        fmt.Print(nice(err))
+        return err
+    }
+    err = applyTxSync(tx, address, u)
+    if err != nil {
+        tx.Rollback()
+        fmt.Print(nice(err))
+        return err
+    }
+    err = tx.Commit()
+    fmt.Print(nice(err))
+    return err
+}
+
+func applyTxSync(tx *sql.Tx, address string, u TxSyncUpdate) error {
+    for _, c := range u.Upsert {
+        if _, err := tx.Exec(`INSERT OR REPLACE INTO address_tx(address, txid, value) VALUES(?, ?, ?)`, address, c.Txid, c.Value); err != nil {
+            return err
+        }
+        // Another address may have fetched the details already; they hold
+        // as long as the transaction is still in the same block.
+        var height int64
+        var hasDetail bool
+        err := tx.QueryRow(`SELECT height, has_detail FROM tx WHERE txid=?`, c.Txid).Scan(&height, &hasDetail)
+        if err != nil && err != sql.ErrNoRows {
+            return err
+        }
+        if err == nil && hasDetail && !c.HasDetail && height == max(c.Height, 0) {
+            continue
+        }
+        if _, err := tx.Exec(`INSERT OR REPLACE INTO tx(txid, height, received, fee, has_detail) VALUES(?, ?, ?, ?, ?)`,
+            c.Txid, max(c.Height, 0), c.Received, c.Fee, c.HasDetail); err != nil {
+            return err
+        }
+        if err := deleteTxDetail(tx, c.Txid); err != nil {
+            return err
+        }
+        for n, in := range c.Inputs {
+            if _, err := tx.Exec(`INSERT INTO tx_input(txid, n, prev_txid, prev_n, value, address) VALUES(?, ?, ?, ?, ?, ?)`,
+                c.Txid, n, in.PrevHash, in.OutputIndex, in.Value, firstAddress(in.Addresses)); err != nil {
+                return err
+            }
+        }
+        for n, out := range c.Outputs {
+            if _, err := tx.Exec(`INSERT INTO tx_output(txid, n, value, address, script, spent_by) VALUES(?, ?, ?, ?, ?, ?)`,
+                c.Txid, n, out.Value, firstAddress(out.Addresses), out.Script, out.SpentBy); err != nil {
+                return err
+            }
+        }
+    }
+    for _, txid := range u.Delete {
+        if _, err := tx.Exec(`DELETE FROM address_tx WHERE address=? AND txid=?`, address, txid); err != nil {
+            return err
+        }
+        // Keep the transaction while another of our addresses lists it.
+        var refs int
+        if err := tx.QueryRow(`SELECT COUNT(*) FROM address_tx WHERE txid=?`, txid).Scan(&refs); err != nil {
+            return err
+        }
+        if refs > 0 {
+            continue
+        }
+        if _, err := tx.Exec(`DELETE FROM tx WHERE txid=?`, txid); err != nil {
+            return err
+        }
+        if err := deleteTxDetail(tx, txid); err != nil {
+            return err
+        }
+    }
+    syncedAt := ""
+    if u.Complete {
+        syncedAt = time.Now().UTC().Format(time.RFC3339)
+    } else {
+        // Keep the previous checkpoint; this sync did not finish.
+        err := tx.QueryRow(`SELECT synced_at FROM sync_state WHERE address=?`, address).Scan(&syncedAt)
+        if err != nil && err != sql.ErrNoRows {
+            return err
+        }
+    }
+    _, err := tx.Exec(`INSERT OR REPLACE INTO sync_state(address, tip_height, synced_at) VALUES(?, ?, ?)`, address, u.TipHeight, syncedAt)
+    return err
+}
+
+func deleteTxDetail(tx *sql.Tx, txid string) error {
+    if _, err := tx.Exec(`DELETE FROM tx_input WHERE txid=?`, txid); err != nil {
+        return err
+    }
+    _, err := tx.Exec(`DELETE FROM tx_output WHERE txid=?`, txid)
+    return err
+}
+
+func firstAddress(addrs []string) string {
+    if len(addrs) == 0 {
+        return ""
+    }
+    return addrs[0]
+}
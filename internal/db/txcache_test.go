@@ -0,0 +1,36 @@
+package db
+
+import (
+    "testing"
+    "time"
+)
+
+func TestMergeTxCachesNetsOutOwnTransfers(t *testing.T) {
+    synced := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+    receive := TxCache{Address: "rcv-0", TipHeight: 100, SyncedAt: synced, Txs: []CachedTx{
+        {Txid: "send", Height: 100, Value: -50_000},
+        {Txid: "pay", Height: 90, Value: 80_000},
+    }}
+    change := TxCache{Address: "chg-0", TipHeight: 101, SyncedAt: synced.Add(time.Hour), Txs: []CachedTx{
+        {Txid: "pending", Value: 1_000},
+        {Txid: "send", Height: 100, Value: 20_000},
+    }}
+    merged := MergeTxCaches(receive, change)
+    if merged.Address != "rcv-0" || merged.TipHeight != 101 || !merged.SyncedAt.Equal(synced) {
+        t.Errorf("merged %s at tip %d synced %s", merged.Address, merged.TipHeight, merged.SyncedAt)
+    }
+    want := []CachedTx{{Txid: "pending", Value: 1_000}, {Txid: "send", Height: 100, Value: -30_000}, {Txid: "pay", Height: 90, Value: 80_000}}
+    if len(merged.Txs) != len(want) {
+        t.Fatalf("merged %d transactions, want %d: %+v", len(merged.Txs), len(want), merged.Txs)
+    }
+    for i, w := range want {
+        if got := merged.Txs[i]; got.Txid != w.Txid || got.Value != w.Value {
+            t.Errorf("tx %d = %s %s, want %s %s", i, got.Txid, got.Value, w.Txid, w.Value)
+        }
+    }
+
+    change.SyncedAt = time.Time{}
+    if merged := MergeTxCaches(receive, change); !merged.SyncedAt.IsZero() {
+        t.Errorf("merged with a never-synced cache claims to be synced at %s", merged.SyncedAt)
+    }
+}
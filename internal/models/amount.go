@@ -0,0 +1,133 @@
+package models
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// Amount is a quantity of litecoin in litoshis (1e-8 LTC). It is exact:
+// parse and format it with the functions here, never through float64.
+type Amount int64
+
+// Unit is a denomination amounts can be written in.
+type Unit int
+
+const (
+    UnitLTC Unit = iota
+    UnitMilliLTC
+    UnitMicroLTC
+    UnitLitoshi
+)
+
+// Units lists every denomination, largest first.
+var Units = []Unit{UnitLTC, UnitMilliLTC, UnitMicroLTC, UnitLitoshi}
+
+// String is the unit's symbol, e.g. "mLTC".
+func (u Unit) String() string {
+    switch u {
+    case UnitMilliLTC:
+        return "mLTC"
+    case UnitMicroLTC:
+        return "µLTC"
+    case UnitLitoshi:
+        return "litoshi"
+    default:
+        return "LTC"
+    }
+}
+
+// Decimals is how many decimal places of the unit a litoshi is.
+func (u Unit) Decimals() int {
+    switch u {
+    case UnitMilliLTC:
+        return 5
+    case UnitMicroLTC:
+        return 2
+    case UnitLitoshi:
+        return 0
+    default:
+        return 8
+    }
+}
+
+// ParseUnit accepts a unit symbol in any case. "u" stands in for "µ" and
+// "sat" for "litoshi".
+func ParseUnit(s string) (Unit, error) {
+    switch strings.ToLower(strings.TrimSpace(s)) {
+    case "ltc":
+        return UnitLTC, nil
+    case "mltc":
+        return UnitMilliLTC, nil
+    case "µltc", "μltc", "ultc":
+        return UnitMicroLTC, nil
+    case "litoshi", "litoshis", "lit", "sat", "sats":
+        return UnitLitoshi, nil
+    }
+    return 0, fmt.Errorf("unknown unit %q (use LTC, mLTC, µLTC or litoshi)", s)
+}
+
+// ParseAmount reads an amount such as "0.29", "0.29 LTC", "290mLTC" or
+// "1500 litoshi". A bare number is in LTC. It fails rather than round when
+// the value has more decimals than a litoshi allows.
+func ParseAmount(s string) (Amount, error) {
+    s = strings.TrimSpace(s)
+    end := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+' })
+    unit := UnitLTC
+    num := s
+    if end >= 0 {
+        var err error
+        if unit, err = ParseUnit(s[end:]); err != nil {
+            return 0, err
+        }
+        num = strings.TrimSpace(s[:end])
+    }
+    return ParseAmountIn(num, unit)
+}
+
+// ParseAmountIn reads a plain decimal number of unit u.
+func ParseAmountIn(s string, u Unit) (Amount, error) {
+    s = strings.TrimSpace(s)
+    neg := strings.HasPrefix(s, "-")
+    digits := strings.TrimLeft(s, "+-")
+    if len(s)-len(digits) > 1 {
+        return 0, fmt.Errorf("invalid amount %q", s)
+    }
+    whole, frac, _ := strings.Cut(digits, ".")
+    if whole == "" && frac == "" || strings.Trim(whole+frac, "0123456789") != "" {
+        return 0, fmt.Errorf("invalid amount %q", s)
+    }
+    if len(frac) > u.Decimals() {
+        return 0, fmt.Errorf("invalid amount %q: %s has at most %d decimal places", s, u, u.Decimals())
+    }
+    n, err := strconv.ParseInt(whole+frac+strings.Repeat("0", u.Decimals()-len(frac)), 10, 64)
+    if err != nil {
+        return 0, fmt.Errorf("invalid amount %q: too large", s)
+    }
+    if neg {
+        n = -n
+    }
+    return Amount(n), nil
+}
+
+// In formats a in unit u with every decimal place, without the symbol,
+// e.g. "0.29000000".
+func (a Amount) In(u Unit) string {
+    n := int64(a)
+    sign := ""
+    if n < 0 {
+        sign, n = "-", -n
+    }
+    d := u.Decimals()
+    s := fmt.Sprintf("%0*d", d+1, n)
+    if d == 0 {
+        return sign + s
+    }
+    return sign + s[:len(s)-d] + "." + s[len(s)-d:]
+}
+
+// Format is In followed by the unit's symbol, e.g. "290.00000 mLTC".
+func (a Amount) Format(u Unit) string { return a.In(u) + " " + u.String() }
+
+// String formats a in LTC, e.g. "0.29000000 LTC".
+func (a Amount) String() string { return a.Format(UnitLTC) }
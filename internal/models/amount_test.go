@@ -0,0 +1,54 @@
+package models
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+    tests := []struct {
+        in   string
+        want Amount
+    }{
+        {"0.29", 29_000_000},
+        {"0.29 LTC", 29_000_000},
+        {"1", 100_000_000},
+        {".5", 50_000_000},
+        {"0.00000001", 1},
+        {"290mLTC", 29_000_000},
+        {"1.5 mLTC", 150_000},
+        {"2.25 µLTC", 225},
+        {"3 uLTC", 300},
+        {"1500 litoshi", 1500},
+        {"84000000", 8_400_000_000_000_000},
+        {"-0.1", -10_000_000},
+    }
+    for _, tt := range tests {
+        got, err := ParseAmount(tt.in)
+        if err != nil || got != tt.want {
+            t.Errorf("ParseAmount(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+        }
+    }
+    for _, bad := range []string{"", ".", "abc", "1.2.3", "0.000000001", "1.5 litoshi", "1 BTC", "--1", "1e-3", "99999999999999 LTC"} {
+        if got, err := ParseAmount(bad); err == nil {
+            t.Errorf("ParseAmount(%q) = %d, want an error", bad, got)
+        }
+    }
+}
+
+func TestAmountFormat(t *testing.T) {
+    a := Amount(28_999_999)
+    for u, want := range map[Unit]string{
+        UnitLTC:      "0.28999999 LTC",
+        UnitMilliLTC: "289.99999 mLTC",
+        UnitMicroLTC: "289999.99 µLTC",
+        UnitLitoshi:  "28999999 litoshi",
+    } {
+        if got := a.Format(u); got != want {
+            t.Errorf("Format(%s) = %q, want %q", u, got, want)
+        }
+        if back, err := ParseAmount(a.Format(u)); err != nil || back != a {
+            t.Errorf("round trip through %s = %d, %v", u, back, err)
+        }
+    }
+    if got := Amount(-5).String(); got != "-0.00000005 LTC" {
+        t.Errorf("String() = %q", got)
+    }
+}
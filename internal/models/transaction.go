@@ -1,17 +1,69 @@
 package models
 
+import "fmt"
+
 type Transaction struct {
     Hash          string   `json:"hash"`
+    BlockHeight   int64    `json:"block_height"` // 0 or -1 while unconfirmed
     Confirmations int      `json:"confirmations"`
-    Value         int64    `json:"value"`
+    Value         Amount   `json:"value"`
     Received      string   `json:"received"`
     Addresses     []string `json:"addresses"`
 }
 type AddressOverview struct {
-    Balance        int64         `json:"balance"`
-    TotalReceived  int64         `json:"total_received"`
-    TotalSent      int64         `json:"total_sent"`
+    Balance        Amount        `json:"balance"`
+    TotalReceived  Amount        `json:"total_received"`
+    TotalSent      Amount        `json:"total_sent"`
     NTx            int           `json:"n_tx"`
     Txrefs         []Transaction `json:"txrefs"`
-    UnconfirmedBalance int64     `json:"unconfirmed_balance"`
+    UnconfirmedBalance Amount    `json:"unconfirmed_balance"`
+}
+
+// UTXO is an unspent output owned by one of our addresses.
+type UTXO struct {
+    TxHash        string `json:"tx_hash"`
+    OutputIndex   uint32 `json:"tx_output_n"`
+    Value         Amount `json:"value"`
+    Confirmations int    `json:"confirmations"`
+    Script        string `json:"script"`
+    Address       string `json:"address"`
+}
+
+// Outpoint identifies the output as "txid:vout".
+func (u UTXO) Outpoint() string { return fmt.Sprintf("%s:%d", u.TxHash, u.OutputIndex) }
+
+// TxInput is one input of a looked-up transaction.
+type TxInput struct {
+    PrevHash    string   `json:"prev_hash"`
+    OutputIndex int      `json:"output_index"`
+    Value       Amount   `json:"output_value"`
+    Addresses   []string `json:"addresses"`
+}
+
+// TxOutput is one output of a looked-up transaction.
+type TxOutput struct {
+    Value     Amount   `json:"value"`
+    Addresses []string `json:"addresses"`
+    Script    string   `json:"script"`
+    SpentBy   string   `json:"spent_by"`
+}
+
+// TxDetail is a full transaction as returned by a chain provider.
+type TxDetail struct {
+    Hash          string     `json:"hash"`
+    BlockHeight   int64      `json:"block_height"`
+    Confirmations int        `json:"confirmations"`
+    Fees          Amount     `json:"fees"`
+    Size          int        `json:"size"`
+    VSize         int        `json:"vsize"`
+    Received      string     `json:"received"`
+    Inputs        []TxInput  `json:"inputs"`
+    Outputs       []TxOutput `json:"outputs"`
+}
+
+// FeeEstimate holds fee rates in litoshis per kB for three confirmation targets.
+type FeeEstimate struct {
+    LowPerKB    int64 `json:"low_fee_per_kb"`
+    MediumPerKB int64 `json:"medium_fee_per_kb"`
+    HighPerKB   int64 `json:"high_fee_per_kb"`
 }
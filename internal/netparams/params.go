@@ -0,0 +1,184 @@
+// Package netparams defines the Litecoin networks in terms of btcd's
+// chaincfg.Params so btcutil, txscript and hdkeychain encode Litecoin
+// addresses and keys instead of Bitcoin ones.
+package netparams
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/btcsuite/btcd/btcutil"
+    "github.com/btcsuite/btcd/btcutil/base58"
+    "github.com/btcsuite/btcd/btcutil/bech32"
+    "github.com/btcsuite/btcd/chaincfg"
+    "github.com/btcsuite/btcd/chaincfg/chainhash"
+    "github.com/btcsuite/btcd/wire"
+)
+
+// legacyScriptHashAddrID is the old Litecoin P2SH version byte ("3…"). Core
+// still accepts it on mainnet but encodes script hashes with 0x32 ("M…").
+const legacyScriptHashAddrID = 0x05
+
+func hashFromStr(s string) *chainhash.Hash {
+    h, err := chainhash.NewHashFromStr(s)
+    if err != nil {
+        panic(err)
+    }
+    return h
+}
+
+// MainNetParams are the Litecoin mainnet parameters.
+var MainNetParams = chaincfg.Params{
+    Name:        "mainnet",
+    Net:         wire.BitcoinNet(0xdbb6c0fb),
+    DefaultPort: "9333",
+    DNSSeeds: []chaincfg.DNSSeed{
+        {Host: "seed-a.litecoin.loshan.co.uk", HasFiltering: true},
+        {Host: "dnsseed.thrasher.io", HasFiltering: true},
+        {Host: "dnsseed.litecointools.com"},
+        {Host: "dnsseed.litecoinpool.org"},
+    },
+    GenesisHash:        hashFromStr("12a765e31ffd4059bada1e25190f6e98c99d9714d334efa41a195a7e7e04bfe2"),
+    TargetTimePerBlock: 150 * time.Second,
+    CoinbaseMaturity:   100,
+
+    Bech32HRPSegwit:  "ltc",
+    PubKeyHashAddrID: 0x30, // L
+    ScriptHashAddrID: 0x32, // M
+    PrivateKeyID:     0xb0, // 6 / T
+
+    HDPrivateKeyID: [4]byte{0x04, 0x88, 0xad, 0xe4}, // xprv
+    HDPublicKeyID:  [4]byte{0x04, 0x88, 0xb2, 0x1e}, // xpub
+    HDCoinType:     2,
+}
+
+// TestNet4Params are the Litecoin testnet4 parameters.
+var TestNet4Params = chaincfg.Params{
+    Name:        "testnet4",
+    Net:         wire.BitcoinNet(0xf1c8d2fd),
+    DefaultPort: "19335",
+    DNSSeeds: []chaincfg.DNSSeed{
+        {Host: "testnet-seed.litecointools.com"},
+        {Host: "seed-b.litecoin.loshan.co.uk", HasFiltering: true},
+        {Host: "dnsseed-testnet.thrasher.io", HasFiltering: true},
+    },
+    GenesisHash:        hashFromStr("4966625a4b2851d9fdee139e56211a0d88575f59ed816ff5e6a63deb4e3e29a0"),
+    TargetTimePerBlock: 150 * time.Second,
+    CoinbaseMaturity:   100,
+
+    Bech32HRPSegwit:  "tltc",
+    PubKeyHashAddrID: 0x6f, // m or n
+    ScriptHashAddrID: 0x3a, // Q
+    PrivateKeyID:     0xef, // c
+
+    HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94}, // tprv
+    HDPublicKeyID:  [4]byte{0x04, 0x35, 0x87, 0xcf}, // tpub
+    HDCoinType:     1,
+}
+
+// RegressionNetParams are the Litecoin regtest parameters.
+var RegressionNetParams = chaincfg.Params{
+    Name:               "regtest",
+    Net:                wire.BitcoinNet(0xdab5bffa),
+    DefaultPort:        "19444",
+    GenesisHash:        hashFromStr("530827f38f93b43ed12af0b3ad25a288dc02ed74d6d7857862df51fc56c416f9"),
+    TargetTimePerBlock: 150 * time.Second,
+    CoinbaseMaturity:   100,
+
+    Bech32HRPSegwit:  "rltc",
+    PubKeyHashAddrID: 0x6f, // m or n
+    ScriptHashAddrID: 0x3a, // Q
+    PrivateKeyID:     0xef, // c
+
+    HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94}, // tprv
+    HDPublicKeyID:  [4]byte{0x04, 0x35, 0x87, 0xcf}, // tpub
+    HDCoinType:     1,
+}
+
+// ByName returns the parameters for "mainnet", "testnet" or "regtest".
+// Common aliases such as "main", "testnet4" and "test" are accepted.
+func ByName(name string) (*chaincfg.Params, error) {
+    switch strings.ToLower(strings.TrimSpace(name)) {
+    case "", "main", "mainnet":
+        return &MainNetParams, nil
+    case "test", "testnet", "testnet4":
+        return &TestNet4Params, nil
+    case "regtest", "regnet":
+        return &RegressionNetParams, nil
+    }
+    return nil, fmt.Errorf("unknown network %q (want mainnet, testnet or regtest)", name)
+}
+
+// Register adds the Litecoin networks to chaincfg's registry so that
+// btcutil.DecodeAddress recognises their bech32 prefixes. Regtest shares its
+// magic with Bitcoin regtest, which chaincfg registers already, so it cannot
+// be registered; DecodeAddress below does not depend on the registry.
+func Register() error {
+    for _, p := range []*chaincfg.Params{&MainNetParams, &TestNet4Params} {
+        if err := chaincfg.Register(p); err != nil && !errors.Is(err, chaincfg.ErrDuplicateNet) {
+            return fmt.Errorf("register %s: %w", p.Name, err)
+        }
+    }
+    return nil
+}
+
+func init() {
+    if err := Register(); err != nil {
+        panic(err)
+    }
+}
+
+// DecodeAddress parses a Litecoin address and checks it belongs to params.
+func DecodeAddress(addr string, params *chaincfg.Params) (btcutil.Address, error) {
+    addr = strings.TrimSpace(addr)
+    if strings.HasPrefix(strings.ToLower(addr), params.Bech32HRPSegwit+"1") {
+        return decodeSegWit(addr, params)
+    }
+    payload, version, err := base58.CheckDecode(addr)
+    if err != nil {
+        if _, _, _, berr := bech32.DecodeGeneric(addr); berr == nil {
+            return nil, fmt.Errorf("address %q is not a %s Litecoin address", addr, params.Name)
+        }
+        return nil, fmt.Errorf("invalid address %q: %w", addr, err)
+    }
+    if len(payload) != 20 {
+        return nil, fmt.Errorf("invalid address %q: bad payload length", addr)
+    }
+    switch {
+    case version == params.PubKeyHashAddrID:
+        return btcutil.NewAddressPubKeyHash(payload, params)
+    case version == params.ScriptHashAddrID,
+        params.Net == MainNetParams.Net && version == legacyScriptHashAddrID:
+        return btcutil.NewAddressScriptHashFromHash(payload, params)
+    }
+    return nil, fmt.Errorf("address %q is not a %s Litecoin address", addr, params.Name)
+}
+
+func decodeSegWit(addr string, params *chaincfg.Params) (btcutil.Address, error) {
+    hrp, data, version, err := bech32.DecodeGeneric(addr)
+    if err != nil {
+        return nil, fmt.Errorf("invalid address %q: %w", addr, err)
+    }
+    if hrp != params.Bech32HRPSegwit || len(data) < 1 {
+        return nil, fmt.Errorf("address %q is not a %s Litecoin address", addr, params.Name)
+    }
+    witnessVersion := data[0]
+    program, err := bech32.ConvertBits(data[1:], 5, 8, false)
+    if err != nil {
+        return nil, fmt.Errorf("invalid address %q: %w", addr, err)
+    }
+    if (witnessVersion == 0) != (version == bech32.Version0) {
+        return nil, fmt.Errorf("invalid address %q: wrong checksum variant", addr)
+    }
+    switch {
+    case witnessVersion == 0 && len(program) == 20:
+        return btcutil.NewAddressWitnessPubKeyHash(program, params)
+    case witnessVersion == 0 && len(program) == 32:
+        return btcutil.NewAddressWitnessScriptHash(program, params)
+    case witnessVersion == 1 && len(program) == 32:
+        return btcutil.NewAddressTaproot(program, params)
+    }
+    return nil, fmt.Errorf("unsupported witness program in %q", addr)
+}
@@ -0,0 +1,96 @@
+package netparams
+
+import (
+    "encoding/hex"
+    "testing"
+
+    "github.com/btcsuite/btcd/btcec/v2"
+    "github.com/btcsuite/btcd/btcutil"
+    "github.com/btcsuite/btcd/chaincfg"
+)
+
+// hash160 of the compressed public key for private key 1.
+const pkhOne = "751e76e8199196d454941c45d1b3a323f1433bd6"
+
+func TestEncodeGoldenVectors(t *testing.T) {
+    pkh, _ := hex.DecodeString(pkhOne)
+    p2pkh, err := btcutil.NewAddressPubKeyHash(pkh, &MainNetParams)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got, want := p2pkh.EncodeAddress(), "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ"; got != want {
+        t.Errorf("P2PKH = %s, want %s", got, want)
+    }
+    p2wpkh, err := btcutil.NewAddressWitnessPubKeyHash(pkh, &MainNetParams)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got, want := p2wpkh.EncodeAddress(), "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9"; got != want {
+        t.Errorf("P2WPKH = %s, want %s", got, want)
+    }
+
+    priv, _ := btcec.PrivKeyFromBytes([]byte{31: 1})
+    wif, err := btcutil.NewWIF(priv, &MainNetParams, true)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got, want := wif.String(), "T33ydQRKp4FCW5LCLLUB7deioUMoveiwekdwUwyfRDeGZm76aUjV"; got != want {
+        t.Errorf("WIF = %s, want %s", got, want)
+    }
+}
+
+func TestDecodeAddress(t *testing.T) {
+    tests := []struct {
+        addr   string
+        params *chaincfg.Params
+        want   string // re-encoded form, empty when decoding must fail
+    }{
+        {"LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ", &MainNetParams, "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ"},
+        {"ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9", &MainNetParams, "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9"},
+        {"LTC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KGMN4N9", &MainNetParams, "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9"},
+        {"MR8UQSBr5ULwWheBHznrHk2jxyxkHQu8vB", &MainNetParams, "MR8UQSBr5ULwWheBHznrHk2jxyxkHQu8vB"},
+        // Deprecated 0x05 P2SH addresses are accepted and re-encoded with 0x32.
+        {"3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN", &MainNetParams, "MR8UQSBr5ULwWheBHznrHk2jxyxkHQu8vB"},
+        {"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH", &MainNetParams, ""},
+        {"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4", &MainNetParams, ""},
+        {"LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnK", &MainNetParams, ""},
+        {"LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ", &TestNet4Params, ""},
+        {"ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9", &TestNet4Params, ""},
+    }
+    for _, tt := range tests {
+        got, err := DecodeAddress(tt.addr, tt.params)
+        if tt.want == "" {
+            if err == nil {
+                t.Errorf("DecodeAddress(%s, %s) = %s, want error", tt.addr, tt.params.Name, got.EncodeAddress())
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("DecodeAddress(%s, %s): %v", tt.addr, tt.params.Name, err)
+            continue
+        }
+        if got.EncodeAddress() != tt.want || !got.IsForNet(tt.params) {
+            t.Errorf("DecodeAddress(%s, %s) = %s, want %s", tt.addr, tt.params.Name, got.EncodeAddress(), tt.want)
+        }
+    }
+}
+
+func TestTestnetRegtestRoundTrip(t *testing.T) {
+    pkh, _ := hex.DecodeString(pkhOne)
+    for _, p := range []*chaincfg.Params{&TestNet4Params, &RegressionNetParams} {
+        for _, build := range []func() (btcutil.Address, error){
+            func() (btcutil.Address, error) { return btcutil.NewAddressPubKeyHash(pkh, p) },
+            func() (btcutil.Address, error) { return btcutil.NewAddressScriptHashFromHash(pkh, p) },
+            func() (btcutil.Address, error) { return btcutil.NewAddressWitnessPubKeyHash(pkh, p) },
+        } {
+            a, err := build()
+            if err != nil {
+                t.Fatal(err)
+            }
+            got, err := DecodeAddress(a.EncodeAddress(), p)
+            if err != nil || got.EncodeAddress() != a.EncodeAddress() {
+                t.Errorf("%s: round trip of %s failed: %v", p.Name, a.EncodeAddress(), err)
+            }
+        }
+    }
+}
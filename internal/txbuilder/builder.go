@@ -0,0 +1,290 @@
+// Package txbuilder assembles and signs Litecoin transactions locally from a
+// set of UTXOs, so no server ever decides where our coins go.
+package txbuilder
+
+import (
+    "bytes"
+    "encoding/hex"
+    "errors"
+    "fmt"
+
+    "litecoin-wallet/internal/coinselect"
+    "litecoin-wallet/internal/crypto"
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "github.com/btcsuite/btcd/chaincfg"
+    "github.com/btcsuite/btcd/chaincfg/chainhash"
+    "github.com/btcsuite/btcd/txscript"
+    "github.com/btcsuite/btcd/wire"
+)
+
+// DustLimit is the smallest output worth creating; smaller change is left to
+// the miner instead.
+const DustLimit = 546
+
+// RBFSequence is the sequence number set on every input. Anything below
+// 0xfffffffe signals BIP 125 replaceability, so a stuck payment can later be
+// bumped with Replace.
+const RBFSequence = wire.MaxTxInSequenceNum - 2
+
+var (
+    ErrInsufficientFunds = coinselect.ErrInsufficientFunds
+    ErrNoUTXOs           = errors.New("no spendable outputs")
+    ErrZeroValue         = errors.New("amount must be positive")
+)
+
+// Payment is one output of the transaction being built.
+type Payment struct {
+    Address string
+    Value   models.Amount
+    // Label is the user's note for the payment; it is not part of the
+    // transaction.
+    Label string
+}
+
+// MaxStandardVSize is the largest transaction nodes relay by default
+// (400,000 weight units).
+const MaxStandardVSize = 100_000
+
+// Request describes a spend from our addresses, all of one type.
+type Request struct {
+    Params   *chaincfg.Params
+    From     string             // our address; change returns here unless Change is set
+    AddrType crypto.AddressType // type of From, decides how inputs are signed
+    UTXOs    []models.UTXO      // candidate inputs, each from From or another address of AddrType
+    // Change is where change goes when it is not From, such as a fresh
+    // address on an HD wallet's change chain.
+    Change string
+    // Strategy picks the inputs among UTXOs; empty spends them in order.
+    Strategy coinselect.Strategy
+    Outputs  []Payment
+    FeePerKB int64
+    // SendAll spends every UTXO and gives the single output everything left
+    // after the fee; its Value is ignored.
+    SendAll bool
+    // SpendAll uses every UTXO as an input even when fewer would cover the
+    // payment, for coins the user picked by hand. Anything left over goes
+    // to change as usual.
+    SpendAll bool
+}
+
+// Draft is a built but not yet signed transaction plus everything needed to
+// review and sign it.
+type Draft struct {
+    Tx          *wire.MsgTx
+    Payments    []Payment // what is paid out, change excluded; send-all value filled in
+    Inputs      []models.UTXO
+    PrevOuts    []*wire.TxOut
+    AddrType    crypto.AddressType
+    Fee         models.Amount
+    FeePerKB    int64
+    VSize       int64
+    Change      models.Amount
+    ChangeIndex int // -1 when there is no change output
+    // ChangeAddress is where Change goes; empty when there is none.
+    ChangeAddress string
+}
+
+// Build selects inputs, adds outputs and change and fixes the fee from the
+// estimated virtual size.
+func Build(req Request) (*Draft, error) {
+    if len(req.Outputs) == 0 {
+        return nil, fmt.Errorf("no outputs")
+    }
+    if len(req.UTXOs) == 0 {
+        return nil, ErrNoUTXOs
+    }
+    fromScript, err := payScript(req.From, req.Params)
+    if err != nil {
+        return nil, err
+    }
+    changeTo := req.From
+    if req.Change != "" {
+        changeTo = req.Change
+    }
+    changeScript, err := payScript(changeTo, req.Params)
+    if err != nil {
+        return nil, err
+    }
+    var outScripts [][]byte
+    var target models.Amount
+    for _, o := range req.Outputs {
+        addr, err := netparams.DecodeAddress(o.Address, req.Params)
+        if err != nil {
+            return nil, err
+        }
+        script, err := txscript.PayToAddrScript(addr)
+        if err != nil {
+            return nil, err
+        }
+        if !req.SendAll && o.Value <= 0 {
+            return nil, ErrZeroValue
+        }
+        outScripts = append(outScripts, script)
+        target += o.Value
+    }
+    if req.SendAll && len(req.Outputs) != 1 {
+        return nil, fmt.Errorf("send-all needs exactly one output")
+    }
+    withChange := append(append([][]byte{}, outScripts...), changeScript)
+    feeFor := func(nIn int, scripts [][]byte) models.Amount {
+        return feeAt(EstimateVSize(req.AddrType, nIn, scripts), req.FeePerKB)
+    }
+
+    utxos, spendAll := req.UTXOs, req.SpendAll
+    if req.Strategy != "" && !req.SendAll && !req.SpendAll {
+        utxos, err = coinselect.Select(req.Strategy, req.UTXOs, coinselect.Target{
+            Value: target,
+            Fee: func(n int, change bool) models.Amount {
+                if change {
+                    return feeFor(n, withChange)
+                }
+                return feeFor(n, outScripts)
+            },
+            ChangeSpendFee: feeAt((req.AddrType.InputWeight()+3)/4, req.FeePerKB),
+            Dust:           DustLimit,
+        })
+        if err != nil {
+            return nil, err
+        }
+        spendAll = true
+    }
+
+    d := &Draft{Tx: wire.NewMsgTx(2), AddrType: req.AddrType, FeePerKB: req.FeePerKB, ChangeIndex: -1}
+    var total models.Amount
+    for _, u := range utxos {
+        if !req.SendAll && !spendAll && total >= target+feeFor(len(d.Inputs), withChange) {
+            break
+        }
+        script := fromScript
+        if u.Address != "" && u.Address != req.From {
+            if script, err = payScript(u.Address, req.Params); err != nil {
+                return nil, err
+            }
+        }
+        if err := d.addInput(u, script); err != nil {
+            return nil, err
+        }
+        total += u.Value
+    }
+
+    if req.SendAll {
+        d.Fee = feeFor(len(d.Inputs), outScripts)
+        value := total - d.Fee
+        if value <= DustLimit {
+            return nil, ErrInsufficientFunds
+        }
+        d.Tx.AddTxOut(wire.NewTxOut(int64(value), outScripts[0]))
+        d.Payments = []Payment{{Address: req.Outputs[0].Address, Value: value}}
+    } else {
+        if total < target+feeFor(len(d.Inputs), outScripts) {
+            return nil, ErrInsufficientFunds
+        }
+        for i, o := range req.Outputs {
+            d.Tx.AddTxOut(wire.NewTxOut(int64(o.Value), outScripts[i]))
+        }
+        d.Payments = append([]Payment(nil), req.Outputs...)
+        d.Fee = total - target
+        if change := total - target - feeFor(len(d.Inputs), withChange); change > DustLimit {
+            d.Change, d.ChangeIndex, d.ChangeAddress = change, len(d.Tx.TxOut), changeTo
+            d.Fee = total - target - change
+            d.Tx.AddTxOut(wire.NewTxOut(int64(change), changeScript))
+        }
+    }
+    d.VSize = EstimateVSize(req.AddrType, len(d.Inputs), txOutScripts(d.Tx))
+    return d, nil
+}
+
+// payScript is the output script paying address.
+func payScript(address string, params *chaincfg.Params) ([]byte, error) {
+    addr, err := netparams.DecodeAddress(address, params)
+    if err != nil {
+        return nil, err
+    }
+    return txscript.PayToAddrScript(addr)
+}
+
+func (d *Draft) addInput(u models.UTXO, fallbackScript []byte) error {
+    hash, err := chainhash.NewHashFromStr(u.TxHash)
+    if err != nil {
+        return fmt.Errorf("bad utxo %s: %w", u.TxHash, err)
+    }
+    script, err := hex.DecodeString(u.Script)
+    if err != nil || len(script) == 0 {
+        script = fallbackScript
+    }
+    in := wire.NewTxIn(wire.NewOutPoint(hash, u.OutputIndex), nil, nil)
+    in.Sequence = RBFSequence
+    d.Tx.AddTxIn(in)
+    d.Inputs = append(d.Inputs, u)
+    d.PrevOuts = append(d.PrevOuts, wire.NewTxOut(int64(u.Value), script))
+    return nil
+}
+
+// Sign computes each input's sighash, signs it with the key and verifies the
+// result against the spent scripts.
+func (d *Draft) Sign(privateKeyHex string) error {
+    return crypto.SignInputs(d.Tx, d.PrevOuts, privateKeyHex, d.AddrType)
+}
+
+// SignWith signs each input with the key of the address it spends from.
+// keys maps our addresses to their private keys, as for a wallet whose
+// coins sit on more than one address.
+func (d *Draft) SignWith(keys map[string]string) error {
+    perInput := make([]string, len(d.Inputs))
+    for i, u := range d.Inputs {
+        key, ok := keys[u.Address]
+        if !ok {
+            return fmt.Errorf("no key for %s, spent by input %s", u.Address, u.Outpoint())
+        }
+        perInput[i] = key
+    }
+    return crypto.SignInputsEach(d.Tx, d.PrevOuts, perInput, d.AddrType)
+}
+
+// RawHex serializes the (signed) transaction for broadcasting.
+func (d *Draft) RawHex() (string, error) {
+    var buf bytes.Buffer
+    if err := d.Tx.Serialize(&buf); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// InputTotal is the sum of all spent outputs.
+func (d *Draft) InputTotal() models.Amount {
+    var total models.Amount
+    for _, u := range d.Inputs {
+        total += u.Value
+    }
+    return total
+}
+
+func txOutScripts(tx *wire.MsgTx) [][]byte {
+    scripts := make([][]byte, len(tx.TxOut))
+    for i, o := range tx.TxOut {
+        scripts[i] = o.PkScript
+    }
+    return scripts
+}
+
+// feeAt is the fee for vsize vbytes at perKB litoshis per kB, rounded up so
+// a fractional rate such as 1.5 sat/vB never pays less than it promises.
+func feeAt(vsize, perKB int64) models.Amount {
+    return models.Amount((vsize*perKB + 999) / 1000)
+}
+
+// EstimateVSize returns the virtual size of a transaction spending nIn inputs
+// of addrType to outputs with the given scripts, assuming worst-case 72-byte
+// signatures.
+func EstimateVSize(addrType crypto.AddressType, nIn int, outScripts [][]byte) int64 {
+    weight := int64(4+4+wire.VarIntSerializeSize(uint64(nIn))+wire.VarIntSerializeSize(uint64(len(outScripts)))) * 4
+    for _, s := range outScripts {
+        weight += int64(8+wire.VarIntSerializeSize(uint64(len(s)))+len(s)) * 4
+    }
+    weight += int64(nIn) * addrType.InputWeight()
+    if addrType != crypto.AddrP2PKH && nIn > 0 {
+        weight += 2 // segwit marker and flag
+    }
+    return (weight + 3) / 4
+}
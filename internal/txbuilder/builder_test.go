@@ -0,0 +1,240 @@
+package txbuilder
+
+import (
+    "encoding/hex"
+    "errors"
+    "testing"
+
+    "litecoin-wallet/internal/coinselect"
+    "litecoin-wallet/internal/crypto"
+    "litecoin-wallet/internal/models"
+    "litecoin-wallet/internal/netparams"
+    "github.com/btcsuite/btcd/txscript"
+)
+
+const testKey = "0000000000000000000000000000000000000000000000000000000000000001"
+
+func testRequest(t *testing.T, addrType crypto.AddressType, values ...models.Amount) Request {
+    t.Helper()
+    w, err := crypto.LoadLitecoinWallet(&netparams.MainNetParams, testKey, addrType)
+    if err != nil {
+        t.Fatal(err)
+    }
+    addr, _ := netparams.DecodeAddress(w.Address, &netparams.MainNetParams)
+    script, _ := txscript.PayToAddrScript(addr)
+    req := Request{Params: &netparams.MainNetParams, From: w.Address, AddrType: addrType, FeePerKB: 10_000}
+    for i, v := range values {
+        req.UTXOs = append(req.UTXOs, models.UTXO{
+            TxHash:      hex.EncodeToString([]byte{31: byte(i + 1)}),
+            OutputIndex: uint32(i),
+            Value:       v,
+            Script:      hex.EncodeToString(script),
+        })
+    }
+    return req
+}
+
+func TestBuildSignedVSizeMatchesEstimate(t *testing.T) {
+    for _, addrType := range []crypto.AddressType{crypto.AddrP2PKH, crypto.AddrP2SHP2WPKH, crypto.AddrP2WPKH} {
+        req := testRequest(t, addrType, 40_000, 70_000)
+        req.Outputs = []Payment{{Address: "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9", Value: 100_000}}
+        d, err := Build(req)
+        if err != nil {
+            t.Fatalf("%s: %v", addrType, err)
+        }
+        if err := d.Sign(testKey); err != nil {
+            t.Fatalf("%s: sign: %v", addrType, err)
+        }
+        weight := int64(d.Tx.SerializeSizeStripped()*3 + d.Tx.SerializeSize())
+        actual := (weight + 3) / 4
+        if d.VSize < actual || d.VSize > actual+2 {
+            t.Errorf("%s: estimated vsize %d, signed vsize %d", addrType, d.VSize, actual)
+        }
+        if got := d.InputTotal() - 100_000 - d.Change; got != d.Fee {
+            t.Errorf("%s: fee %d does not balance (inputs-outputs = %d)", addrType, d.Fee, got)
+        }
+    }
+}
+
+func TestBuildDropsDustChange(t *testing.T) {
+    req := testRequest(t, crypto.AddrP2WPKH, 101_500)
+    req.Outputs = []Payment{{Address: req.From, Value: 100_000}}
+    d, err := Build(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if d.ChangeIndex != -1 || len(d.Tx.TxOut) != 1 {
+        t.Fatalf("expected no change output, got %d outputs", len(d.Tx.TxOut))
+    }
+    if d.Fee != 1_500 {
+        t.Errorf("fee = %d, want the 1500 left over", d.Fee)
+    }
+}
+
+func TestBuildSendAll(t *testing.T) {
+    req := testRequest(t, crypto.AddrP2WPKH, 10_000, 20_000, 30_000)
+    req.Outputs = []Payment{{Address: "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ"}}
+    req.SendAll = true
+    d, err := Build(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(d.Inputs) != 3 || len(d.Tx.TxOut) != 1 {
+        t.Fatalf("got %d inputs / %d outputs, want 3 / 1", len(d.Inputs), len(d.Tx.TxOut))
+    }
+    if models.Amount(d.Tx.TxOut[0].Value)+d.Fee != 60_000 {
+        t.Errorf("output %d + fee %d != 60000", d.Tx.TxOut[0].Value, d.Fee)
+    }
+    if len(d.Payments) != 1 || d.Payments[0].Value != models.Amount(d.Tx.TxOut[0].Value) {
+        t.Errorf("payments = %+v, want the swept value", d.Payments)
+    }
+}
+
+func TestBuildRoundsFeeUp(t *testing.T) {
+    // At 1.234 sat/vB the exact fee has a fraction of a litoshi.
+    req := testRequest(t, crypto.AddrP2WPKH, 100_000)
+    req.FeePerKB = 1234
+    req.Outputs = []Payment{{Address: "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ", Value: 50_000}}
+    d, err := Build(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    req.Outputs[0].Value = 0
+    req.SendAll = true
+    sweep, err := Build(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, d := range []*Draft{d, sweep} {
+        exact := d.VSize * 1234
+        if exact%1000 == 0 {
+            t.Fatalf("vsize %d gives a whole fee; the test needs a fraction", d.VSize)
+        }
+        if int64(d.Fee)*1000 < exact || int64(d.Fee)*1000 >= exact+1000 {
+            t.Errorf("fee %d for %d vB at 1.234 sat/vB, want %d/1000 rounded up", d.Fee, d.VSize, exact)
+        }
+    }
+}
+
+func TestBuildInsufficientFunds(t *testing.T) {
+    req := testRequest(t, crypto.AddrP2PKH, 1_000)
+    req.Outputs = []Payment{{Address: req.From, Value: 1_000}}
+    if _, err := Build(req); err != ErrInsufficientFunds {
+        t.Fatalf("err = %v, want ErrInsufficientFunds", err)
+    }
+}
+
+func TestBuildWithStrategy(t *testing.T) {
+    req := testRequest(t, crypto.AddrP2WPKH, 100_000, 61_000, 40_000)
+    req.Outputs = []Payment{{Address: "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ", Value: 99_000}}
+    req.Strategy = coinselect.LeastCost
+    d, err := Build(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // In order would spend 100k+61k and make change; 61k+40k pays it with
+    // a couple of hundred litoshis over the fee and no change to spend later.
+    if d.InputTotal() != 101_000 || d.ChangeIndex != -1 {
+        t.Errorf("spent %d with change index %d, want 61000+40000 and no change", d.InputTotal(), d.ChangeIndex)
+    }
+    if d.Fee != 2_000 {
+        t.Errorf("fee = %d, want the 2000 left over", d.Fee)
+    }
+}
+
+func TestBuildSignalsRBF(t *testing.T) {
+    req := testRequest(t, crypto.AddrP2WPKH, 40_000, 70_000)
+    req.Outputs = []Payment{{Address: "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ", Value: 100_000}}
+    d, err := Build(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    for i, in := range d.Tx.TxIn {
+        if in.Sequence >= 0xfffffffe {
+            t.Errorf("input %d has sequence %#x, does not signal replaceability", i, in.Sequence)
+        }
+    }
+}
+
+func TestReplaceRaisesFeeFromChange(t *testing.T) {
+    req := testRequest(t, crypto.AddrP2WPKH, 40_000, 70_000)
+    req.Outputs = []Payment{{Address: "LVuDpNCSSj6pQ7t9Pv6d6sUkLKoqDEVUnJ", Value: 50_000}}
+    orig, err := Build(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // The original only needed the first coin; the replacement must spend
+    // the same ones to conflict with it.
+    req.UTXOs = orig.Inputs
+    bumped, err := Replace(req, orig.Fee, 30_000)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if bumped.Tx.TxIn[0].PreviousOutPoint != orig.Tx.TxIn[0].PreviousOutPoint || len(bumped.Inputs) != len(orig.Inputs) {
+        t.Fatalf("replacement spends different inputs")
+    }
+    if bumped.Fee <= orig.Fee || bumped.Change != orig.Change-(bumped.Fee-orig.Fee) {
+        t.Errorf("fee %d -> %d, change %d -> %d: extra fee should come from change", orig.Fee, bumped.Fee, orig.Change, bumped.Change)
+    }
+    if _, err := Replace(req, orig.Fee, req.FeePerKB); !errors.Is(err, ErrFeeNotHigher) {
+        t.Errorf("same rate: err = %v, want ErrFeeNotHigher", err)
+    }
+    if _, err := Replace(req, orig.Fee, 10_000_000); !errors.Is(err, ErrInsufficientFunds) {
+        t.Errorf("huge rate: err = %v, want ErrInsufficientFunds", err)
+    }
+}
+
+func TestCPFPLiftsPackageRate(t *testing.T) {
+    req := testRequest(t, crypto.AddrP2WPKH, 500_000)
+    // A 200 vB parent paying 1 litoshi/vB, lifted to 30.
+    pkg, err := CPFP(req, 200, 200, 30_000)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if rate := pkg.FeePerKB(); rate < 30_000 || rate > 30_000+1000 {
+        t.Errorf("package rate = %d litoshis/kB, want about 30000", rate)
+    }
+    if pkg.Child.Payments[0].Address != req.From || len(pkg.Child.Tx.TxOut) != 1 {
+        t.Errorf("child should pay everything back to %s", req.From)
+    }
+    if pkg.Child.Fee+pkg.Child.Payments[0].Value != 500_000 {
+        t.Errorf("child fee %d + output %d != 500000", pkg.Child.Fee, pkg.Child.Payments[0].Value)
+    }
+    if _, err := CPFP(req, 10_000, 200, 30_000); err != ErrPackageRateMet {
+        t.Errorf("err = %v, want ErrPackageRateMet for a parent already at 50 litoshis/vB", err)
+    }
+}
+
+func TestBuildSpendsSeveralAddresses(t *testing.T) {
+    const otherKey = "0000000000000000000000000000000000000000000000000000000000000002"
+    params := &netparams.MainNetParams
+    other, _ := crypto.LoadLitecoinWallet(params, otherKey, crypto.AddrP2WPKH)
+    change, _ := crypto.LoadLitecoinWallet(params, "0000000000000000000000000000000000000000000000000000000000000003", crypto.AddrP2WPKH)
+    req := testRequest(t, crypto.AddrP2WPKH, 40_000)
+    req.UTXOs[0].Address = req.From
+    // No script: the input's own address must stand in, not From's.
+    req.UTXOs = append(req.UTXOs, models.UTXO{TxHash: hex.EncodeToString([]byte{31: 9}), Value: 70_000, Address: other.Address})
+    req.Change = change.Address
+    req.Outputs = []Payment{{Address: "ltc1qw508d6qejxtdg4y5r3zarvary0c5xw7kgmn4n9", Value: 50_000}}
+    d, err := Build(req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(d.Inputs) != 2 || d.ChangeIndex < 0 || d.ChangeAddress != change.Address {
+        t.Fatalf("inputs %d, change %d to %q; want both coins and change to %s", len(d.Inputs), d.ChangeIndex, d.ChangeAddress, change.Address)
+    }
+    addr, _ := netparams.DecodeAddress(change.Address, params)
+    script, _ := txscript.PayToAddrScript(addr)
+    if got := d.Tx.TxOut[d.ChangeIndex].PkScript; hex.EncodeToString(got) != hex.EncodeToString(script) {
+        t.Errorf("change pays %x, want %x", got, script)
+    }
+    if err := d.SignWith(map[string]string{req.From: testKey}); err == nil {
+        t.Error("signed without the key of the second address")
+    }
+    if err := d.Sign(testKey); err == nil {
+        t.Error("one key signed inputs from two addresses")
+    }
+    if err := d.SignWith(map[string]string{req.From: testKey, other.Address: otherKey}); err != nil {
+        t.Fatal(err)
+    }
+}
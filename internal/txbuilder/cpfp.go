@@ -0,0 +1,64 @@
+package txbuilder
+
+import (
+    "errors"
+    "fmt"
+
+    "litecoin-wallet/internal/models"
+)
+
+var ErrPackageRateMet = errors.New("parent already pays the target fee rate")
+
+// Package is an unconfirmed parent and the child spending its output. Miners
+// weigh the two together, so a high-fee child pulls its parent into a block.
+type Package struct {
+    Child       *Draft
+    ParentFee   models.Amount
+    ParentVSize int64
+}
+
+// Fee is what parent and child pay together.
+func (p *Package) Fee() models.Amount { return p.ParentFee + p.Child.Fee }
+
+// VSize is the combined virtual size of parent and child.
+func (p *Package) VSize() int64 { return p.ParentVSize + p.Child.VSize }
+
+// FeePerKB is the effective fee rate of the package in litoshis per kB.
+func (p *Package) FeePerKB() int64 { return int64(p.Fee()) * 1000 / p.VSize() }
+
+// ParentFeePerKB is the fee rate of the parent alone.
+func (p *Package) ParentFeePerKB() int64 { return int64(p.ParentFee) * 1000 / p.ParentVSize }
+
+// CPFP builds a child that sweeps req.UTXOs, outputs of an unconfirmed
+// parent paying us, back to req.From with a fee that lifts parent and
+// child together to targetPerKB. The child never pays less than the
+// minimum relay rate on its own.
+func CPFP(req Request, parentFee models.Amount, parentVSize int64, targetPerKB int64) (*Package, error) {
+    if parentVSize <= 0 {
+        return nil, fmt.Errorf("parent size unknown")
+    }
+    if int64(parentFee)*1000/parentVSize >= targetPerKB {
+        return nil, ErrPackageRateMet
+    }
+    req.Outputs = []Payment{{Address: req.From}}
+    req.SendAll = true
+    req.Strategy = ""
+    // Size the child at the target rate first; its size does not depend on
+    // the fee, so the rate that covers the whole package follows from it.
+    req.FeePerKB = targetPerKB
+    child, err := Build(req)
+    if err != nil {
+        return nil, err
+    }
+    need := int64(feeAt(parentVSize+child.VSize, targetPerKB) - parentFee)
+    if min := int64(feeAt(child.VSize, IncrementalRelayFeePerKB)); need < min {
+        need = min
+    }
+    // Round the child's rate up so its fee reaches need.
+    req.FeePerKB = (need*1000 + child.VSize - 1) / child.VSize
+    child, err = Build(req)
+    if err != nil {
+        return nil, err
+    }
+    return &Package{Child: child, ParentFee: parentFee, ParentVSize: parentVSize}, nil
+}
@@ -0,0 +1,37 @@
+package txbuilder
+
+import (
+    "errors"
+    "fmt"
+
+    "litecoin-wallet/internal/models"
+)
+
+// IncrementalRelayFeePerKB is the minimum rate, in litoshis per kB, by which
+// a replacement must raise the fee over the transaction it replaces (BIP
+// 125 rule 4).
+const IncrementalRelayFeePerKB = 1000
+
+var ErrFeeNotHigher = errors.New("replacement fee is not high enough")
+
+// Replace rebuilds orig, a request already broadcast with fee oldFee, at
+// feePerKB. It spends exactly the same inputs, so the result conflicts with
+// and replaces the original; the extra fee comes out of the change, or out
+// of the single output of a sweep.
+func Replace(orig Request, oldFee models.Amount, feePerKB int64) (*Draft, error) {
+    req := orig
+    req.FeePerKB = feePerKB
+    req.Strategy = ""
+    req.SpendAll = true
+    d, err := Build(req)
+    if errors.Is(err, ErrInsufficientFunds) {
+        return nil, fmt.Errorf("%w: change cannot cover the higher fee", err)
+    }
+    if err != nil {
+        return nil, err
+    }
+    if min := oldFee + feeAt(d.VSize, IncrementalRelayFeePerKB); d.Fee < min {
+        return nil, fmt.Errorf("%w: %s, need at least %s", ErrFeeNotHigher, d.Fee, min)
+    }
+    return d, nil
+}
@@ -0,0 +1,205 @@
+// Package txsync keeps the local transaction cache in internal/db up to
+// date with a chain provider, fetching only what changed since the last
+// sync.
+package txsync
+
+import (
+    "context"
+    "errors"
+
+    "litecoin-wallet/internal/api"
+    "litecoin-wallet/internal/db"
+)
+
+// Defaults for Syncer.
+const (
+    // DefaultReorgDepth is how many confirmations make a cached transaction
+    // final. Shallower ones are re-checked on every sync.
+    DefaultReorgDepth = 6
+    // DefaultDetailBudget bounds the GetTransaction calls per sync used to
+    // fill in inputs, outputs and fees. The rest wait for the next sync.
+    DefaultDetailBudget = 10
+)
+
+// Store is where the cache lives; db.LoadTxCache and db.SaveTxSync by
+// default.
+type Store interface {
+    LoadTxCache(address string) (db.TxCache, error)
+    SaveTxSync(address string, u db.TxSyncUpdate) error
+}
+
+type dbStore struct{}
+
+func (dbStore) LoadTxCache(address string) (db.TxCache, error) { return db.LoadTxCache(address) }
+func (dbStore) SaveTxSync(address string, u db.TxSyncUpdate) error {
+    return db.SaveTxSync(address, u)
+}
+
+// Syncer brings an address's cached history up to date.
+type Syncer struct {
+    Provider     api.ChainProvider
+    Store        Store
+    ReorgDepth   int
+    DetailBudget int
+}
+
+func New(provider api.ChainProvider) *Syncer {
+    return &Syncer{
+        Provider:     provider,
+        Store:        dbStore{},
+        ReorgDepth:   DefaultReorgDepth,
+        DetailBudget: DefaultDetailBudget,
+    }
+}
+
+// Result counts what a sync changed.
+type Result struct {
+    Added, Updated, Removed, Details int
+}
+
+// Sync walks the provider's history newest first and stops at the first
+// cached transaction that is final, since everything older was stored by an
+// earlier complete sync. Unconfirmed and shallow cached entries the walk did
+// not meet again were dropped or reorged out and are looked up one by one.
+// Whatever was fetched is saved even when the walk fails part way; the
+// checkpoint only moves when it finished. The updated cache is returned.
+func (s *Syncer) Sync(ctx context.Context, address string) (db.TxCache, Result, error) {
+    var res Result
+    cache, err := s.Store.LoadTxCache(address)
+    if err != nil {
+        return cache, res, err
+    }
+    cached := make(map[string]db.CachedTx, len(cache.Txs))
+    for _, c := range cache.Txs {
+        cached[c.Txid] = c
+    }
+    u := db.TxSyncUpdate{TipHeight: cache.TipHeight}
+    changed := map[string]db.CachedTx{}
+    seen := map[string]bool{}
+    var walked []string
+
+    h := api.NewHistory(s.Provider, address)
+    for h.Next(ctx) {
+        tx := h.Tx()
+        seen[tx.Hash] = true
+        walked = append(walked, tx.Hash)
+        height := max(tx.BlockHeight, 0)
+        if height > 0 && tx.Confirmations > 0 {
+            u.TipHeight = max(u.TipHeight, height+int64(tx.Confirmations)-1)
+        }
+        old, ok := cached[tx.Hash]
+        if ok && old.Height == height && old.Value == tx.Value {
+            if !cache.SyncedAt.IsZero() && tx.Confirmations >= s.ReorgDepth {
+                break
+            }
+            continue
+        }
+        c := db.CachedTx{Txid: tx.Hash, Height: height, Value: tx.Value, Received: tx.Received}
+        if ok && old.Height == height {
+            // Same block, so the inputs and outputs still hold.
+            c.Fee, c.HasDetail, c.Inputs, c.Outputs = old.Fee, old.HasDetail, old.Inputs, old.Outputs
+        }
+        if ok {
+            res.Updated++
+        } else {
+            res.Added++
+        }
+        changed[tx.Hash] = c
+    }
+    walkErr := h.Err()
+
+    if walkErr == nil {
+        for _, c := range cache.Txs {
+            if seen[c.Txid] || (c.Height > 0 && c.Confirmations(u.TipHeight) >= s.ReorgDepth) {
+                continue
+            }
+            if err := s.recheck(ctx, c, changed, &u, &res); err != nil {
+                walkErr = err
+                break
+            }
+        }
+    }
+
+    // Fill in details, newest first, within the budget. Not worth trying
+    // when the provider just failed.
+    if walkErr == nil {
+        order := walked
+        for _, c := range cache.Txs {
+            order = append(order, c.Txid)
+        }
+        budget, tried := s.DetailBudget, map[string]bool{}
+        for _, txid := range order {
+            if budget <= 0 || ctx.Err() != nil {
+                break
+            }
+            c, ok := changed[txid]
+            if !ok {
+                c, ok = cached[txid]
+            }
+            if !ok || tried[txid] || c.HasDetail || isDeleted(u.Delete, txid) {
+                continue
+            }
+            tried[txid] = true
+            budget--
+            if s.fillDetail(ctx, &c) {
+                changed[txid] = c
+                res.Details++
+            }
+        }
+    }
+
+    for _, c := range changed {
+        u.Upsert = append(u.Upsert, c)
+    }
+    u.Complete = walkErr == nil
+    if err := s.Store.SaveTxSync(address, u); err != nil {
+        return cache, res, err
+    }
+    if updated, err := s.Store.LoadTxCache(address); err == nil {
+        cache = updated
+    }
+    return cache, res, walkErr
+}
+
+// recheck looks up a shallow cached transaction the history walk no longer
+// lists, and removes it if the provider has never heard of it.
+func (s *Syncer) recheck(ctx context.Context, c db.CachedTx, changed map[string]db.CachedTx, u *db.TxSyncUpdate, res *Result) error {
+    detail, err := s.Provider.GetTransaction(ctx, c.Txid)
+    if errors.Is(err, api.ErrTxNotFound) {
+        u.Delete = append(u.Delete, c.Txid)
+        res.Removed++
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    height := max(detail.BlockHeight, 0)
+    if height == c.Height {
+        return nil
+    }
+    c.Height = height
+    c.Fee, c.HasDetail, c.Inputs, c.Outputs = detail.Fees, true, detail.Inputs, detail.Outputs
+    changed[c.Txid] = c
+    res.Updated++
+    return nil
+}
+
+// fillDetail fetches the inputs, outputs and fee of c. A failure is left
+// for the next sync.
+func (s *Syncer) fillDetail(ctx context.Context, c *db.CachedTx) bool {
+    detail, err := s.Provider.GetTransaction(ctx, c.Txid)
+    if err != nil {
+        return false
+    }
+    c.Fee, c.HasDetail, c.Inputs, c.Outputs = detail.Fees, true, detail.Inputs, detail.Outputs
+    return true
+}
+
+func isDeleted(deleted []string, txid string) bool {
+    for _, d := range deleted {
+        if d == txid {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,147 @@
+package txsync
+
+import (
+    "context"
+    "errors"
+    "strconv"
+    "testing"
+
+    "litecoin-wallet/internal/api"
+    "litecoin-wallet/internal/db"
+    "litecoin-wallet/internal/models"
+)
+
+// memStore keeps the cache in memory, applying updates like db.SaveTxSync.
+type memStore struct {
+    cache db.TxCache
+}
+
+func (m *memStore) LoadTxCache(address string) (db.TxCache, error) {
+    c := m.cache
+    c.Txs = append([]db.CachedTx(nil), m.cache.Txs...)
+    return c, nil
+}
+
+func (m *memStore) SaveTxSync(address string, u db.TxSyncUpdate) error {
+    byID := map[string]int{}
+    for i, c := range m.cache.Txs {
+        byID[c.Txid] = i
+    }
+    for _, c := range u.Upsert {
+        if i, ok := byID[c.Txid]; ok {
+            m.cache.Txs[i] = c
+        } else {
+            byID[c.Txid] = len(m.cache.Txs)
+            m.cache.Txs = append(m.cache.Txs, c)
+        }
+    }
+    var kept []db.CachedTx
+    for _, c := range m.cache.Txs {
+        if !isDeleted(u.Delete, c.Txid) {
+            kept = append(kept, c)
+        }
+    }
+    m.cache.Txs, m.cache.TipHeight = kept, u.TipHeight
+    if u.Complete {
+        m.cache.SyncedAt = m.cache.SyncedAt.AddDate(0, 0, 1)
+    }
+    return nil
+}
+
+// pagedProvider serves History one transaction per page and counts the
+// pages and lookups it was asked for.
+type pagedProvider struct {
+    *api.MemoryProvider
+    history []models.Transaction
+    pages   int
+    lookups int
+}
+
+func (p *pagedProvider) HistoryPage(ctx context.Context, address, cursor string) ([]models.Transaction, string, error) {
+    p.pages++
+    if p.Err != nil {
+        return nil, "", p.Err
+    }
+    i, _ := strconv.Atoi(cursor)
+    if i >= len(p.history) {
+        return nil, "", nil
+    }
+    next := ""
+    if i+1 < len(p.history) {
+        next = strconv.Itoa(i + 1)
+    }
+    return p.history[i : i+1], next, nil
+}
+
+func (p *pagedProvider) GetTransaction(ctx context.Context, txid string) (models.TxDetail, error) {
+    p.lookups++
+    return p.MemoryProvider.GetTransaction(ctx, txid)
+}
+
+// setChain makes txs the provider's history at chain height tip.
+func (p *pagedProvider) setChain(tip int64, txs ...models.Transaction) {
+    p.history = nil
+    p.Txs = map[string]models.TxDetail{}
+    for _, tx := range txs {
+        if tx.BlockHeight > 0 {
+            tx.Confirmations = int(tip - tx.BlockHeight + 1)
+        }
+        p.history = append(p.history, tx)
+        p.Txs[tx.Hash] = models.TxDetail{Hash: tx.Hash, BlockHeight: tx.BlockHeight, Fees: 100}
+    }
+}
+
+func TestSyncIncrementalAndReorg(t *testing.T) {
+    p := &pagedProvider{MemoryProvider: api.NewMemoryProvider()}
+    store := &memStore{}
+    s := New(p)
+    s.Store = store
+    ctx := context.Background()
+
+    a := models.Transaction{Hash: "a", BlockHeight: 100, Value: 5000}
+    b := models.Transaction{Hash: "b", BlockHeight: 108, Value: -1000}
+    c := models.Transaction{Hash: "c", Value: 300}
+    p.setChain(110, c, b, a)
+    cache, res, err := s.Sync(ctx, "L1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if res.Added != 3 || res.Details != 3 || len(cache.Txs) != 3 || cache.TipHeight != 110 {
+        t.Fatalf("first sync: %+v, cache %+v", res, cache)
+    }
+
+    // b is reorged out, c confirms and d arrives. The walk must stop at a,
+    // which is final and already cached.
+    c.BlockHeight = 112
+    d := models.Transaction{Hash: "d", Value: 700}
+    p.setChain(112, d, c, a)
+    extra := models.Transaction{Hash: "older", BlockHeight: 50, Value: 1}
+    p.history = append(p.history, extra)
+    p.pages, p.lookups = 0, 0
+    cache, res, err = s.Sync(ctx, "L1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if p.pages != 3 {
+        t.Errorf("fetched %d pages, want to stop at the final transaction a", p.pages)
+    }
+    if res.Added != 1 || res.Updated != 1 || res.Removed != 1 {
+        t.Errorf("second sync: %+v", res)
+    }
+    if len(cache.Txs) != 3 || isDeleted([]string{cache.Txs[0].Txid, cache.Txs[1].Txid, cache.Txs[2].Txid}, "b") {
+        t.Errorf("cache = %+v, want b removed", cache.Txs)
+    }
+    for _, tx := range cache.Txs {
+        if tx.Txid == "c" && (tx.Height != 112 || !tx.HasDetail) {
+            t.Errorf("c = %+v, want confirmed at 112 with details refetched", tx)
+        }
+    }
+
+    // Offline: the walk fails, nothing is lost and the checkpoint stays.
+    synced := store.cache.SyncedAt
+    p.Err = api.ErrNetwork
+    cache, _, err = s.Sync(ctx, "L1")
+    if !errors.Is(err, api.ErrNetwork) || len(cache.Txs) != 3 || !store.cache.SyncedAt.Equal(synced) {
+        t.Errorf("offline sync: err = %v, %d cached, checkpoint moved %v", err, len(cache.Txs), !store.cache.SyncedAt.Equal(synced))
+    }
+}
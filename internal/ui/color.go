@@ -2,6 +2,8 @@ package ui
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
 )
 
@@ -18,34 +20,51 @@ const (
     Under   = "\033[4m"
 )
 
-func PrintBanner() {
+// Printer writes the styled output to W, the terminal or a buffer in tests.
+type Printer struct {
+    W io.Writer
+}
+
+// Stdout is the Printer for the terminal; the package-level Print functions
+// use it.
+var Stdout = Printer{W: os.Stdout}
+
+func (p Printer) PrintBanner() {
     border := Cyan + "╔" + line("═", 46) + "╗" + Reset
     title := Bold + Under + "LITECOIN WALLET" + Reset
-    fmt.Println(border)
+    fmt.Fprintln(p.W, border)
     pad := (46-len("LITECOIN WALLET"))/2
-    fmt.Printf("%s║%s%s%s%s║\n", Cyan, strings.Repeat(" ", pad), title, strings.Repeat(" ", 46-pad-len("LITECOIN WALLET")), Reset)
-    fmt.Println(Cyan + "╚" + line("═", 46) + "╝" + Reset)
+    fmt.Fprintf(p.W, "%s║%s%s%s%s║\n", Cyan, strings.Repeat(" ", pad), title, strings.Repeat(" ", 46-pad-len("LITECOIN WALLET")), Reset)
+    fmt.Fprintln(p.W, Cyan + "╚" + line("═", 46) + "╝" + Reset)
 }
 
 
-func PrintMenu(title string, items []string) {
-    fmt.Println(Blue + "╔" + line("─", 44) + "╗" + Reset)
-    fmt.Printf("%s║%-44s║\n", Blue, title)
-    fmt.Println(Blue + "╠" + line("═", 44) + "╣" + Reset)
+func (p Printer) PrintMenu(title string, items []string) {
+    fmt.Fprintln(p.W, Blue + "╔" + line("─", 44) + "╗" + Reset)
+    fmt.Fprintf(p.W, "%s║%-44s║\n", Blue, title)
+    fmt.Fprintln(p.W, Blue + "╠" + line("═", 44) + "╣" + Reset)
     for _, it := range items {
-        fmt.Printf("%s║ %-43s║\n", Blue, it)
+        fmt.Fprintf(p.W, "%s║ %-43s║\n", Blue, it)
     }
-    fmt.Println(Blue + "╚" + line("─", 44) + "╝" + Reset)
+    fmt.Fprintln(p.W, Blue + "╚" + line("─", 44) + "╝" + Reset)
 }
 
 
-func PrintSection(title string) {
-    fmt.Printf("\n%s╭─[ %s ]─╮%s\n", Magenta, title, Reset)
+func (p Printer) PrintSection(title string) {
+    fmt.Fprintf(p.W, "\n%s╭─[ %s ]─╮%s\n", Magenta, title, Reset)
 }
 
-func PrintSuccess(text string)  { fmt.Printf("%s✔ %s%s\n", Green, text, Reset) }
-func PrintInfo(text string)     { fmt.Printf("%s» %s%s\n", Cyan, text, Reset) }
-func PrintError(text string)    { fmt.Printf("%s✖ %s%s\n", Red, text, Reset) }
-func PrintPrompt(text string)   { fmt.Printf("%s%s%s", Yellow+Bold, text, Reset) }
+func (p Printer) PrintSuccess(text string)  { fmt.Fprintf(p.W, "%s✔ %s%s\n", Green, text, Reset) }
+func (p Printer) PrintInfo(text string)     { fmt.Fprintf(p.W, "%s» %s%s\n", Cyan, text, Reset) }
+func (p Printer) PrintError(text string)    { fmt.Fprintf(p.W, "%s✖ %s%s\n", Red, text, Reset) }
+func (p Printer) PrintPrompt(text string)   { fmt.Fprintf(p.W, "%s%s%s", Yellow+Bold, text, Reset) }
+
+func PrintBanner()                          { Stdout.PrintBanner() }
+func PrintMenu(title string, items []string) { Stdout.PrintMenu(title, items) }
+func PrintSection(title string)             { Stdout.PrintSection(title) }
+func PrintSuccess(text string)              { Stdout.PrintSuccess(text) }
+func PrintInfo(text string)                 { Stdout.PrintInfo(text) }
+func PrintError(text string)                { Stdout.PrintError(text) }
+func PrintPrompt(text string)               { Stdout.PrintPrompt(text) }
 
 func line(char string, n int) string { s := ""; for i := 0; i < n; i++ { s += char }; return s }
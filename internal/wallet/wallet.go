@@ -5,4 +5,49 @@ type Wallet struct {
     PublicKey  string
     Address    string
     Alias      string
+    Kind       string
+    Seed       string
+    Path       string
+    AddrType   string
+    // Receive and Change are an HD wallet's addresses on each chain in
+    // index order, Receive[0] being Address. Keys holds the private key of
+    // every one of them. All three are empty for a single-key wallet.
+    Receive []string
+    Change  []string
+    Keys    map[string]string
+}
+
+// Addresses returns every address the wallet owns, Address first.
+func (w *Wallet) Addresses() []string {
+    if len(w.Receive) == 0 {
+        return []string{w.Address}
+    }
+    return append(append([]string{}, w.Receive...), w.Change...)
+}
+
+// ReceiveAddress is the address to hand out for payments: the newest
+// receive address of an HD wallet, the only one otherwise.
+func (w *Wallet) ReceiveAddress() string {
+    if len(w.Receive) == 0 {
+        return w.Address
+    }
+    return w.Receive[len(w.Receive)-1]
+}
+
+// Owns reports whether address is one of the wallet's.
+func (w *Wallet) Owns(address string) bool {
+    for _, a := range w.Addresses() {
+        if a == address {
+            return true
+        }
+    }
+    return false
+}
+
+// SigningKeys maps each of the wallet's addresses to its private key.
+func (w *Wallet) SigningKeys() map[string]string {
+    if len(w.Keys) == 0 {
+        return map[string]string{w.Address: w.PrivateKey}
+    }
+    return w.Keys
 }